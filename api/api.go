@@ -7,7 +7,7 @@ import (
 	"time"
 )
 
-//go:generate mockgen -package mock -destination ../mock/mock_api.go github.com/evcc-io/evcc/api Charger,ChargeState,PhaseSwitcher,Identifier,Meter,MeterEnergy,Vehicle,ChargeRater,Battery,Tariff
+//go:generate mockgen -package mock -destination ../mock/mock_api.go github.com/evcc-io/evcc/api Charger,ChargeState,PhaseSwitcher,Identifier,Meter,MeterEnergy,Vehicle,ChargeRater,Battery,Tariff,Reserver
 
 // ChargeMode is the charge operation mode. Valid values are off, now, minpv and pv
 type ChargeMode string
@@ -133,6 +133,12 @@ type Authorizer interface {
 	Authorize(key string) error
 }
 
+// Reserver reserves the charger for an identifier until expiry
+type Reserver interface {
+	Reserve(id string, expiry time.Time) error
+	CancelReservation() error
+}
+
 // Vehicle represents the EV and it's battery
 type Vehicle interface {
 	Battery
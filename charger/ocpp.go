@@ -17,12 +17,19 @@ import (
 	"github.com/samber/lo"
 )
 
-const statusTimeout = 30 * time.Second
+const (
+	statusTimeout = 30 * time.Second
+
+	defaultCurrent   = 6.0 // A, default profile limit before any current has been set
+	minActiveCurrent = 1.0 // A, minimum current for a phase to be considered active
+	maxPhaseRetries  = 2   // profile updates sent again if the measured phases don't change
+)
 
 // OCPP charger implementation
 type OCPP struct {
 	log               *util.Logger
 	cp                *ocpp.CP
+	conn              *ocpp.Connector
 	connector         int
 	idtag             string
 	phases            int
@@ -30,6 +37,8 @@ type OCPP struct {
 	meterValuesSample string
 	timeout           time.Duration
 	phaseSwitching    bool
+	verifyPhases      bool
+	phaseRetries      int
 }
 
 const defaultIdTag = "evcc"
@@ -85,7 +94,7 @@ func NewOCPPFromConfig(other map[string]interface{}) (api.Charger, error) {
 	}
 
 	var currentsG func() (float64, float64, float64, error)
-	if c.hasMeasurement(types.MeasurandCurrentImport + ".L3") {
+	if c.hasMeasurement(types.MeasurandCurrentImport) {
 		currentsG = c.currents
 	}
 
@@ -107,14 +116,18 @@ func NewOCPP(id string, connector int, idtag string, meterValues string, meterIn
 	}
 	log := util.NewLogger(unit)
 
-	cp := ocpp.NewChargePoint(log, id, timeout)
-	if err := ocpp.Instance().Register(id, cp); err != nil {
+	// connectors of the same station share the charge point
+	conn, err := ocpp.Instance().RegisterConnector(id, connector, log, timeout)
+	if err != nil {
 		return nil, err
 	}
 
+	cp := conn.ChargePoint()
+
 	c := &OCPP{
 		log:       log,
 		cp:        cp,
+		conn:      conn,
 		connector: connector,
 		idtag:     idtag,
 		timeout:   timeout,
@@ -145,7 +158,11 @@ func NewOCPP(id string, connector int, idtag string, meterValues string, meterIn
 	_ = keys
 
 	// quirks mode disables GetConfiguration
-	if quirks {
+	if cp.Protocol() == ocpp.ProtocolV201 {
+		if err := c.getVariables(&meterSampleInterval); err != nil {
+			return nil, err
+		}
+	} else if quirks {
 		c.meterValuesSample = meterValues
 		if meterInterval == 0 {
 			meterInterval = 10 * time.Second
@@ -173,36 +190,7 @@ func NewOCPP(id string, connector int, idtag string, meterValues string, meterIn
 
 					c.log.TRACE.Printf("%s (%s): %s", opt.Key, rw[opt.Readonly], *opt.Value)
 
-					switch opt.Key {
-					case ocpp.KeyNumberOfConnectors:
-						var val int
-						if val, err = strconv.Atoi(*opt.Value); err == nil && c.connector > val {
-							err = fmt.Errorf("connector %d exceeds max available connectors: %d", c.connector, val)
-						}
-
-					case ocpp.KeyMeterValuesSampledData:
-						c.meterValuesSample = *opt.Value
-
-					case ocpp.KeyMeterValueSampleInterval:
-						var val int
-						if val, err = strconv.Atoi(*opt.Value); err == nil {
-							meterSampleInterval = time.Duration(val) * time.Second
-						}
-
-					case ocpp.KeyConnectorSwitch3to1PhaseSupported:
-						var val bool
-						if val, err = strconv.ParseBool(*opt.Value); err == nil {
-							c.phaseSwitching = val
-						}
-
-					case ocpp.KeyAlfenPlugAndChargeIdentifier:
-						if c.idtag == defaultIdTag {
-							c.idtag = *opt.Value
-							c.log.DEBUG.Printf("overriding default `idTag` with Alfen-specific value: %s", c.idtag)
-						}
-					}
-
-					if err != nil {
+					if err = c.applyConfiguration(opt.Key, *opt.Value, &meterSampleInterval); err != nil {
 						break
 					}
 				}
@@ -216,6 +204,10 @@ func NewOCPP(id string, connector int, idtag string, meterValues string, meterIn
 		}
 	}
 
+	// accept remote starts and send the id tag list
+	conn.SetRemoteIdTag(c.idtag)
+	ocpp.Instance().SyncLocalList(cp.ID())
+
 	if meterValues != "" && meterValues != c.meterValuesSample {
 		if err := c.configure(ocpp.KeyMeterValuesSampledData, meterValues); err != nil {
 			return nil, err
@@ -238,7 +230,7 @@ func NewOCPP(id string, connector int, idtag string, meterValues string, meterIn
 		// HACK: setup watchdog for meter values if not happy with config
 		if meterInterval > 0 {
 			c.log.DEBUG.Println("enabling meter watchdog")
-			go cp.WatchDog(meterInterval)
+			go conn.WatchDog(meterInterval)
 		}
 	}
 
@@ -253,13 +245,56 @@ func NewOCPP(id string, connector int, idtag string, meterValues string, meterIn
 	// }
 
 	// request initial status
-	_ = cp.Initialized(statusTimeout)
+	_ = conn.Initialized(statusTimeout)
 
-	// TODO: check for running transaction
+	// recognize transaction still running after restart
+	if err := conn.RestoreTransaction(); err != nil {
+		c.log.ERROR.Printf("restore transaction: %v", err)
+	}
+
+	if err := conn.RestoreReservation(); err != nil {
+		c.log.ERROR.Printf("restore reservation: %v", err)
+	}
 
 	return c, nil
 }
 
+// applyConfiguration applies the charge point's configuration key
+func (c *OCPP) applyConfiguration(key, value string, meterSampleInterval *time.Duration) error {
+	var err error
+
+	switch key {
+	case ocpp.KeyNumberOfConnectors:
+		var val int
+		if val, err = strconv.Atoi(value); err == nil && c.connector > val {
+			err = fmt.Errorf("connector %d exceeds max available connectors: %d", c.connector, val)
+		}
+
+	case ocpp.KeyMeterValuesSampledData:
+		c.meterValuesSample = value
+
+	case ocpp.KeyMeterValueSampleInterval:
+		var val int
+		if val, err = strconv.Atoi(value); err == nil {
+			*meterSampleInterval = time.Duration(val) * time.Second
+		}
+
+	case ocpp.KeyConnectorSwitch3to1PhaseSupported:
+		var val bool
+		if val, err = strconv.ParseBool(value); err == nil {
+			c.phaseSwitching = val
+		}
+
+	case ocpp.KeyAlfenPlugAndChargeIdentifier:
+		if c.idtag == defaultIdTag {
+			c.idtag = value
+			c.log.DEBUG.Printf("overriding default `idTag` with Alfen-specific value: %s", c.idtag)
+		}
+	}
+
+	return err
+}
+
 // hasMeasurement checks if meterValuesSample contains given measurement
 func (c *OCPP) hasMeasurement(val types.Measurand) bool {
 	return lo.Contains(strings.Split(c.meterValuesSample, ","), string(val))
@@ -267,13 +302,17 @@ func (c *OCPP) hasMeasurement(val types.Measurand) bool {
 
 // configure updates CP configuration
 func (c *OCPP) configure(key, val string) error {
+	if c.cp.Protocol() == ocpp.ProtocolV201 {
+		return c.setVariable(key, val)
+	}
+
 	rc := make(chan error, 1)
 
 	err := ocpp.Instance().ChangeConfiguration(c.cp.ID(), func(resp *core.ChangeConfigurationConfirmation, err error) {
 		c.log.TRACE.Printf("%T: %v", resp, resp)
 
 		if err == nil && resp != nil && resp.Status != core.ConfigurationStatusAccepted {
-			rc <- fmt.Errorf("ChangeConfiguration failed: %s", resp.Status)
+			err = fmt.Errorf("ChangeConfiguration failed: %s", resp.Status)
 		}
 
 		rc <- err
@@ -297,20 +336,33 @@ func (c *OCPP) wait(err error, rc chan error) error {
 
 // Status implements the api.Charger interface
 func (c *OCPP) Status() (api.ChargeStatus, error) {
-	return c.cp.Status()
+	return c.conn.Status()
 }
 
 // Enabled implements the api.Charger interface
 func (c *OCPP) Enabled() (bool, error) {
-	return c.cp.TransactionID() > 0, nil
+	return c.conn.TransactionID() > 0, nil
 }
 
 // Enable implements the api.Charger interface
 func (c *OCPP) Enable(enable bool) error {
+	if c.cp.Protocol() == ocpp.ProtocolV201 {
+		return c.enable201(enable)
+	}
+
 	var err error
 	rc := make(chan error, 1)
 
 	if enable {
+		// reserved connectors only accept the reservation's id tag
+		idtag := c.idtag
+		if res := c.conn.Reservation(); res != nil {
+			idtag = res.IdTag
+		}
+
+		// accept the id tag for the requested transaction only
+		c.conn.RemoteStart(idtag)
+
 		err = ocpp.Instance().RemoteStartTransaction(c.cp.ID(), func(resp *core.RemoteStartTransactionConfirmation, err error) {
 			c.log.TRACE.Printf("%T: %+v", resp, resp)
 
@@ -319,7 +371,7 @@ func (c *OCPP) Enable(enable bool) error {
 			}
 
 			rc <- err
-		}, c.idtag, func(request *core.RemoteStartTransactionRequest) {
+		}, idtag, func(request *core.RemoteStartTransactionRequest) {
 			request.ConnectorId = &c.connector
 			request.ChargingProfile = getTxChargingProfile(c.current, c.phases)
 		})
@@ -332,7 +384,7 @@ func (c *OCPP) Enable(enable bool) error {
 			}
 
 			rc <- err
-		}, c.cp.TransactionID())
+		}, c.conn.TransactionID())
 	}
 
 	return c.wait(err, rc)
@@ -364,7 +416,13 @@ func (c *OCPP) updatePeriod(current float64, phases int) error {
 
 	c.log.TRACE.Printf("update period with phases: %d, current: %f", phases, current)
 
-	err := c.setChargingProfile(c.connector, getTxChargingProfile(current, phases))
+	var err error
+	if c.cp.Protocol() == ocpp.ProtocolV201 {
+		err = c.setChargingProfile201(getTxChargingProfile201(current, phases, c.conn.StationTransactionID()))
+	} else {
+		err = c.setChargingProfile(c.connector, getTxChargingProfile(current, phases))
+	}
+
 	if err != nil {
 		err = fmt.Errorf("set charging profile: %w", err)
 	}
@@ -390,6 +448,15 @@ func getTxChargingProfile(current float64, phases int) *types.ChargingProfile {
 	}
 }
 
+// getTxDefaultChargingProfile returns the default profile. Its id differs from the transaction profile's id as profiles with equal ids replace each other.
+func getTxDefaultChargingProfile(current float64, phases int) *types.ChargingProfile {
+	profile := getTxChargingProfile(current, phases)
+	profile.ChargingProfileId = 2
+	profile.ChargingProfilePurpose = types.ChargingProfilePurposeTxDefaultProfile
+
+	return profile
+}
+
 // MaxCurrent implements the api.Charger interface
 func (c *OCPP) MaxCurrent(current int64) error {
 	return c.MaxCurrentMillis(float64(current))
@@ -408,31 +475,126 @@ func (c *OCPP) MaxCurrentMillis(current float64) error {
 
 // CurrentPower implements the api.Meter interface
 func (c *OCPP) currentPower() (float64, error) {
-	return c.cp.CurrentPower()
+	return c.conn.CurrentPower()
 }
 
 // TotalEnergy implements the api.MeterTotal interface
 func (c *OCPP) totalEnergy() (float64, error) {
-	return c.cp.TotalEnergy()
+	return c.conn.TotalEnergy()
 }
 
 // Currents implements the api.PhaseCurrents interface
 func (c *OCPP) currents() (float64, float64, float64, error) {
-	return c.cp.Currents()
+	l1, l2, l3, err := c.conn.Currents()
+	if err == nil && c.verifyPhases {
+		if err := c.checkPhases(l1, l2, l3); err != nil {
+			c.retryPhases(err)
+		}
+	}
+
+	return l1, l2, l3, err
+}
+
+// checkPhases verifies that the measured phases match the switched phases once the vehicle draws current.
+// Returns an error if more phases are measured than switched.
+func (c *OCPP) checkPhases(currents ...float64) error {
+	var active int
+	for _, i := range currents {
+		if i > minActiveCurrent {
+			active++
+		}
+	}
+
+	if active == 0 {
+		return nil
+	}
+
+	c.verifyPhases = false
+
+	switch {
+	case active > c.phases:
+		return fmt.Errorf("phase switching not confirmed: %dp requested, %dp measured", c.phases, active)
+	case active < c.phases:
+		// vehicle may not support 3p
+		c.log.DEBUG.Printf("phase switching: %dp requested, %dp measured", c.phases, active)
+	default:
+		c.log.DEBUG.Printf("phase switching confirmed: %dp", active)
+	}
+
+	return nil
+}
+
+// retryPhases sends the charging profile again until the measured phases change or retries are exhausted
+func (c *OCPP) retryPhases(err error) {
+	if c.phaseRetries >= maxPhaseRetries {
+		c.log.ERROR.Println(err)
+		return
+	}
+
+	c.phaseRetries++
+	c.log.WARN.Printf("%v, retry %d/%d", err, c.phaseRetries, maxPhaseRetries)
+
+	if err := c.updatePeriod(c.current, c.phases); err != nil {
+		c.log.ERROR.Printf("switch phases: %v", err)
+	}
+
+	c.verifyPhases = true
 }
 
 // Phases1p3p implements the api.PhaseSwitcher interface
 func (c *OCPP) phases1p3p(phases int) error {
 	c.phases = phases
+	c.verifyPhases = true
+	c.phaseRetries = 0
+
+	// switch phases during transaction
+	if enabled, _ := c.Enabled(); enabled {
+		return c.updatePeriod(c.current, c.phases)
+	}
 
-	// NOTE: this will currently _never_ do anything since
-	// loadpoint disabled the charger before switching so
-	// updatePeriod will short-circuit
-	return c.updatePeriod(c.current, c.phases)
+	// loadpoint disables the charger before switching phases,
+	// hence phases are applied to the next transaction by the default profile
+	return c.setTxDefaultProfile()
 }
 
-// // Identify implements the api.Identifier interface
-// Unless charger uses vehicle ID as idTag in authorize.req it is not possible to implement this in ocpp1.6
-// func (c *OCPP) Identify() (string, error) {
-// 	return "", errors.New("not implemented")
-// }
+// setTxDefaultProfile applies current and phases to transactions started without charging profile
+func (c *OCPP) setTxDefaultProfile() error {
+	current := c.current
+	if current == 0 {
+		current = defaultCurrent
+	}
+
+	c.log.TRACE.Printf("update default profile with phases: %d, current: %f", c.phases, current)
+
+	var err error
+	if c.cp.Protocol() == ocpp.ProtocolV201 {
+		err = c.setChargingProfile201(getTxDefaultChargingProfile201(current, c.phases))
+	} else {
+		err = c.setChargingProfile(c.connector, getTxDefaultChargingProfile(current, c.phases))
+	}
+
+	if err != nil {
+		err = fmt.Errorf("set default charging profile: %w", err)
+	}
+
+	return err
+}
+
+var _ api.Identifier = (*OCPP)(nil)
+
+// Identify implements the api.Identifier interface
+func (c *OCPP) Identify() (string, error) {
+	return c.conn.IdTag(), nil
+}
+
+var _ api.Reserver = (*OCPP)(nil)
+
+// Reserve implements the api.Reserver interface
+func (c *OCPP) Reserve(id string, expiry time.Time) error {
+	return c.conn.Reserve(id, expiry)
+}
+
+// CancelReservation implements the api.Reserver interface
+func (c *OCPP) CancelReservation() error {
+	return c.conn.CancelReservation()
+}
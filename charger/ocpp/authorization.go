@@ -0,0 +1,128 @@
+package ocpp
+
+import (
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/localauth"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+)
+
+// IdTag is an allow or deny list entry
+type IdTag struct {
+	IdTag  string
+	Status string    // Accepted (default) or Blocked
+	Expiry time.Time // optional
+	Parent string    // optional parent id tag
+}
+
+// AuthList authorizes id tags across all stations. An empty list accepts any id tag.
+type AuthList struct {
+	tags    map[string]IdTag
+	version int
+}
+
+var authList = new(AuthList)
+
+// NewAuthList creates an allow/deny list of id tags
+func NewAuthList(tags []IdTag) (*AuthList, error) {
+	res := &AuthList{
+		tags: make(map[string]IdTag),
+	}
+
+	var keys []string
+	for _, tag := range tags {
+		if tag.IdTag == "" {
+			return nil, fmt.Errorf("missing id tag")
+		}
+
+		if _, ok := res.tags[tag.IdTag]; ok {
+			return nil, fmt.Errorf("duplicate id tag: %s", tag.IdTag)
+		}
+
+		switch strings.ToLower(tag.Status) {
+		case "", strings.ToLower(string(types.AuthorizationStatusAccepted)):
+			tag.Status = string(types.AuthorizationStatusAccepted)
+		case strings.ToLower(string(types.AuthorizationStatusBlocked)):
+			tag.Status = string(types.AuthorizationStatusBlocked)
+		default:
+			return nil, fmt.Errorf("invalid status for id tag %s: %s", tag.IdTag, tag.Status)
+		}
+
+		res.tags[tag.IdTag] = tag
+		keys = append(keys, fmt.Sprintf("%s:%s:%d:%s", tag.IdTag, tag.Status, tag.Expiry.Unix(), tag.Parent))
+	}
+
+	// version is derived from the list's contents to detect changes across restarts
+	if len(keys) > 0 {
+		sort.Strings(keys)
+		res.version = int(crc32.ChecksumIEEE([]byte(strings.Join(keys, ","))) & 0x7fffffff)
+		if res.version == 0 {
+			res.version = 1
+		}
+	}
+
+	return res, nil
+}
+
+// Enabled returns true if id tags are restricted
+func (l *AuthList) Enabled() bool {
+	return len(l.tags) > 0
+}
+
+// Version returns the local list version
+func (l *AuthList) Version() int {
+	return l.version
+}
+
+// Authorize returns the id tag's authorization
+func (l *AuthList) Authorize(idTag string) *types.IdTagInfo {
+	if !l.Enabled() {
+		return &types.IdTagInfo{Status: types.AuthorizationStatusAccepted}
+	}
+
+	tag, ok := l.tags[idTag]
+	if !ok {
+		return &types.IdTagInfo{Status: types.AuthorizationStatusInvalid}
+	}
+
+	return l.idTagInfo(tag)
+}
+
+func (l *AuthList) idTagInfo(tag IdTag) *types.IdTagInfo {
+	res := &types.IdTagInfo{
+		Status:      types.AuthorizationStatus(tag.Status),
+		ParentIdTag: tag.Parent,
+	}
+
+	if !tag.Expiry.IsZero() {
+		res.ExpiryDate = types.NewDateTime(tag.Expiry)
+
+		if res.Status == types.AuthorizationStatusAccepted && time.Now().After(tag.Expiry) {
+			res.Status = types.AuthorizationStatusExpired
+		}
+	}
+
+	return res
+}
+
+// LocalList returns the list's entries for sending to stations
+func (l *AuthList) LocalList() []localauth.AuthorizationData {
+	res := make([]localauth.AuthorizationData, 0, len(l.tags))
+
+	for _, tag := range l.tags {
+		res = append(res, localauth.AuthorizationData{
+			IdTag:     tag.IdTag,
+			IdTagInfo: l.idTagInfo(tag),
+		})
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].IdTag < res[j].IdTag
+	})
+
+	return res
+}
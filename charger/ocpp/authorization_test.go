@@ -0,0 +1,177 @@
+package ocpp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/evcc-io/evcc/util"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthList(t *testing.T) {
+	l, err := NewAuthList(nil)
+	require.NoError(t, err)
+	assert.False(t, l.Enabled())
+	assert.Equal(t, 0, l.Version())
+	assert.Equal(t, types.AuthorizationStatusAccepted, l.Authorize("foo").Status)
+
+	_, err = NewAuthList([]IdTag{{IdTag: "a"}, {IdTag: "a"}})
+	assert.Error(t, err, "duplicate")
+
+	_, err = NewAuthList([]IdTag{{IdTag: "a", Status: "foo"}})
+	assert.Error(t, err, "invalid status")
+
+	tags := []IdTag{
+		{IdTag: "accepted", Parent: "garage"},
+		{IdTag: "blocked", Status: "blocked"},
+		{IdTag: "expired", Expiry: time.Now().Add(-time.Hour)},
+	}
+
+	l, err = NewAuthList(tags)
+	require.NoError(t, err)
+	assert.True(t, l.Enabled())
+	assert.NotZero(t, l.Version())
+
+	res := l.Authorize("accepted")
+	assert.Equal(t, types.AuthorizationStatusAccepted, res.Status)
+	assert.Equal(t, "garage", res.ParentIdTag)
+
+	assert.Equal(t, types.AuthorizationStatusBlocked, l.Authorize("blocked").Status)
+	assert.Equal(t, types.AuthorizationStatusExpired, l.Authorize("expired").Status)
+	assert.Equal(t, types.AuthorizationStatusInvalid, l.Authorize("unknown").Status)
+
+	list := l.LocalList()
+	require.Len(t, list, 3)
+	assert.Equal(t, "accepted", list[0].IdTag)
+	assert.Equal(t, types.AuthorizationStatusBlocked, list[1].IdTagInfo.Status)
+
+	// version is stable and changes with the list
+	l2, err := NewAuthList([]IdTag{tags[2], tags[1], tags[0]})
+	require.NoError(t, err)
+	assert.Equal(t, l.Version(), l2.Version())
+
+	l2, err = NewAuthList(tags[:2])
+	require.NoError(t, err)
+	assert.NotEqual(t, l.Version(), l2.Version())
+}
+
+func TestAuthorizeTransaction(t *testing.T) {
+	list, err := NewAuthList([]IdTag{{IdTag: "card"}})
+	require.NoError(t, err)
+
+	defer func(l *AuthList) { authList = l }(authList)
+	authList = list
+
+	l, err := NewLedger(nil)
+	require.NoError(t, err)
+
+	cp := NewChargePoint(util.NewLogger("foo"), "cp1", time.Minute)
+	cp.ledger = l
+
+	conn, err := cp.RegisterConnector(1)
+	require.NoError(t, err)
+	conn.SetRemoteIdTag("evcc")
+
+	for _, tc := range []struct {
+		idTag  string
+		remote bool
+		status types.AuthorizationStatus
+	}{
+		{"card", false, types.AuthorizationStatusAccepted},
+		{"evcc", false, types.AuthorizationStatusInvalid},
+		{"evcc", true, types.AuthorizationStatusAccepted},
+		{"foreign", false, types.AuthorizationStatusInvalid},
+	} {
+		// remote id tag is only accepted for transactions started by evcc
+		if tc.remote {
+			conn.RemoteStart(tc.idTag)
+		}
+
+		auth, err := cp.Authorize(&core.AuthorizeRequest{IdTag: tc.idTag})
+		require.NoError(t, err)
+		assert.Equal(t, tc.status, auth.IdTagInfo.Status, tc.idTag)
+
+		start, err := cp.StartTransaction(&core.StartTransactionRequest{
+			ConnectorId: 1,
+			IdTag:       tc.idTag,
+			Timestamp:   types.NewDateTime(time.Now()),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, tc.status, start.IdTagInfo.Status, tc.idTag)
+
+		// rejected transactions are finished right away
+		txn, err := l.Transaction(start.TransactionId)
+		require.NoError(t, err)
+
+		if tc.status != types.AuthorizationStatusAccepted {
+			assert.Zero(t, conn.TransactionID(), tc.idTag)
+			assert.False(t, txn.Finished.IsZero(), tc.idTag)
+			continue
+		}
+
+		assert.Equal(t, start.TransactionId, conn.TransactionID(), tc.idTag)
+		assert.True(t, txn.Finished.IsZero(), tc.idTag)
+
+		stop, err := cp.StopTransaction(&core.StopTransactionRequest{
+			TransactionId: start.TransactionId,
+			IdTag:         tc.idTag,
+			Timestamp:     types.NewDateTime(time.Now()),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, tc.status, stop.IdTagInfo.Status, tc.idTag)
+	}
+
+	// remote start is consumed by the transaction
+	start, err := cp.StartTransaction(&core.StartTransactionRequest{
+		ConnectorId: 1,
+		IdTag:       "evcc",
+		Timestamp:   types.NewDateTime(time.Now()),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, types.AuthorizationStatusInvalid, start.IdTagInfo.Status)
+}
+
+func TestIdentify(t *testing.T) {
+	l, err := NewLedger(nil)
+	require.NoError(t, err)
+
+	cp := NewChargePoint(util.NewLogger("foo"), "cp1", time.Minute)
+	cp.ledger = l
+
+	conn, err := cp.RegisterConnector(1)
+	require.NoError(t, err)
+	conn.SetRemoteIdTag("evcc")
+
+	_, err = cp.Authorize(&core.AuthorizeRequest{IdTag: "card"})
+	require.NoError(t, err)
+	assert.Equal(t, "card", conn.IdTag())
+
+	// remote starts don't identify
+	conn.RemoteStart("evcc")
+	_, err = cp.StartTransaction(&core.StartTransactionRequest{
+		ConnectorId: 1,
+		IdTag:       "evcc",
+		Timestamp:   types.NewDateTime(time.Now()),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "card", conn.IdTag())
+
+	for _, tc := range []struct {
+		status core.ChargePointStatus
+		idTag  string
+	}{
+		{core.ChargePointStatusFinishing, "card"},
+		{core.ChargePointStatusAvailable, ""},
+	} {
+		_, err = cp.StatusNotification(&core.StatusNotificationRequest{
+			ConnectorId: 1,
+			ErrorCode:   core.NoError,
+			Status:      tc.status,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, tc.idTag, conn.IdTag(), tc.status)
+	}
+}
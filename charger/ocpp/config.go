@@ -0,0 +1,172 @@
+package ocpp
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// security profiles
+const (
+	ProfileUnsecured     = iota // no authentication
+	ProfileBasicAuth            // basic auth without TLS
+	ProfileTLSBasicAuth         // basic auth with TLS server certificate
+	ProfileTLSClientCert        // TLS with client certificates
+)
+
+// Config is the central system configuration
+type Config struct {
+	Port        int
+	Path        string
+	Profile     int               // security profile
+	Stations    map[string]string // allowed station ids and their basic auth passwords
+	Certificate struct {
+		Public, Private string // server certificate and key (PEM)
+	}
+	ClientCA    string  // client certificate authority (PEM)
+	IdTags      []IdTag // id tag allow/deny list shared by all stations
+	URL         string  // evcc url for firmware downloads and diagnostics uploads by stations
+	Firmware    string  // firmware images directory
+	Diagnostics string  // diagnostics uploads directory
+	Record      string  // file recording all OCPP-J frames as JSON lines
+}
+
+var config = Config{
+	Port: 8887,
+	Path: "/",
+}
+
+// Configure sets the central system configuration. It must be called before the central system is started.
+func Configure(conf Config) error {
+	if instance != nil {
+		return errors.New("central system already started")
+	}
+
+	if conf.Port == 0 {
+		conf.Port = config.Port
+	}
+
+	conf.Path = "/" + strings.Trim(conf.Path, "/")
+	conf.URL = strings.TrimSuffix(conf.URL, "/")
+
+	if err := conf.validate(); err != nil {
+		return err
+	}
+
+	list, err := NewAuthList(conf.IdTags)
+	if err != nil {
+		return err
+	}
+
+	config = conf
+	authList = list
+
+	return nil
+}
+
+func (c Config) validate() error {
+	switch c.Profile {
+	case ProfileUnsecured, ProfileTLSClientCert:
+	case ProfileBasicAuth, ProfileTLSBasicAuth:
+		if len(c.Stations) == 0 {
+			return errors.New("basic auth requires stations")
+		}
+
+		for id, password := range c.Stations {
+			if password == "" {
+				return fmt.Errorf("missing password: %s", id)
+			}
+		}
+	default:
+		return fmt.Errorf("invalid security profile: %d", c.Profile)
+	}
+
+	if c.Profile >= ProfileTLSBasicAuth && (c.Certificate.Public == "" || c.Certificate.Private == "") {
+		return errors.New("tls requires certificate")
+	}
+
+	if c.Profile == ProfileTLSClientCert && c.ClientCA == "" {
+		return errors.New("client certificates require client ca")
+	}
+
+	_, err := c.tlsConfig()
+
+	return err
+}
+
+// tlsConfig returns the server's TLS configuration or nil if TLS is not enabled
+func (c Config) tlsConfig() (*tls.Config, error) {
+	if c.Profile < ProfileTLSBasicAuth {
+		return nil, nil
+	}
+
+	certificate, err := tls.X509KeyPair([]byte(c.Certificate.Public), []byte(c.Certificate.Private))
+	if err != nil {
+		return nil, fmt.Errorf("certificate: %w", err)
+	}
+
+	res := &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if c.Profile == ProfileTLSClientCert {
+		res.ClientCAs = x509.NewCertPool()
+		if !res.ClientCAs.AppendCertsFromPEM([]byte(c.ClientCA)) {
+			return nil, errors.New("client ca: no certificates found")
+		}
+
+		res.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return res, nil
+}
+
+// stationID returns the station id from the request path
+func (c Config) stationID(path string) (string, bool) {
+	prefix := strings.TrimSuffix(c.Path, "/") + "/"
+
+	id := strings.TrimPrefix(path, prefix)
+	if id == path || id == "" || strings.Contains(id, "/") {
+		return "", false
+	}
+
+	return id, true
+}
+
+// authorize checks the station's credentials according to the security profile
+func (c Config) authorize(id string, r *http.Request) error {
+	if len(c.Stations) > 0 {
+		if _, ok := c.Stations[id]; !ok {
+			return fmt.Errorf("unknown station: %s", id)
+		}
+	}
+
+	switch c.Profile {
+	case ProfileBasicAuth, ProfileTLSBasicAuth:
+		user, password, ok := r.BasicAuth()
+		if !ok {
+			return errors.New("missing credentials")
+		}
+
+		if user != id || subtle.ConstantTimeCompare([]byte(password), []byte(c.Stations[id])) != 1 {
+			return errors.New("invalid credentials")
+		}
+
+	case ProfileTLSClientCert:
+		if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+			return errors.New("missing client certificate")
+		}
+
+		// the certificate's common name identifies the station
+		if cn := r.TLS.PeerCertificates[0].Subject.CommonName; cn != id {
+			return fmt.Errorf("client certificate mismatch: %s", cn)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package ocpp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, Config{}.validate())
+	assert.Error(t, Config{Profile: 4}.validate())
+
+	assert.Error(t, Config{Profile: ProfileBasicAuth}.validate(), "missing stations")
+	assert.Error(t, Config{Profile: ProfileBasicAuth, Stations: map[string]string{"cp1": ""}}.validate(), "missing password")
+	assert.NoError(t, Config{Profile: ProfileBasicAuth, Stations: map[string]string{"cp1": "secret"}}.validate())
+
+	assert.Error(t, Config{Profile: ProfileTLSBasicAuth, Stations: map[string]string{"cp1": "secret"}}.validate(), "missing certificate")
+	assert.Error(t, Config{Profile: ProfileTLSClientCert}.validate(), "missing certificate")
+}
+
+func TestConfigStationID(t *testing.T) {
+	for _, tc := range []struct {
+		path, uri, id string
+	}{
+		{"/", "/cp1", "cp1"},
+		{"/", "/", ""},
+		{"/", "/ocpp/cp1", ""},
+		{"/ocpp", "/ocpp/cp1", "cp1"},
+		{"/ocpp", "/cp1", ""},
+		{"/ocpp", "/ocppcp1", ""},
+	} {
+		id, ok := Config{Path: tc.path}.stationID(tc.uri)
+		assert.Equal(t, tc.id, id, tc)
+		assert.Equal(t, tc.id != "", ok, tc)
+	}
+}
+
+func TestConfigAuthorize(t *testing.T) {
+	conf := Config{
+		Profile:  ProfileBasicAuth,
+		Stations: map[string]string{"cp1": "secret"},
+	}
+
+	r := httptest.NewRequest("GET", "/cp1", nil)
+	assert.Error(t, conf.authorize("cp1", r), "missing credentials")
+
+	r.SetBasicAuth("cp1", "wrong")
+	assert.Error(t, conf.authorize("cp1", r), "invalid password")
+
+	r.SetBasicAuth("cp2", "secret")
+	assert.Error(t, conf.authorize("cp1", r), "invalid user")
+
+	r.SetBasicAuth("cp1", "secret")
+	assert.NoError(t, conf.authorize("cp1", r))
+	assert.Error(t, conf.authorize("cp2", r), "unknown station")
+
+	// unsecured with allow list
+	conf = Config{Stations: map[string]string{"cp1": ""}}
+	assert.NoError(t, conf.authorize("cp1", httptest.NewRequest("GET", "/cp1", nil)))
+	assert.Error(t, conf.authorize("cp2", httptest.NewRequest("GET", "/cp2", nil)))
+
+	// client certificates
+	conf = Config{Profile: ProfileTLSClientCert}
+	r = httptest.NewRequest("GET", "/cp1", nil)
+	assert.Error(t, conf.authorize("cp1", r), "missing certificate")
+
+	r.TLS = &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "cp1"}}},
+	}
+	assert.NoError(t, conf.authorize("cp1", r))
+	assert.Error(t, conf.authorize("cp2", r), "certificate mismatch")
+}
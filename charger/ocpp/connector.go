@@ -0,0 +1,338 @@
+package ocpp
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/evcc-io/evcc/api"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/availability"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/transactions"
+)
+
+// Connector tracks status, measurements and transactions of a single station connector (OCPP 2.0.1: EVSE).
+// Its state is guarded by the charge point's lock.
+type Connector struct {
+	cp *CP
+	id int
+
+	statusC chan struct{}
+	status  *core.StatusNotificationRequest
+
+	meterUpdated time.Time
+	measurements map[string]types.SampledValue
+
+	txnId       int
+	remoteIdTag string
+	remoteStart *remoteStart // remote start requested by evcc
+	remoteTxnId int          // transaction started remotely by evcc
+	idTag       string       // id tag identifying the vehicle
+	reservation *Reservation
+
+	// OCPP 2.0.1
+	connectorStatus availability.ConnectorStatus
+	chargingState   transactions.ChargingState
+	transactionId   string
+}
+
+// remoteStart is a remote start requested by evcc that the station has not started yet
+type remoteStart struct {
+	idTag   string
+	expires time.Time
+}
+
+func newConnector(cp *CP, id int) *Connector {
+	return &Connector{
+		cp:           cp,
+		id:           id,
+		statusC:      make(chan struct{}),
+		measurements: make(map[string]types.SampledValue),
+	}
+}
+
+// ID returns the connector id
+func (conn *Connector) ID() int {
+	return conn.id
+}
+
+// ChargePoint returns the connector's station
+func (conn *Connector) ChargePoint() *CP {
+	return conn.cp
+}
+
+// SetRemoteIdTag sets the id tag used by the central system for remote starts
+func (conn *Connector) SetRemoteIdTag(idTag string) {
+	conn.cp.mu.Lock()
+	defer conn.cp.mu.Unlock()
+
+	conn.remoteIdTag = idTag
+}
+
+// RemoteStart marks the remote start about to be requested for the id tag.
+// The id tag is accepted for the connector's next transaction only.
+func (conn *Connector) RemoteStart(idTag string) {
+	conn.cp.mu.Lock()
+	defer conn.cp.mu.Unlock()
+
+	conn.remoteStart = &remoteStart{idTag: idTag, expires: time.Now().Add(remoteStartExpiry)}
+}
+
+// remoteStartPending returns true if evcc requested a remote start for the id tag. Lock must be held.
+func (conn *Connector) remoteStartPending(idTag string) bool {
+	return conn.remoteStart != nil && conn.remoteStart.idTag == idTag && time.Now().Before(conn.remoteStart.expires)
+}
+
+func (conn *Connector) Initialized(timeout time.Duration) bool {
+	conn.cp.log.DEBUG.Printf("waiting for connector %d status: %v", conn.id, timeout)
+
+	// trigger status
+	time.AfterFunc(5*time.Second, func() {
+		select {
+		case <-conn.statusC:
+			return
+		default:
+			Instance().TriggerMessageRequest(conn.cp.ID(), core.StatusNotificationFeatureName)
+		}
+	})
+
+	// wait for status
+	select {
+	case <-conn.statusC:
+		conn.cp.update()
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// WatchDog triggers meter values messages if older than timeout.
+// Must be wrapped in a goroutine.
+func (conn *Connector) WatchDog(timeout time.Duration) {
+	for ; true; <-time.NewTicker(timeout).C {
+		conn.cp.mu.Lock()
+		update := conn.txnId != 0 && time.Since(conn.meterUpdated) > timeout
+		conn.cp.mu.Unlock()
+
+		if update {
+			Instance().TriggerMessageRequest(conn.cp.ID(), core.MeterValuesFeatureName)
+		}
+	}
+}
+
+// setStatus updates the connector status unless outdated. Lock must be held.
+func (conn *Connector) setStatus(request *core.StatusNotificationRequest) {
+	var prev core.ChargePointStatus
+	if conn.status != nil {
+		prev = conn.status.Status
+	}
+
+	if conn.status == nil {
+		conn.status = request
+		close(conn.statusC) // signal initial status received
+	} else if request.Timestamp == nil || conn.timestampValid(request.Timestamp.Time) {
+		conn.status = request
+	} else {
+		conn.cp.log.TRACE.Printf("ignoring status: %s < %s", request.Timestamp.Time, conn.status.Timestamp)
+	}
+
+	// vehicle disconnected
+	if conn.status.Status == core.ChargePointStatusAvailable {
+		conn.idTag = ""
+	}
+
+	conn.updateReservation(prev)
+}
+
+// timestampValid returns false if status timestamps are outdated
+func (conn *Connector) timestampValid(t time.Time) bool {
+	// reject if expired
+	if time.Since(t) > messageExpiry {
+		return false
+	}
+
+	// assume having a timestamp is better than not
+	if conn.status.Timestamp == nil {
+		return true
+	}
+
+	// reject older values than we already have
+	return !t.Before(conn.status.Timestamp.Time)
+}
+
+// setMeterValues records the connector's measurements unless outdated. Lock must be held.
+func (conn *Connector) setMeterValues(meterValues []types.MeterValue) {
+	for _, meterValue := range meterValues {
+		// ignore old meter value requests
+		if meterValue.Timestamp.Time.After(conn.meterUpdated) {
+			for _, sample := range meterValue.SampledValue {
+				conn.measurements[getSampleKey(sample)] = sample
+				conn.meterUpdated = time.Now()
+			}
+		}
+	}
+}
+
+// TransactionID returns the current transaction id
+func (conn *Connector) TransactionID() int {
+	conn.cp.mu.Lock()
+	defer conn.cp.mu.Unlock()
+	return conn.txnId
+}
+
+// IdTag returns the id tag presented by the user until the vehicle is disconnected
+func (conn *Connector) IdTag() string {
+	conn.cp.mu.Lock()
+	defer conn.cp.mu.Unlock()
+	return conn.idTag
+}
+
+// identify records the id tag unless it's a remote start id tag. Lock must be held.
+func (conn *Connector) identify(idTag string) {
+	if idTag != "" && !conn.cp.isRemoteIdTag(idTag) {
+		conn.idTag = idTag
+	}
+}
+
+// StationTransactionID returns the current transaction id as assigned by the charging station (OCPP 2.0.1)
+func (conn *Connector) StationTransactionID() string {
+	conn.cp.mu.Lock()
+	defer conn.cp.mu.Unlock()
+	return conn.transactionId
+}
+
+// RestoreTransaction recognizes the connector's transaction still running after restart
+func (conn *Connector) RestoreTransaction() error {
+	conn.cp.mu.Lock()
+	defer conn.cp.mu.Unlock()
+
+	if conn.txnId != 0 || conn.status == nil {
+		return nil
+	}
+
+	// transactions are only running while the vehicle is charging or suspended
+	switch conn.status.Status {
+	case core.ChargePointStatusCharging, core.ChargePointStatusSuspendedEV, core.ChargePointStatusSuspendedEVSE:
+	default:
+		return nil
+	}
+
+	txn, err := conn.cp.ledger.Active(conn.cp.id, conn.id)
+	if err != nil || txn == nil {
+		return err
+	}
+
+	conn.cp.log.DEBUG.Printf("restored transaction: %d", txn.ID)
+
+	conn.txnId = txn.ID
+	conn.transactionId = txn.StationTxID
+	conn.identify(txn.IdTag)
+
+	return nil
+}
+
+func (conn *Connector) Status() (api.ChargeStatus, error) {
+	conn.cp.mu.Lock()
+	defer conn.cp.mu.Unlock()
+
+	res := api.StatusNone
+
+	if time.Since(conn.cp.updated) > conn.cp.timeout {
+		return res, api.ErrTimeout
+	}
+
+	if conn.status == nil {
+		return res, api.ErrNotAvailable
+	}
+
+	if conn.status.ErrorCode != core.NoError {
+		return res, fmt.Errorf("%s: %s", conn.status.ErrorCode, conn.status.Info)
+	}
+
+	switch conn.status.Status {
+	case core.ChargePointStatusAvailable, // "Available"
+		core.ChargePointStatusUnavailable, // "Unavailable"
+		core.ChargePointStatusReserved:    // "Reserved"
+		res = api.StatusA
+	case
+		core.ChargePointStatusPreparing,     // "Preparing"
+		core.ChargePointStatusSuspendedEVSE, // "SuspendedEVSE"
+		core.ChargePointStatusSuspendedEV,   // "SuspendedEV"
+		core.ChargePointStatusFinishing:     // "Finishing"
+		res = api.StatusB
+	case core.ChargePointStatusCharging: // "Charging"
+		res = api.StatusC
+	case core.ChargePointStatusFaulted: // "Faulted"
+		return api.StatusF, fmt.Errorf("chargepoint status: %s", conn.status.ErrorCode)
+	default:
+		return api.StatusNone, fmt.Errorf("invalid chargepoint status: %s", conn.status.Status)
+	}
+
+	return res, nil
+}
+
+var _ api.Meter = (*Connector)(nil)
+
+func (conn *Connector) CurrentPower() (float64, error) {
+	conn.cp.mu.Lock()
+	defer conn.cp.mu.Unlock()
+
+	if conn.cp.timeout > 0 && time.Since(conn.meterUpdated) > conn.cp.timeout {
+		return 0, api.ErrNotAvailable
+	}
+
+	if m, ok := conn.measurements[string(types.MeasurandPowerActiveImport)]; ok {
+		f, err := strconv.ParseFloat(m.Value, 64)
+		return scale(f, m.Unit), err
+	}
+
+	return 0, api.ErrNotAvailable
+}
+
+var _ api.MeterEnergy = (*Connector)(nil)
+
+func (conn *Connector) TotalEnergy() (float64, error) {
+	conn.cp.mu.Lock()
+	defer conn.cp.mu.Unlock()
+
+	if conn.cp.timeout > 0 && time.Since(conn.meterUpdated) > conn.cp.timeout {
+		return 0, api.ErrNotAvailable
+	}
+
+	if m, ok := conn.measurements[string(types.MeasurandEnergyActiveImportRegister)]; ok {
+		f, err := strconv.ParseFloat(m.Value, 64)
+		return scale(f, m.Unit) / 1e3, err
+	}
+
+	return 0, api.ErrNotAvailable
+}
+
+var _ api.PhaseCurrents = (*Connector)(nil)
+
+func (conn *Connector) Currents() (float64, float64, float64, error) {
+	conn.cp.mu.Lock()
+	defer conn.cp.mu.Unlock()
+
+	if conn.cp.timeout > 0 && time.Since(conn.meterUpdated) > conn.cp.timeout {
+		return 0, 0, 0, api.ErrNotAvailable
+	}
+
+	currents := make([]float64, 0, 3)
+
+	for phase := 1; phase <= 3; phase++ {
+		m, ok := conn.measurements[getKeyCurrentPhase(phase)]
+		if !ok {
+			return 0, 0, 0, api.ErrNotAvailable
+		}
+
+		f, err := strconv.ParseFloat(m.Value, 64)
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid current for phase %d: %w", phase, err)
+		}
+
+		currents = append(currents, scale(f, m.Unit))
+	}
+
+	return currents[0], currents[1], currents[2], nil
+}
@@ -0,0 +1,186 @@
+package ocpp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/evcc-io/evcc/api"
+	"github.com/evcc-io/evcc/util"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/availability"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/transactions"
+	types201 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestConnectors(t *testing.T, ids ...int) (*CP, []*Connector) {
+	l, err := NewLedger(nil)
+	require.NoError(t, err)
+
+	cp := NewChargePoint(util.NewLogger("foo"), "cp1", time.Minute)
+	cp.ledger = l
+	cp.update()
+
+	var res []*Connector
+	for _, id := range ids {
+		conn, err := cp.RegisterConnector(id)
+		require.NoError(t, err)
+		res = append(res, conn)
+	}
+
+	return cp, res
+}
+
+func TestConnectors(t *testing.T) {
+	cp, conns := newTestConnectors(t, 1, 2)
+
+	_, err := cp.RegisterConnector(2)
+	assert.Error(t, err, "duplicate connector")
+
+	for id, status := range map[int]core.ChargePointStatus{
+		1: core.ChargePointStatusCharging,
+		2: core.ChargePointStatusAvailable,
+	} {
+		_, err := cp.StatusNotification(&core.StatusNotificationRequest{ConnectorId: id, ErrorCode: core.NoError, Status: status})
+		require.NoError(t, err)
+	}
+
+	// station status doesn't affect connectors
+	_, err = cp.StatusNotification(&core.StatusNotificationRequest{ConnectorId: 0, ErrorCode: core.NoError, Status: core.ChargePointStatusUnavailable})
+	require.NoError(t, err)
+
+	for i, exp := range []api.ChargeStatus{api.StatusC, api.StatusA} {
+		status, err := conns[i].Status()
+		require.NoError(t, err)
+		assert.Equal(t, exp, status, i)
+	}
+
+	start, err := cp.StartTransaction(&core.StartTransactionRequest{
+		ConnectorId: 1,
+		IdTag:       "card",
+		Timestamp:   types.NewDateTime(time.Now()),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, start.TransactionId, conns[0].TransactionID())
+	assert.Zero(t, conns[1].TransactionID())
+
+	_, err = cp.MeterValues(&core.MeterValuesRequest{
+		ConnectorId: 2,
+		MeterValue: []types.MeterValue{{
+			Timestamp:    types.NewDateTime(time.Now()),
+			SampledValue: []types.SampledValue{{Measurand: types.MeasurandPowerActiveImport, Value: "1000"}},
+		}},
+	})
+	require.NoError(t, err)
+
+	_, err = conns[0].CurrentPower()
+	assert.ErrorIs(t, err, api.ErrNotAvailable)
+
+	power, err := conns[1].CurrentPower()
+	require.NoError(t, err)
+	assert.Equal(t, 1000.0, power)
+
+	// stopping doesn't affect the other connector's transaction
+	start2, err := cp.StartTransaction(&core.StartTransactionRequest{
+		ConnectorId: 2,
+		Timestamp:   types.NewDateTime(time.Now()),
+	})
+	require.NoError(t, err)
+
+	_, err = cp.StopTransaction(&core.StopTransactionRequest{
+		TransactionId: start.TransactionId,
+		Timestamp:     types.NewDateTime(time.Now()),
+	})
+	require.NoError(t, err)
+	assert.Zero(t, conns[0].TransactionID())
+	assert.Equal(t, start2.TransactionId, conns[1].TransactionID())
+}
+
+func TestConnectorStationMeterValues(t *testing.T) {
+	cp, conns := newTestConnectors(t, 1)
+
+	// single connector stations may report on connector 0
+	_, err := cp.MeterValues(&core.MeterValuesRequest{
+		ConnectorId: 0,
+		MeterValue: []types.MeterValue{{
+			Timestamp:    types.NewDateTime(time.Now()),
+			SampledValue: []types.SampledValue{{Measurand: types.MeasurandEnergyActiveImportRegister, Value: "2000"}},
+		}},
+	})
+	require.NoError(t, err)
+
+	energy, err := conns[0].TotalEnergy()
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, energy)
+}
+
+func TestConnectorStationStatus(t *testing.T) {
+	cp, conns := newTestConnectors(t, 1)
+
+	// single connector stations may report on connector 0
+	_, err := cp.StatusNotification(&core.StatusNotificationRequest{ConnectorId: 0, ErrorCode: core.NoError, Status: core.ChargePointStatusCharging})
+	require.NoError(t, err)
+
+	status, err := conns[0].Status()
+	require.NoError(t, err)
+	assert.Equal(t, api.StatusC, status)
+
+	// unknown connectors are ignored
+	_, err = cp.StatusNotification(&core.StatusNotificationRequest{ConnectorId: 2, ErrorCode: core.NoError, Status: core.ChargePointStatusAvailable})
+	require.NoError(t, err)
+
+	status, err = conns[0].Status()
+	require.NoError(t, err)
+	assert.Equal(t, api.StatusC, status)
+}
+
+func TestConnectors201(t *testing.T) {
+	cp, conns := newTestConnectors(t, 1, 2)
+
+	for _, id := range []int{1, 2} {
+		_, err := cp.EvseStatusNotification(&availability.StatusNotificationRequest{
+			Timestamp:       types201.NewDateTime(time.Now()),
+			ConnectorStatus: availability.ConnectorStatusOccupied,
+			EvseID:          id,
+			ConnectorID:     1,
+		})
+		require.NoError(t, err)
+	}
+
+	_, err := cp.TransactionEvent(&transactions.TransactionEventRequest{
+		EventType:       transactions.TransactionEventStarted,
+		Timestamp:       types201.NewDateTime(time.Now()),
+		TriggerReason:   transactions.TriggerReasonChargingStateChanged,
+		TransactionInfo: transactions.Transaction{TransactionID: "tx2", ChargingState: transactions.ChargingStateCharging},
+		Evse:            &types201.EVSE{ID: 2},
+	})
+	require.NoError(t, err)
+
+	// evse is omitted after the first event
+	_, err = cp.TransactionEvent(&transactions.TransactionEventRequest{
+		EventType:       transactions.TransactionEventUpdated,
+		Timestamp:       types201.NewDateTime(time.Now()),
+		TriggerReason:   transactions.TriggerReasonMeterValuePeriodic,
+		TransactionInfo: transactions.Transaction{TransactionID: "tx2"},
+		MeterValue: []types201.MeterValue{{
+			Timestamp:    *types201.NewDateTime(time.Now()),
+			SampledValue: []types201.SampledValue{{Measurand: types201.MeasurandPowerActiveImport, Value: 3000}},
+		}},
+	})
+	require.NoError(t, err)
+
+	assert.Empty(t, conns[0].StationTransactionID())
+	assert.Equal(t, "tx2", conns[1].StationTransactionID())
+
+	for i, exp := range []api.ChargeStatus{api.StatusB, api.StatusC} {
+		status, err := conns[i].Status()
+		require.NoError(t, err)
+		assert.Equal(t, exp, status, i)
+	}
+
+	power, err := conns[1].CurrentPower()
+	require.NoError(t, err)
+	assert.Equal(t, 3000.0, power)
+}
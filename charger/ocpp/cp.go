@@ -7,10 +7,16 @@ import (
 	"sync"
 	"time"
 
-	"github.com/evcc-io/evcc/api"
 	"github.com/evcc-io/evcc/util"
 	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
 	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	types201 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// Protocols negotiated by websocket subprotocol
+const (
+	ProtocolV16  = types.V16Subprotocol
+	ProtocolV201 = types201.V201Subprotocol
 )
 
 const (
@@ -37,28 +43,26 @@ type CP struct {
 	log  *util.Logger
 	once sync.Once
 
-	id string
-
-	connectC, statusC chan struct{}
-	updated           time.Time
-	status            *core.StatusNotificationRequest
+	id        string
+	protocol  string
+	connected bool
+	boot      *core.BootNotificationRequest
 
-	timeout      time.Duration
-	meterUpdated time.Time
-	measurements map[string]types.SampledValue
+	connectC chan struct{}
+	updated  time.Time
+	timeout  time.Duration
 
-	txnCount int // change initial value to the last known global transaction. Needs persistence
-	txnId    int
+	ledger     *Ledger
+	connectors map[int]*Connector
 }
 
 func NewChargePoint(log *util.Logger, id string, timeout time.Duration) *CP {
 	return &CP{
-		log:          log,
-		id:           id,
-		connectC:     make(chan struct{}),
-		statusC:      make(chan struct{}),
-		measurements: make(map[string]types.SampledValue),
-		timeout:      timeout,
+		log:        log,
+		id:         id,
+		connectC:   make(chan struct{}),
+		timeout:    timeout,
+		connectors: make(map[int]*Connector),
 	}
 }
 
@@ -80,133 +84,98 @@ func (cp *CP) RegisterID(id string) {
 	cp.id = id
 }
 
-func (cp *CP) Connect() {
+// RegisterConnector adds the connector whose state is tracked separately from the station's other connectors
+func (cp *CP) RegisterConnector(id int) (*Connector, error) {
 	cp.mu.Lock()
 	defer cp.mu.Unlock()
 
-	cp.once.Do(func() {
-		close(cp.connectC)
-	})
-}
+	if _, ok := cp.connectors[id]; ok {
+		return nil, fmt.Errorf("connector already registered: %d", id)
+	}
 
-func (cp *CP) HasConnected() <-chan struct{} {
-	return cp.connectC
-}
+	conn := newConnector(cp, id)
+	cp.connectors[id] = conn
 
-func (cp *CP) Initialized(timeout time.Duration) bool {
-	cp.log.DEBUG.Printf("waiting for chargepoint status: %v", timeout)
+	return conn, nil
+}
 
-	// trigger status
-	time.AfterFunc(5*time.Second, func() {
-		select {
-		case <-cp.statusC:
-			return
-		default:
-			Instance().TriggerMessageRequest(cp.ID(), core.StatusNotificationFeatureName)
-		}
-	})
+// connector returns the registered connector or nil. Station-wide messages for connector 0
+// are attributed to the connector if it's the station's only one. Lock must be held.
+func (cp *CP) connector(id int) *Connector {
+	if conn, ok := cp.connectors[id]; ok || id != 0 || len(cp.connectors) != 1 {
+		return conn
+	}
 
-	// wait for status
-	select {
-	case <-cp.statusC:
-		cp.update()
-		return true
-	case <-time.After(timeout):
-		return false
+	for _, conn := range cp.connectors {
+		return conn
 	}
-}
 
-// WatchDog triggers meter values messages if older than timeout.
-// Must be wrapped in a goroutine.
-func (cp *CP) WatchDog(timeout time.Duration) {
-	for ; true; <-time.NewTicker(timeout).C {
-		cp.mu.Lock()
-		update := cp.txnId != 0 && time.Since(cp.meterUpdated) > timeout
-		cp.mu.Unlock()
+	return nil
+}
 
-		if update {
-			Instance().TriggerMessageRequest(cp.ID(), core.MeterValuesFeatureName)
+// transactionConnector returns the connector running the transaction or nil. Lock must be held.
+func (cp *CP) transactionConnector(txnId int) *Connector {
+	for _, conn := range cp.connectors {
+		if conn.txnId == txnId {
+			return conn
 		}
 	}
-}
 
-// TransactionID returns the current transaction id
-func (cp *CP) TransactionID() int {
-	cp.mu.Lock()
-	defer cp.mu.Unlock()
-	return cp.txnId
+	return nil
 }
 
-func (cp *CP) Status() (api.ChargeStatus, error) {
+func (cp *CP) Connect(protocol string) {
 	cp.mu.Lock()
 	defer cp.mu.Unlock()
 
-	res := api.StatusNone
-
-	if time.Since(cp.updated) > cp.timeout {
-		return res, api.ErrTimeout
-	}
+	cp.protocol = protocol
+	cp.connected = true
 
-	if cp.status.ErrorCode != core.NoError {
-		return res, fmt.Errorf("%s: %s", cp.status.ErrorCode, cp.status.Info)
-	}
-
-	switch cp.status.Status {
-	case core.ChargePointStatusAvailable, // "Available"
-		core.ChargePointStatusUnavailable: // "Unavailable"
-		res = api.StatusA
-	case
-		core.ChargePointStatusPreparing,     // "Preparing"
-		core.ChargePointStatusSuspendedEVSE, // "SuspendedEVSE"
-		core.ChargePointStatusSuspendedEV,   // "SuspendedEV"
-		core.ChargePointStatusFinishing:     // "Finishing"
-		res = api.StatusB
-	case core.ChargePointStatusCharging: // "Charging"
-		res = api.StatusC
-	case core.ChargePointStatusReserved, // "Reserved"
-		core.ChargePointStatusFaulted: // "Faulted"
-		return api.StatusF, fmt.Errorf("chargepoint status: %s", cp.status.ErrorCode)
-	default:
-		return api.StatusNone, fmt.Errorf("invalid chargepoint status: %s", cp.status.Status)
-	}
-
-	return res, nil
+	cp.once.Do(func() {
+		close(cp.connectC)
+	})
 }
 
-var _ api.Meter = (*CP)(nil)
-
-func (cp *CP) CurrentPower() (float64, error) {
+// Disconnect marks the station as offline
+func (cp *CP) Disconnect() {
 	cp.mu.Lock()
 	defer cp.mu.Unlock()
 
-	if cp.timeout > 0 && time.Since(cp.meterUpdated) > cp.timeout {
-		return 0, api.ErrNotAvailable
-	}
-
-	if m, ok := cp.measurements[string(types.MeasurandPowerActiveImport)]; ok {
-		f, err := strconv.ParseFloat(m.Value, 64)
-		return scale(f, m.Unit), err
-	}
-
-	return 0, api.ErrNotAvailable
+	cp.connected = false
 }
 
-var _ api.MeterEnergy = (*CP)(nil)
-
-func (cp *CP) TotalEnergy() (float64, error) {
+// Protocol returns the OCPP protocol version negotiated on connect
+func (cp *CP) Protocol() string {
 	cp.mu.Lock()
 	defer cp.mu.Unlock()
 
-	if cp.timeout > 0 && time.Since(cp.meterUpdated) > cp.timeout {
-		return 0, api.ErrNotAvailable
+	return cp.protocol
+}
+
+func (cp *CP) HasConnected() <-chan struct{} {
+	return cp.connectC
+}
+
+// isRemoteIdTag returns true if the id tag is used by the central system for remote starts on any connector. Lock must be held.
+func (cp *CP) isRemoteIdTag(idTag string) bool {
+	for _, conn := range cp.connectors {
+		if idTag != "" && idTag == conn.remoteIdTag {
+			return true
+		}
 	}
 
-	if m, ok := cp.measurements[string(types.MeasurandEnergyActiveImportRegister)]; ok {
-		f, err := strconv.ParseFloat(m.Value, 64)
-		return scale(f, m.Unit) / 1e3, err
+	return false
+}
+
+// remoteStartPending returns true if evcc requested a remote start for the id tag on any connector. Lock must be held.
+func (cp *CP) remoteStartPending(idTag string) bool {
+	for _, conn := range cp.connectors {
+		if conn.remoteStartPending(idTag) {
+			return true
+		}
 	}
 
-	return 0, api.ErrNotAvailable
+	return false
 }
 
 func scale(f float64, scale types.UnitOfMeasure) float64 {
@@ -223,32 +192,3 @@ func scale(f float64, scale types.UnitOfMeasure) float64 {
 func getKeyCurrentPhase(phase int) string {
 	return string(types.MeasurandCurrentImport) + "@L" + strconv.Itoa(phase)
 }
-
-var _ api.PhaseCurrents = (*CP)(nil)
-
-func (cp *CP) Currents() (float64, float64, float64, error) {
-	cp.mu.Lock()
-	defer cp.mu.Unlock()
-
-	if cp.timeout > 0 && time.Since(cp.meterUpdated) > cp.timeout {
-		return 0, 0, 0, api.ErrNotAvailable
-	}
-
-	currents := make([]float64, 0, 3)
-
-	for phase := 1; phase <= 3; phase++ {
-		m, ok := cp.measurements[getKeyCurrentPhase(phase)]
-		if !ok {
-			return 0, 0, 0, api.ErrNotAvailable
-		}
-
-		f, err := strconv.ParseFloat(m.Value, 64)
-		if err != nil {
-			return 0, 0, 0, fmt.Errorf("invalid current for phase %d: %w", phase, err)
-		}
-
-		currents = append(currents, scale(f, m.Unit))
-	}
-
-	return currents[0], currents[1], currents[2], nil
-}
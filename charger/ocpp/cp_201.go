@@ -0,0 +1,212 @@
+package ocpp
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/evcc-io/evcc/util/ocpp201"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/availability"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/transactions"
+	types201 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// OCPP 2.0.1 messages are mapped onto their OCPP 1.6 counterparts to share the charge point state
+
+// EvseStatusNotification handles the OCPP 2.0.1 connector status
+func (cp *CP) EvseStatusNotification(request *availability.StatusNotificationRequest) (*availability.StatusNotificationResponse, error) {
+	cp.log.TRACE.Printf("%T: %+v", request, request)
+
+	cp.mu.Lock()
+	conn, ok := cp.connectors[request.EvseID]
+	if ok {
+		conn.connectorStatus = request.ConnectorStatus
+	}
+	cp.mu.Unlock()
+
+	if !ok {
+		cp.log.TRACE.Printf("ignoring status for evse %d: %s", request.EvseID, request.ConnectorStatus)
+		return new(availability.StatusNotificationResponse), nil
+	}
+
+	if _, err := cp.StatusNotification(conn.status201(request.Timestamp)); err != nil {
+		return nil, err
+	}
+
+	return new(availability.StatusNotificationResponse), nil
+}
+
+// TransactionEvent handles OCPP 2.0.1 transaction start, update and end including the transaction's charging state and meter values
+func (cp *CP) TransactionEvent(request *transactions.TransactionEventRequest) (*transactions.TransactionEventResponse, error) {
+	cp.log.TRACE.Printf("%T: %+v", request, request)
+
+	var evse int
+	if request.Evse != nil {
+		evse = request.Evse.ID
+	}
+
+	cp.mu.Lock()
+
+	txn, err := cp.transaction201(evse, request)
+	if err != nil {
+		cp.mu.Unlock()
+		return nil, fmt.Errorf("transaction event: %w", err)
+	}
+
+	if request.EventType == transactions.TransactionEventEnded {
+		if _, err := cp.ledger.Stop(txn.ID, energy201(request.MeterValue), request.Timestamp.Time, string(request.TransactionInfo.StoppedReason)); err != nil {
+			cp.log.ERROR.Printf("transaction event: %v", err)
+		}
+	}
+
+	// evse is only required for the first event of the transaction
+	if evse == 0 {
+		evse = txn.Connector
+	}
+
+	conn, ok := cp.connectors[evse]
+	if !ok {
+		cp.mu.Unlock()
+		cp.log.TRACE.Printf("ignoring transaction event for evse %d", evse)
+		return cp.transactionEventResponse201(request), nil
+	}
+
+	if request.IDToken != nil && request.IDToken.Type != types201.IdTokenTypeCentral {
+		conn.identify(request.IDToken.IdToken)
+	}
+
+	switch request.EventType {
+	case transactions.TransactionEventStarted, transactions.TransactionEventUpdated:
+		switch {
+		case !txn.Finished.IsZero():
+			cp.log.WARN.Printf("transaction event: transaction %s already finished", txn.StationTxID)
+
+		case conn.txnId != txn.ID:
+			// transaction may have been started before connecting
+			if conn.txnId != 0 {
+				cp.log.WARN.Printf("transaction event: replacing transaction %s on evse %d", conn.transactionId, evse)
+			}
+
+			conn.txnId = txn.ID
+			conn.transactionId = txn.StationTxID
+		}
+
+	case transactions.TransactionEventEnded:
+		if conn.txnId == txn.ID {
+			conn.txnId = 0
+			conn.transactionId = ""
+		} else {
+			cp.log.WARN.Printf("transaction event: evse %d not running transaction %s", evse, txn.StationTxID)
+		}
+	}
+
+	prev := conn.chargingState
+	if request.EventType == transactions.TransactionEventEnded {
+		conn.chargingState = ""
+	} else if request.TransactionInfo.ChargingState != "" {
+		conn.chargingState = request.TransactionInfo.ChargingState
+	}
+
+	var status *core.StatusNotificationRequest
+	if conn.chargingState != prev {
+		status = conn.status201(request.Timestamp)
+	}
+
+	cp.mu.Unlock()
+
+	if len(request.MeterValue) > 0 {
+		if _, err := cp.MeterValues(meterValuesRequest201(evse, request.MeterValue)); err != nil {
+			return nil, err
+		}
+	}
+
+	if status != nil {
+		if _, err := cp.StatusNotification(status); err != nil {
+			return nil, err
+		}
+	}
+
+	return cp.transactionEventResponse201(request), nil
+}
+
+// transactionEventResponse201 authorizes the transaction event's id token
+func (cp *CP) transactionEventResponse201(request *transactions.TransactionEventRequest) *transactions.TransactionEventResponse {
+	res := new(transactions.TransactionEventResponse)
+	if request.IDToken != nil {
+		// central tokens are issued for remote starts
+		if request.IDToken.Type == types201.IdTokenTypeCentral {
+			res.IDTokenInfo = types201.NewIdTokenInfo(types201.AuthorizationStatusAccepted)
+		} else {
+			cp.mu.Lock()
+			res.IDTokenInfo = ocpp201.IdTokenInfo(cp.idTagInfo(request.IDToken.IdToken))
+			cp.mu.Unlock()
+		}
+	}
+
+	return res
+}
+
+// transaction201 returns the transaction identified by the station's transaction id, recording it if unknown. Lock must be held.
+func (cp *CP) transaction201(evse int, request *transactions.TransactionEventRequest) (*Transaction, error) {
+	id := request.TransactionInfo.TransactionID
+
+	txn, err := cp.ledger.StationTransaction(cp.id, id)
+	if err != nil || txn != nil {
+		return txn, err
+	}
+
+	txn = &Transaction{
+		Station:     cp.id,
+		Connector:   evse,
+		StationTxID: id,
+		MeterStart:  energy201(request.MeterValue),
+		Started:     request.Timestamp.Time,
+	}
+
+	if request.IDToken != nil {
+		txn.IdTag = request.IDToken.IdToken
+	}
+
+	return txn, cp.ledger.Start(txn)
+}
+
+// energy201 returns the energy meter reading in Wh or zero if not available
+func energy201(meterValues []types201.MeterValue) int {
+	for _, mv := range ocpp201.MeterValues16(meterValues) {
+		for _, sample := range mv.SampledValue {
+			if sample.Measurand != types.MeasurandEnergyActiveImportRegister || sample.Phase != "" {
+				continue
+			}
+
+			if f, err := strconv.ParseFloat(sample.Value, 64); err == nil {
+				return int(scale(f, sample.Unit))
+			}
+		}
+	}
+
+	return 0
+}
+
+// status201 maps the connector status and transaction charging state onto an OCPP 1.6 status. Lock must be held.
+func (conn *Connector) status201(timestamp *types201.DateTime) *core.StatusNotificationRequest {
+	res := &core.StatusNotificationRequest{
+		ConnectorId: conn.id,
+		ErrorCode:   core.NoError,
+		Status:      ocpp201.Status16(conn.connectorStatus, conn.chargingState),
+	}
+
+	if timestamp != nil {
+		res.Timestamp = types.NewDateTime(timestamp.Time)
+	}
+
+	return res
+}
+
+// meterValuesRequest201 converts OCPP 2.0.1 meter values
+func meterValuesRequest201(evse int, meterValues []types201.MeterValue) *core.MeterValuesRequest {
+	return &core.MeterValuesRequest{
+		ConnectorId: evse,
+		MeterValue:  ocpp201.MeterValues16(meterValues),
+	}
+}
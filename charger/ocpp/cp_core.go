@@ -1,6 +1,7 @@
 package ocpp
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
@@ -10,25 +11,52 @@ import (
 
 const (
 	messageExpiry     = 30 * time.Second
-	transactionExpiry = time.Hour
+	remoteStartExpiry = 5 * time.Minute
 )
 
 func (cp *CP) Authorize(request *core.AuthorizeRequest) (*core.AuthorizeConfirmation, error) {
 	cp.log.TRACE.Printf("%T: %+v", request, request)
 
-	// TODO check if this authorizes foreign RFID tags
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	// the station doesn't tell which connector the id tag was presented at
+	for _, conn := range cp.connectors {
+		if len(cp.connectors) == 1 || (conn.txnId == 0 && conn.status != nil && conn.status.Status == core.ChargePointStatusPreparing) {
+			conn.identify(request.IdTag)
+		}
+	}
+
+	// stations may authorize remote starts before starting the transaction
+	info := &types.IdTagInfo{Status: types.AuthorizationStatusAccepted}
+	if !cp.remoteStartPending(request.IdTag) {
+		info = cp.idTagInfo(request.IdTag)
+	}
+
 	res := &core.AuthorizeConfirmation{
-		IdTagInfo: &types.IdTagInfo{
-			Status: types.AuthorizationStatusAccepted,
-		},
+		IdTagInfo: info,
 	}
 
 	return res, nil
 }
 
+// idTagInfo authorizes the id tag against the allow/deny list. Lock must be held.
+func (cp *CP) idTagInfo(idTag string) *types.IdTagInfo {
+	res := authList.Authorize(idTag)
+	if res.Status != types.AuthorizationStatusAccepted {
+		cp.log.WARN.Printf("id tag %s: %s", idTag, res.Status)
+	}
+
+	return res
+}
+
 func (cp *CP) BootNotification(request *core.BootNotificationRequest) (*core.BootNotificationConfirmation, error) {
 	cp.log.TRACE.Printf("%T: %+v", request, request)
 
+	cp.mu.Lock()
+	cp.boot = request
+	cp.mu.Unlock()
+
 	res := &core.BootNotificationConfirmation{
 		CurrentTime: types.NewDateTime(time.Now()),
 		Interval:    60, // TODO
@@ -38,22 +66,6 @@ func (cp *CP) BootNotification(request *core.BootNotificationRequest) (*core.Boo
 	return res, nil
 }
 
-// timestampValid returns false if status timestamps are outdated
-func (cp *CP) timestampValid(t time.Time) bool {
-	// reject if expired
-	if time.Since(t) > messageExpiry {
-		return false
-	}
-
-	// assume having a timestamp is better than not
-	if cp.status.Timestamp == nil {
-		return true
-	}
-
-	// reject older values than we already have
-	return !t.Before(cp.status.Timestamp.Time)
-}
-
 func (cp *CP) StatusNotification(request *core.StatusNotificationRequest) (*core.StatusNotificationConfirmation, error) {
 	cp.log.TRACE.Printf("%T: %+v", request, request)
 
@@ -61,13 +73,11 @@ func (cp *CP) StatusNotification(request *core.StatusNotificationRequest) (*core
 		cp.mu.Lock()
 		defer cp.mu.Unlock()
 
-		if cp.status == nil {
-			cp.status = request
-			close(cp.statusC) // signal initial status received
-		} else if request.Timestamp == nil || cp.timestampValid(request.Timestamp.Time) {
-			cp.status = request
+		// single connector stations may report on connector 0
+		if conn := cp.connector(request.ConnectorId); conn != nil {
+			conn.setStatus(request)
 		} else {
-			cp.log.TRACE.Printf("ignoring status: %s < %s", request.Timestamp.Time, cp.status.Timestamp)
+			cp.log.TRACE.Printf("ignoring status for connector %d: %s", request.ConnectorId, request.Status)
 		}
 	}
 
@@ -107,14 +117,10 @@ func (cp *CP) MeterValues(request *core.MeterValuesRequest) (*core.MeterValuesCo
 	cp.mu.Lock()
 	defer cp.mu.Unlock()
 
-	for _, meterValue := range request.MeterValue {
-		// ignore old meter value requests
-		if meterValue.Timestamp.Time.After(cp.meterUpdated) {
-			for _, sample := range meterValue.SampledValue {
-				cp.measurements[getSampleKey(sample)] = sample
-				cp.meterUpdated = time.Now()
-			}
-		}
+	if conn := cp.connector(request.ConnectorId); conn != nil {
+		conn.setMeterValues(request.MeterValue)
+	} else {
+		cp.log.TRACE.Printf("ignoring meter values for connector %d", request.ConnectorId)
 	}
 
 	return new(core.MeterValuesConfirmation), nil
@@ -134,20 +140,62 @@ func (cp *CP) StartTransaction(request *core.StartTransactionRequest) (*core.Sta
 	cp.mu.Lock()
 	defer cp.mu.Unlock()
 
+	conn, ok := cp.connectors[request.ConnectorId]
+
+	// the remote start id tag is only accepted for transactions requested by evcc
+	remote := ok && conn.remoteStartPending(request.IdTag)
+
+	info := &types.IdTagInfo{Status: types.AuthorizationStatusAccepted}
+	if remote {
+		conn.remoteStart = nil
+	} else {
+		info = cp.idTagInfo(request.IdTag)
+	}
+
+	txn := &Transaction{
+		Station:    cp.id,
+		Connector:  request.ConnectorId,
+		IdTag:      request.IdTag,
+		MeterStart: request.MeterStart,
+		Started:    request.Timestamp.Time,
+	}
+
+	if err := cp.ledger.Start(txn); err != nil {
+		return nil, fmt.Errorf("start transaction: %w", err)
+	}
+
 	res := &core.StartTransactionConfirmation{
-		IdTagInfo: &types.IdTagInfo{
-			Status: types.AuthorizationStatusAccepted, // accept
-		},
-		TransactionId: 1, // default
+		IdTagInfo:     info,
+		TransactionId: txn.ID,
+	}
+
+	// rejected transactions are finished right away
+	if info.Status != types.AuthorizationStatusAccepted {
+		if _, err := cp.ledger.Stop(txn.ID, request.MeterStart, request.Timestamp.Time, string(core.ReasonDeAuthorized)); err != nil {
+			cp.log.ERROR.Printf("start transaction: %v", err)
+		}
+
+		return res, nil
+	}
+
+	if !ok {
+		cp.log.WARN.Printf("start transaction %d: unknown connector %d", txn.ID, request.ConnectorId)
+		return res, nil
 	}
 
-	// create new transaction
-	if request != nil && time.Since(request.Timestamp.Time) < transactionExpiry { // only respect transactions in the last hour
-		cp.txnCount++
-		res.TransactionId = cp.txnCount
+	conn.identify(request.IdTag)
+	conn.useReservation(request.ReservationId)
+
+	if conn.txnId != 0 {
+		cp.log.WARN.Printf("start transaction %d: replacing transaction %d on connector %d", txn.ID, conn.txnId, conn.id)
 	}
 
-	cp.txnId = res.TransactionId
+	conn.txnId = txn.ID
+
+	conn.remoteTxnId = 0
+	if remote {
+		conn.remoteTxnId = txn.ID
+	}
 
 	return res, nil
 }
@@ -158,20 +206,34 @@ func (cp *CP) StopTransaction(request *core.StopTransactionRequest) (*core.StopT
 	cp.mu.Lock()
 	defer cp.mu.Unlock()
 
-	// reset transaction
-	if request != nil && time.Since(request.Timestamp.Time) < transactionExpiry { // only respect transactions in the last hour
-		// log mismatching id but close transaction anyway
-		if request.TransactionId != cp.txnId {
-			cp.log.ERROR.Printf("stop transaction: invalid id %d", request.TransactionId)
-		}
+	// log unknown transactions but confirm anyway
+	txn, err := cp.ledger.Stop(request.TransactionId, request.MeterStop, request.Timestamp.Time, string(request.Reason))
+	if err != nil {
+		cp.log.ERROR.Printf("stop transaction: %v", err)
+	}
+
+	res := new(core.StopTransactionConfirmation)
 
-		cp.txnId = 0
+	// the request doesn't contain the connector
+	conn := cp.transactionConnector(request.TransactionId)
+
+	// id tag is optional, the remote start id tag is accepted for stopping the remote transaction
+	if request.IdTag != "" {
+		if conn != nil && conn.remoteTxnId == request.TransactionId && txn != nil && txn.IdTag == request.IdTag {
+			res.IdTagInfo = &types.IdTagInfo{Status: types.AuthorizationStatusAccepted}
+		} else {
+			res.IdTagInfo = cp.idTagInfo(request.IdTag)
+		}
 	}
 
-	res := &core.StopTransactionConfirmation{
-		IdTagInfo: &types.IdTagInfo{
-			Status: types.AuthorizationStatusAccepted, // accept
-		},
+	if conn != nil {
+		conn.txnId = 0
+		conn.remoteTxnId = 0
+	} else if txn != nil {
+		// transaction is not active on its connector, e.g. after restart
+		if conn := cp.connectors[txn.Connector]; conn != nil && conn.txnId != 0 {
+			cp.log.WARN.Printf("stop transaction %d: connector %d running transaction %d", request.TransactionId, txn.Connector, conn.txnId)
+		}
 	}
 
 	return res, nil
@@ -180,11 +242,15 @@ func (cp *CP) StopTransaction(request *core.StopTransactionRequest) (*core.StopT
 func (cp *CP) DiagnosticStatusNotification(request *firmware.DiagnosticsStatusNotificationRequest) (*firmware.DiagnosticsStatusNotificationConfirmation, error) {
 	cp.log.TRACE.Printf("%T: %+v", request, request)
 
+	cp.updateOperation(OperationDiagnostics, nil, string(request.Status))
+
 	return &firmware.DiagnosticsStatusNotificationConfirmation{}, nil
 }
 
 func (cp *CP) FirmwareStatusNotification(request *firmware.FirmwareStatusNotificationRequest) (*firmware.FirmwareStatusNotificationConfirmation, error) {
 	cp.log.TRACE.Printf("%T: %+v", request, request)
 
+	cp.updateOperation(OperationFirmware, nil, string(request.Status))
+
 	return &firmware.FirmwareStatusNotificationConfirmation{}, nil
 }
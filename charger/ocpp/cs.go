@@ -4,31 +4,65 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/evcc-io/evcc/util"
 	ocpp16 "github.com/lorenzodonini/ocpp-go/ocpp1.6"
+	ocpp2 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1"
 )
 
 type CS struct {
 	mu  sync.Mutex
 	log *util.Logger
 	ocpp16.CentralSystem
-	cps map[string]*CP
+	CSMS ocpp2.CSMS
+	cps  map[string]*CP
 }
 
 func (cs *CS) Register(id string, cp *CP) error {
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
 
+	return cs.register(id, cp)
+}
+
+// register adds the charge point. Lock must be held.
+func (cs *CS) register(id string, cp *CP) error {
 	if _, ok := cs.cps[id]; ok && id == "" {
 		return errors.New("cannot have >1 chargepoint with empty station id")
 	}
 
+	ledger, err := TransactionLedger()
+	if err != nil {
+		return fmt.Errorf("transaction ledger: %w", err)
+	}
+
+	cp.mu.Lock()
+	cp.ledger = ledger
+	cp.mu.Unlock()
+
 	cs.cps[id] = cp
 
 	return nil
 }
 
+// RegisterConnector registers the station's connector. Connectors of the same station share the station's charge point
+// which is created on first registration.
+func (cs *CS) RegisterConnector(id string, connector int, log *util.Logger, timeout time.Duration) (*Connector, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cp, ok := cs.cps[id]
+	if !ok {
+		cp = NewChargePoint(log, id, timeout)
+		if err := cs.register(id, cp); err != nil {
+			return nil, err
+		}
+	}
+
+	return cp.RegisterConnector(connector)
+}
+
 // errorHandler logs error channel
 func (cs *CS) errorHandler(errC <-chan error) {
 	for err := range errC {
@@ -44,42 +78,71 @@ func (cs *CS) chargepointByID(id string) (*CP, error) {
 	return cp, nil
 }
 
-func (cs *CS) NewChargePoint(chargePoint ocpp16.ChargePointConnection) {
+// protocol returns the protocol of the charge point or empty if unknown
+func (cs *CS) protocol(id string) string {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if cp, err := cs.chargepointByID(id); err == nil {
+		return cp.Protocol()
+	}
+
+	return ""
+}
+
+func (cs *CS) connect(id, protocol string) {
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
 
-	if cp, err := cs.chargepointByID(chargePoint.ID()); err != nil {
+	if cp, err := cs.chargepointByID(id); err != nil {
 		if cp, ok := cs.cps[""]; ok {
-			cs.log.INFO.Printf("chargepoint connected, registering: %s", chargePoint.ID())
+			cs.log.INFO.Printf("chargepoint connected, registering: %s (%s)", id, protocol)
 
 			// update id
-			cp.RegisterID(chargePoint.ID())
-			cs.cps[chargePoint.ID()] = cp
+			cp.RegisterID(id)
+			cs.cps[id] = cp
 			delete(cs.cps, "")
 
-			cp.Connect()
+			cp.Connect(protocol)
 
 			return
 		}
 
-		cs.log.WARN.Printf("chargepoint connected, ignoring: %s", chargePoint.ID())
+		cs.log.WARN.Printf("chargepoint connected, ignoring: %s", id)
 	} else {
-		cs.log.DEBUG.Printf("chargepoint connected: %s", chargePoint.ID())
-		cp.Connect()
+		cs.log.DEBUG.Printf("chargepoint connected: %s (%s)", id, protocol)
+		cp.Connect(protocol)
 	}
 }
 
-func (cs *CS) ChargePointDisconnected(chargePoint ocpp16.ChargePointConnection) {
+func (cs *CS) disconnect(id string) {
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
 
-	if _, err := cs.chargepointByID(chargePoint.ID()); err != nil {
+	if cp, err := cs.chargepointByID(id); err != nil {
 		cs.log.ERROR.Printf("chargepoint disconnected: %v", err)
 	} else {
-		cs.log.DEBUG.Printf("chargepoint disconnected: %s", chargePoint.ID())
+		cs.log.DEBUG.Printf("chargepoint disconnected: %s", id)
+		cp.Disconnect()
 	}
 }
 
+func (cs *CS) NewChargePoint(chargePoint ocpp16.ChargePointConnection) {
+	cs.connect(chargePoint.ID(), ProtocolV16)
+}
+
+func (cs *CS) ChargePointDisconnected(chargePoint ocpp16.ChargePointConnection) {
+	cs.disconnect(chargePoint.ID())
+}
+
+func (cs *CS) NewChargingStation(chargingStation ocpp2.ChargingStationConnection) {
+	cs.connect(chargingStation.ID(), ProtocolV201)
+}
+
+func (cs *CS) ChargingStationDisconnected(chargingStation ocpp2.ChargingStationConnection) {
+	cs.disconnect(chargingStation.ID())
+}
+
 func (cs *CS) Debug(args ...interface{}) {
 	cs.log.TRACE.Println(args...)
 }
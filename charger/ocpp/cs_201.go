@@ -0,0 +1,219 @@
+package ocpp
+
+import (
+	"github.com/evcc-io/evcc/util/ocpp201"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/authorization"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/availability"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/diagnostics"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/firmware"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/meter"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/remotecontrol"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/transactions"
+	types201 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// csms handles OCPP 2.0.1 messages. Since handler names equal their OCPP 1.6 counterparts
+// it wraps the central system instead of adding the handlers to it.
+type csms struct {
+	cs *CS
+}
+
+// cs actions
+
+func (cs *CS) triggerMessage201(id string, requestedMessage remotecontrol.MessageTrigger) {
+	if err := cs.CSMS.TriggerMessage(id, func(request *remotecontrol.TriggerMessageResponse, err error) {
+		log := cs.log.TRACE
+		if err == nil && request != nil && request.Status != remotecontrol.TriggerMessageStatusAccepted {
+			log = cs.log.ERROR
+		}
+
+		var status remotecontrol.TriggerMessageStatus
+		if request != nil {
+			status = request.Status
+		}
+
+		log.Printf("TriggerMessage %s for %s: %+v", requestedMessage, id, status)
+	}, requestedMessage); err != nil {
+		cs.log.ERROR.Printf("send TriggerMessage %s for %s failed: %v", requestedMessage, id, err)
+	}
+}
+
+// cp actions
+
+func (s *csms) chargepointByID(id string) (*CP, error) {
+	s.cs.mu.Lock()
+	defer s.cs.mu.Unlock()
+
+	return s.cs.chargepointByID(id)
+}
+
+func (s *csms) OnAuthorize(id string, request *authorization.AuthorizeRequest) (*authorization.AuthorizeResponse, error) {
+	cp, err := s.chargepointByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := cp.Authorize(&core.AuthorizeRequest{
+		IdTag: request.IdToken.IdToken,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &authorization.AuthorizeResponse{
+		IdTokenInfo: *ocpp201.IdTokenInfo(res.IdTagInfo),
+	}, nil
+}
+
+func (s *csms) OnBootNotification(id string, request *provisioning.BootNotificationRequest) (*provisioning.BootNotificationResponse, error) {
+	cp, err := s.chargepointByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := cp.BootNotification(&core.BootNotificationRequest{
+		ChargePointVendor:       request.ChargingStation.VendorName,
+		ChargePointModel:        request.ChargingStation.Model,
+		ChargePointSerialNumber: request.ChargingStation.SerialNumber,
+		FirmwareVersion:         request.ChargingStation.FirmwareVersion,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &provisioning.BootNotificationResponse{
+		CurrentTime: types201.NewDateTime(res.CurrentTime.Time),
+		Interval:    res.Interval,
+		Status:      provisioning.RegistrationStatus(res.Status),
+	}, nil
+}
+
+func (s *csms) OnNotifyReport(id string, request *provisioning.NotifyReportRequest) (*provisioning.NotifyReportResponse, error) {
+	cp, err := s.chargepointByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	cp.log.TRACE.Printf("%T: %+v", request, request)
+
+	return new(provisioning.NotifyReportResponse), nil
+}
+
+func (s *csms) OnHeartbeat(id string, request *availability.HeartbeatRequest) (*availability.HeartbeatResponse, error) {
+	cp, err := s.chargepointByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := cp.Heartbeat(new(core.HeartbeatRequest))
+	if err != nil {
+		return nil, err
+	}
+
+	return &availability.HeartbeatResponse{
+		CurrentTime: *types201.NewDateTime(res.CurrentTime.Time),
+	}, nil
+}
+
+func (s *csms) OnStatusNotification(id string, request *availability.StatusNotificationRequest) (*availability.StatusNotificationResponse, error) {
+	cp, err := s.chargepointByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return cp.EvseStatusNotification(request)
+}
+
+func (s *csms) OnMeterValues(id string, request *meter.MeterValuesRequest) (*meter.MeterValuesResponse, error) {
+	cp, err := s.chargepointByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := cp.MeterValues(meterValuesRequest201(request.EvseID, request.MeterValue)); err != nil {
+		return nil, err
+	}
+
+	return new(meter.MeterValuesResponse), nil
+}
+
+func (s *csms) OnTransactionEvent(id string, request *transactions.TransactionEventRequest) (*transactions.TransactionEventResponse, error) {
+	cp, err := s.chargepointByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return cp.TransactionEvent(request)
+}
+
+func (s *csms) OnFirmwareStatusNotification(id string, request *firmware.FirmwareStatusNotificationRequest) (*firmware.FirmwareStatusNotificationResponse, error) {
+	cp, err := s.chargepointByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	cp.log.TRACE.Printf("%T: %+v", request, request)
+
+	cp.updateOperation(OperationFirmware, request.RequestID, string(request.Status))
+
+	return new(firmware.FirmwareStatusNotificationResponse), nil
+}
+
+func (s *csms) OnPublishFirmwareStatusNotification(id string, request *firmware.PublishFirmwareStatusNotificationRequest) (*firmware.PublishFirmwareStatusNotificationResponse, error) {
+	cp, err := s.chargepointByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	cp.log.TRACE.Printf("%T: %+v", request, request)
+
+	return new(firmware.PublishFirmwareStatusNotificationResponse), nil
+}
+
+func (s *csms) OnLogStatusNotification(id string, request *diagnostics.LogStatusNotificationRequest) (*diagnostics.LogStatusNotificationResponse, error) {
+	cp, err := s.chargepointByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	cp.log.TRACE.Printf("%T: %+v", request, request)
+
+	cp.updateOperation(OperationDiagnostics, &request.RequestID, string(request.Status))
+
+	return new(diagnostics.LogStatusNotificationResponse), nil
+}
+
+func (s *csms) OnNotifyCustomerInformation(id string, request *diagnostics.NotifyCustomerInformationRequest) (*diagnostics.NotifyCustomerInformationResponse, error) {
+	cp, err := s.chargepointByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	cp.log.TRACE.Printf("%T: %+v", request, request)
+
+	return new(diagnostics.NotifyCustomerInformationResponse), nil
+}
+
+func (s *csms) OnNotifyEvent(id string, request *diagnostics.NotifyEventRequest) (*diagnostics.NotifyEventResponse, error) {
+	cp, err := s.chargepointByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	cp.log.TRACE.Printf("%T: %+v", request, request)
+
+	return new(diagnostics.NotifyEventResponse), nil
+}
+
+func (s *csms) OnNotifyMonitoringReport(id string, request *diagnostics.NotifyMonitoringReportRequest) (*diagnostics.NotifyMonitoringReportResponse, error) {
+	cp, err := s.chargepointByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	cp.log.TRACE.Printf("%T: %+v", request, request)
+
+	return new(diagnostics.NotifyMonitoringReportResponse), nil
+}
@@ -0,0 +1,476 @@
+package ocpp
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/evcc-io/evcc/api"
+	"github.com/evcc-io/evcc/util/ocpp201"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/remotetrigger"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/authorization"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/availability"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/remotecontrol"
+	types201 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+const requestTimeout = 30 * time.Second
+
+var (
+	ErrUnknownStation = errors.New("unknown station")
+	ErrStationOffline = errors.New("station offline")
+)
+
+// StationInfo is the station's state for troubleshooting
+type StationInfo struct {
+	ID         string                        `json:"id"`
+	Protocol   string                        `json:"protocol"`
+	Connected  bool                          `json:"connected"`
+	Boot       *core.BootNotificationRequest `json:"boot,omitempty"`
+	Heartbeat  time.Time                     `json:"heartbeat"`
+	Connectors []ConnectorInfo               `json:"connectors"`
+}
+
+// ConnectorInfo is the connector's state for troubleshooting
+type ConnectorInfo struct {
+	ID           int                           `json:"id"`
+	Status       core.ChargePointStatus        `json:"status"`
+	ErrorCode    core.ChargePointErrorCode     `json:"errorCode"`
+	Info         string                        `json:"info,omitempty"`
+	Transaction  *Transaction                  `json:"transaction,omitempty"`
+	IdTag        string                        `json:"idTag,omitempty"`
+	MeterUpdated time.Time                     `json:"meterUpdated"`
+	Measurements map[string]types.SampledValue `json:"measurements"`
+}
+
+// Admin inspects and operates stations on behalf of support staff.
+// Requests are sent to the station according to its protocol and wait for the station's response.
+type Admin struct {
+	cs *CS
+}
+
+// Admin returns the central system's admin interface
+func (cs *CS) Admin() *Admin {
+	return &Admin{cs: cs}
+}
+
+// Stations returns all registered stations ordered by id
+func (a *Admin) Stations() []StationInfo {
+	a.cs.mu.Lock()
+	cps := make([]*CP, 0, len(a.cs.cps))
+	for _, cp := range a.cs.cps {
+		cps = append(cps, cp)
+	}
+	a.cs.mu.Unlock()
+
+	res := make([]StationInfo, 0, len(cps))
+	for _, cp := range cps {
+		res = append(res, cp.info())
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ID < res[j].ID
+	})
+
+	return res
+}
+
+// Station returns the station's state
+func (a *Admin) Station(id string) (StationInfo, error) {
+	a.cs.mu.Lock()
+	cp, ok := a.cs.cps[id]
+	a.cs.mu.Unlock()
+
+	if !ok {
+		return StationInfo{}, fmt.Errorf("%w: %s", ErrUnknownStation, id)
+	}
+
+	return cp.info(), nil
+}
+
+// info returns the station's state
+func (cp *CP) info() StationInfo {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	res := StationInfo{
+		ID:         cp.id,
+		Protocol:   cp.protocol,
+		Connected:  cp.connected,
+		Boot:       cp.boot,
+		Heartbeat:  cp.updated,
+		Connectors: make([]ConnectorInfo, 0, len(cp.connectors)),
+	}
+
+	for _, conn := range cp.connectors {
+		info := ConnectorInfo{
+			ID:           conn.id,
+			IdTag:        conn.idTag,
+			MeterUpdated: conn.meterUpdated,
+			Measurements: make(map[string]types.SampledValue, len(conn.measurements)),
+		}
+
+		if conn.status != nil {
+			info.Status = conn.status.Status
+			info.ErrorCode = conn.status.ErrorCode
+			info.Info = conn.status.Info
+		}
+
+		if conn.txnId != 0 && cp.ledger != nil {
+			if txn, err := cp.ledger.Transaction(conn.txnId); err == nil {
+				info.Transaction = txn
+			}
+		}
+
+		for k, v := range conn.measurements {
+			info.Measurements[k] = v
+		}
+
+		res.Connectors = append(res.Connectors, info)
+	}
+
+	sort.Slice(res.Connectors, func(i, j int) bool {
+		return res.Connectors[i].ID < res.Connectors[j].ID
+	})
+
+	return res
+}
+
+// protocol returns the protocol of the connected station
+func (a *Admin) protocol(id string) (string, error) {
+	a.cs.mu.Lock()
+	cp, ok := a.cs.cps[id]
+	a.cs.mu.Unlock()
+
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownStation, id)
+	}
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	if !cp.connected {
+		return "", fmt.Errorf("%w: %s", ErrStationOffline, id)
+	}
+
+	return cp.protocol, nil
+}
+
+// request sends the request and waits for the station's response
+func request[T any](send func(callback func(T, error)) error) (T, error) {
+	type result struct {
+		res T
+		err error
+	}
+
+	var zero T
+	rc := make(chan result, 1)
+
+	if err := send(func(res T, err error) {
+		rc <- result{res, err}
+	}); err != nil {
+		return zero, err
+	}
+
+	select {
+	case r := <-rc:
+		return r.res, r.err
+	case <-time.After(requestTimeout):
+		return zero, api.ErrTimeout
+	}
+}
+
+// Reset resets the station. Soft resets wait for running transactions to finish (OCPP 2.0.1: OnIdle).
+func (a *Admin) Reset(id string, hard bool) (string, error) {
+	protocol, err := a.protocol(id)
+	if err != nil {
+		return "", err
+	}
+
+	if protocol == ProtocolV201 {
+		typ := provisioning.ResetTypeOnIdle
+		if hard {
+			typ = provisioning.ResetTypeImmediate
+		}
+
+		res, err := request(func(cb func(*provisioning.ResetResponse, error)) error {
+			return a.cs.CSMS.Reset(id, cb, typ)
+		})
+		if err != nil {
+			return "", err
+		}
+
+		return string(res.Status), nil
+	}
+
+	typ := core.ResetTypeSoft
+	if hard {
+		typ = core.ResetTypeHard
+	}
+
+	res, err := request(func(cb func(*core.ResetConfirmation, error)) error {
+		return a.cs.Reset(id, cb, typ)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return string(res.Status), nil
+}
+
+// UnlockConnector unlocks the connector's cable (OCPP 2.0.1: the EVSE's first connector)
+func (a *Admin) UnlockConnector(id string, connector int) (string, error) {
+	protocol, err := a.protocol(id)
+	if err != nil {
+		return "", err
+	}
+
+	if protocol == ProtocolV201 {
+		res, err := request(func(cb func(*remotecontrol.UnlockConnectorResponse, error)) error {
+			return a.cs.CSMS.UnlockConnector(id, cb, connector, 1)
+		})
+		if err != nil {
+			return "", err
+		}
+
+		return string(res.Status), nil
+	}
+
+	res, err := request(func(cb func(*core.UnlockConnectorConfirmation, error)) error {
+		return a.cs.UnlockConnector(id, cb, connector)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return string(res.Status), nil
+}
+
+// ChangeAvailability makes the connector or the whole station (connector 0) operative or inoperative
+func (a *Admin) ChangeAvailability(id string, connector int, operative bool) (string, error) {
+	protocol, err := a.protocol(id)
+	if err != nil {
+		return "", err
+	}
+
+	if protocol == ProtocolV201 {
+		status := availability.OperationalStatusInoperative
+		if operative {
+			status = availability.OperationalStatusOperative
+		}
+
+		res, err := request(func(cb func(*availability.ChangeAvailabilityResponse, error)) error {
+			return a.cs.CSMS.ChangeAvailability(id, cb, status, func(request *availability.ChangeAvailabilityRequest) {
+				if connector > 0 {
+					request.Evse = &types201.EVSE{ID: connector}
+				}
+			})
+		})
+		if err != nil {
+			return "", err
+		}
+
+		return string(res.Status), nil
+	}
+
+	typ := core.AvailabilityTypeInoperative
+	if operative {
+		typ = core.AvailabilityTypeOperative
+	}
+
+	res, err := request(func(cb func(*core.ChangeAvailabilityConfirmation, error)) error {
+		return a.cs.ChangeAvailability(id, cb, connector, typ)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return string(res.Status), nil
+}
+
+// TriggerMessage requests the station to send the message, e.g. StatusNotification or MeterValues
+func (a *Admin) TriggerMessage(id, message string) (string, error) {
+	protocol, err := a.protocol(id)
+	if err != nil {
+		return "", err
+	}
+
+	if protocol == ProtocolV201 {
+		res, err := request(func(cb func(*remotecontrol.TriggerMessageResponse, error)) error {
+			return a.cs.CSMS.TriggerMessage(id, cb, remotecontrol.MessageTrigger(message))
+		})
+		if err != nil {
+			return "", err
+		}
+
+		return string(res.Status), nil
+	}
+
+	res, err := request(func(cb func(*remotetrigger.TriggerMessageConfirmation, error)) error {
+		return a.cs.TriggerMessage(id, cb, remotetrigger.MessageTrigger(message))
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return string(res.Status), nil
+}
+
+// GetConfiguration returns the station's configuration keys or all keys if none are given.
+// OCPP 2.0.1 variables are addressed by their OCPP 1.6 configuration key or as Component.Variable.
+func (a *Admin) GetConfiguration(id string, keys []string) (*core.GetConfigurationConfirmation, error) {
+	protocol, err := a.protocol(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if protocol == ProtocolV201 {
+		return a.getVariables201(id, keys)
+	}
+
+	return request(func(cb func(*core.GetConfigurationConfirmation, error)) error {
+		return a.cs.GetConfiguration(id, cb, keys)
+	})
+}
+
+// ChangeConfiguration updates the station's configuration key
+func (a *Admin) ChangeConfiguration(id, key, value string) (string, error) {
+	protocol, err := a.protocol(id)
+	if err != nil {
+		return "", err
+	}
+
+	if protocol == ProtocolV201 {
+		v, err := variable201(key)
+		if err != nil {
+			return "", err
+		}
+
+		res, err := request(func(cb func(*provisioning.SetVariablesResponse, error)) error {
+			return a.cs.CSMS.SetVariables(id, cb, []provisioning.SetVariableData{{
+				Component:      v.Component,
+				Variable:       v.Variable,
+				AttributeValue: value,
+			}})
+		})
+		if err != nil {
+			return "", err
+		}
+
+		if len(res.SetVariableResult) == 0 {
+			return "", errors.New("missing result")
+		}
+
+		return string(res.SetVariableResult[0].AttributeStatus), nil
+	}
+
+	res, err := request(func(cb func(*core.ChangeConfigurationConfirmation, error)) error {
+		return a.cs.ChangeConfiguration(id, cb, key, value)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return string(res.Status), nil
+}
+
+// ClearCache clears the station's authorization cache
+func (a *Admin) ClearCache(id string) (string, error) {
+	protocol, err := a.protocol(id)
+	if err != nil {
+		return "", err
+	}
+
+	if protocol == ProtocolV201 {
+		res, err := request(func(cb func(*authorization.ClearCacheResponse, error)) error {
+			return a.cs.CSMS.ClearCache(id, cb)
+		})
+		if err != nil {
+			return "", err
+		}
+
+		return string(res.Status), nil
+	}
+
+	res, err := request(func(cb func(*core.ClearCacheConfirmation, error)) error {
+		return a.cs.ClearCache(id, cb)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return string(res.Status), nil
+}
+
+// variable201 returns the device model variable by OCPP 1.6 configuration key or Component.Variable name
+func variable201(key string) (types201.ComponentVariable, error) {
+	if v, ok := ocpp201.Variables[key]; ok {
+		return v, nil
+	}
+
+	component, variable, ok := strings.Cut(key, ".")
+	if !ok || component == "" || variable == "" {
+		return types201.ComponentVariable{}, fmt.Errorf("invalid variable: %s", key)
+	}
+
+	return types201.ComponentVariable{
+		Component: types201.Component{Name: component},
+		Variable:  types201.Variable{Name: variable},
+	}, nil
+}
+
+// getVariables201 reads the device model variables and maps them onto OCPP 1.6 configuration keys
+func (a *Admin) getVariables201(id string, keys []string) (*core.GetConfigurationConfirmation, error) {
+	if len(keys) == 0 {
+		for key := range ocpp201.Variables {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+	}
+
+	data := make([]provisioning.GetVariableData, 0, len(keys))
+	for _, key := range keys {
+		v, err := variable201(key)
+		if err != nil {
+			return nil, err
+		}
+
+		data = append(data, provisioning.GetVariableData{
+			Component: v.Component,
+			Variable:  v.Variable,
+		})
+	}
+
+	resp, err := request(func(cb func(*provisioning.GetVariablesResponse, error)) error {
+		return a.cs.CSMS.GetVariables(id, cb, data)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := new(core.GetConfigurationConfirmation)
+	for _, r := range resp.GetVariableResult {
+		key, ok := ocpp201.Key(r.Component, r.Variable)
+		if !ok {
+			key = r.Component.Name + "." + r.Variable.Name
+		}
+
+		if r.AttributeStatus != provisioning.GetVariableStatusAccepted {
+			res.UnknownKey = append(res.UnknownKey, key)
+			continue
+		}
+
+		value := r.AttributeValue
+		res.ConfigurationKey = append(res.ConfigurationKey, core.ConfigurationKey{
+			Key:   key,
+			Value: &value,
+		})
+	}
+
+	return res, nil
+}
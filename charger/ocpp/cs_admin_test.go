@@ -0,0 +1,71 @@
+package ocpp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/evcc-io/evcc/util"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminStations(t *testing.T) {
+	cp, _ := newTestConnectors(t, 2, 1)
+
+	cs := &CS{
+		log: util.NewLogger("foo"),
+		cps: map[string]*CP{"cp1": cp},
+	}
+	admin := cs.Admin()
+
+	_, err := admin.Reset("cp1", false)
+	assert.ErrorIs(t, err, ErrStationOffline)
+
+	_, err = admin.Station("cp2")
+	assert.ErrorIs(t, err, ErrUnknownStation)
+
+	_, err = cp.BootNotification(&core.BootNotificationRequest{ChargePointVendor: "vendor", ChargePointModel: "model"})
+	require.NoError(t, err)
+
+	_, err = cp.StatusNotification(&core.StatusNotificationRequest{ConnectorId: 1, ErrorCode: core.GroundFailure, Status: core.ChargePointStatusFaulted})
+	require.NoError(t, err)
+
+	start, err := cp.StartTransaction(&core.StartTransactionRequest{
+		ConnectorId: 2,
+		IdTag:       "card",
+		Timestamp:   types.NewDateTime(time.Now()),
+	})
+	require.NoError(t, err)
+
+	stations := admin.Stations()
+	require.Len(t, stations, 1)
+
+	res := stations[0]
+	assert.Equal(t, "cp1", res.ID)
+	assert.Equal(t, "vendor", res.Boot.ChargePointVendor)
+	require.Len(t, res.Connectors, 2)
+
+	assert.Equal(t, 1, res.Connectors[0].ID)
+	assert.Equal(t, core.ChargePointStatusFaulted, res.Connectors[0].Status)
+	assert.Equal(t, core.GroundFailure, res.Connectors[0].ErrorCode)
+	assert.Nil(t, res.Connectors[0].Transaction)
+
+	require.NotNil(t, res.Connectors[1].Transaction)
+	assert.Equal(t, start.TransactionId, res.Connectors[1].Transaction.ID)
+	assert.Equal(t, "card", res.Connectors[1].IdTag)
+}
+
+func TestAdminVariable201(t *testing.T) {
+	v, err := variable201("MeterValueSampleInterval")
+	require.NoError(t, err)
+	assert.Equal(t, "SampledDataCtrlr", v.Component.Name)
+
+	v, err = variable201("OCPPCommCtrlr.HeartbeatInterval")
+	require.NoError(t, err)
+	assert.Equal(t, "HeartbeatInterval", v.Variable.Name)
+
+	_, err = variable201("UnknownKey")
+	assert.Error(t, err)
+}
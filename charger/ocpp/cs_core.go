@@ -4,6 +4,7 @@ import (
 	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
 	"github.com/lorenzodonini/ocpp-go/ocpp1.6/firmware"
 	"github.com/lorenzodonini/ocpp-go/ocpp1.6/remotetrigger"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/remotecontrol"
 )
 
 // cs actions
@@ -27,6 +28,11 @@ func (cs *CS) TriggerResetRequest(id string, resetType core.ResetType) {
 }
 
 func (cs *CS) TriggerMessageRequest(id string, requestedMessage remotetrigger.MessageTrigger) {
+	if cs.protocol(id) == ProtocolV201 {
+		cs.triggerMessage201(id, remotecontrol.MessageTrigger(requestedMessage))
+		return
+	}
+
 	if err := cs.TriggerMessage(id, func(request *remotetrigger.TriggerMessageConfirmation, err error) {
 		log := cs.log.TRACE
 		if err == nil && request != nil && request.Status != remotetrigger.TriggerMessageStatusAccepted {
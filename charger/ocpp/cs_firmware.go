@@ -0,0 +1,183 @@
+package ocpp
+
+import (
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/firmware"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/diagnostics"
+	firmware201 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1/firmware"
+	types201 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// Retry are the optional retry parameters of firmware downloads and diagnostics uploads
+type Retry struct {
+	Retries       *int // number of retries
+	RetryInterval *int // seconds between attempts
+}
+
+// Operations returns the station's firmware updates and diagnostics uploads, latest first
+func (a *Admin) Operations(id string) ([]Operation, error) {
+	if _, err := a.Station(id); err != nil {
+		return nil, err
+	}
+
+	ledger, err := TransactionLedger()
+	if err != nil {
+		return nil, err
+	}
+
+	return ledger.Operations(id)
+}
+
+// startOperation records the operation before it is sent to the station
+func (a *Admin) startOperation(id, typ, location, file string) (*Ledger, *Operation, error) {
+	ledger, err := TransactionLedger()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	op := &Operation{
+		Station:  id,
+		Type:     typ,
+		Location: location,
+		File:     file,
+		Status:   StatusRequested,
+		Created:  now,
+		Updated:  now,
+	}
+
+	return ledger, op, ledger.StartOperation(op)
+}
+
+// finishRequest records the station's response to the operation's request
+func (a *Admin) finishRequest(ledger *Ledger, op *Operation, status string, err error) (*Operation, error) {
+	switch {
+	case err != nil:
+		op.setStatus(StatusRequestFailed)
+	case status != "":
+		op.setStatus(status)
+	}
+
+	if serr := ledger.SaveOperation(op); err == nil {
+		err = serr
+	}
+
+	publish(*op)
+
+	return op, err
+}
+
+// UpdateFirmware requests the station to download and install the firmware image served by evcc
+func (a *Admin) UpdateFirmware(id, file string, retry Retry) (*Operation, error) {
+	protocol, err := a.protocol(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := FirmwareFile(file); err != nil {
+		return nil, err
+	}
+
+	if config.URL == "" {
+		return nil, errors.New("url not configured")
+	}
+
+	location := config.URL + "/ocpp/firmware/" + url.PathEscape(file)
+
+	ledger, op, err := a.startOperation(id, OperationFirmware, location, file)
+	if err != nil {
+		return nil, err
+	}
+
+	if protocol == ProtocolV201 {
+		res, err := request(func(cb func(*firmware201.UpdateFirmwareResponse, error)) error {
+			return a.cs.CSMS.UpdateFirmware(id, cb, op.ID, firmware201.Firmware{
+				Location:         location,
+				RetrieveDateTime: types201.NewDateTime(time.Now()),
+			}, func(request *firmware201.UpdateFirmwareRequest) {
+				request.Retries = retry.Retries
+				request.RetryInterval = retry.RetryInterval
+			})
+		})
+
+		var status string
+		if err == nil && res.Status != firmware201.UpdateFirmwareStatusAccepted {
+			status = string(res.Status)
+			op.Finished = time.Now()
+		}
+
+		return a.finishRequest(ledger, op, status, err)
+	}
+
+	_, err = request(func(cb func(*firmware.UpdateFirmwareConfirmation, error)) error {
+		return a.cs.UpdateFirmware(id, cb, location, types.NewDateTime(time.Now()), func(request *firmware.UpdateFirmwareRequest) {
+			request.Retries = retry.Retries
+			request.RetryInterval = retry.RetryInterval
+		})
+	})
+
+	return a.finishRequest(ledger, op, "", err)
+}
+
+// GetDiagnostics requests the station to upload its diagnostics to evcc
+func (a *Admin) GetDiagnostics(id string, retry Retry) (*Operation, error) {
+	protocol, err := a.protocol(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Diagnostics == "" {
+		return nil, errors.New("diagnostics directory not configured")
+	}
+
+	if config.URL == "" {
+		return nil, errors.New("url not configured")
+	}
+
+	location := config.URL + "/ocpp/diagnostics/" + url.PathEscape(id)
+
+	ledger, op, err := a.startOperation(id, OperationDiagnostics, location, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if protocol == ProtocolV201 {
+		res, err := request(func(cb func(*diagnostics.GetLogResponse, error)) error {
+			return a.cs.CSMS.GetLog(id, cb, diagnostics.LogTypeDiagnostics, op.ID, diagnostics.LogParameters{
+				RemoteLocation: location,
+			}, func(request *diagnostics.GetLogRequest) {
+				request.Retries = retry.Retries
+				request.RetryInterval = retry.RetryInterval
+			})
+		})
+
+		var status string
+		if err == nil {
+			op.File = res.Filename
+
+			if res.Status == diagnostics.LogStatusRejected {
+				status = string(res.Status)
+				op.Finished = time.Now()
+			}
+		}
+
+		return a.finishRequest(ledger, op, status, err)
+	}
+
+	res, err := request(func(cb func(*firmware.GetDiagnosticsConfirmation, error)) error {
+		return a.cs.GetDiagnostics(id, cb, location, func(request *firmware.GetDiagnosticsRequest) {
+			request.Retries = retry.Retries
+			request.RetryInterval = retry.RetryInterval
+		})
+	})
+
+	if err == nil {
+		op.File = res.FileName
+	}
+
+	return a.finishRequest(ledger, op, "", err)
+}
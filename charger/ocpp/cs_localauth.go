@@ -0,0 +1,93 @@
+package ocpp
+
+import (
+	"github.com/evcc-io/evcc/util/ocpp201"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/localauth"
+	localauth201 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1/localauth"
+	types201 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// SyncLocalList sends the id tag list to the station if the station's local list version differs.
+// An empty list clears the station's local list.
+func (cs *CS) SyncLocalList(id string) {
+	if cs.protocol(id) == ProtocolV201 {
+		cs.syncLocalList201(id)
+		return
+	}
+
+	if err := cs.GetLocalListVersion(id, func(resp *localauth.GetLocalListVersionConfirmation, err error) {
+		if err != nil {
+			cs.log.DEBUG.Printf("GetLocalListVersion for %s: %v", id, err)
+			return
+		}
+
+		switch {
+		case resp.ListVersion < 0:
+			cs.log.DEBUG.Printf("local list disabled for %s", id)
+			return
+		case resp.ListVersion == authList.Version():
+			return
+		}
+
+		if err := cs.SendLocalList(id, func(resp *localauth.SendLocalListConfirmation, err error) {
+			log := cs.log.DEBUG
+			if err != nil || resp.Status != localauth.UpdateStatusAccepted {
+				log = cs.log.ERROR
+			}
+
+			var status localauth.UpdateStatus
+			if resp != nil {
+				status = resp.Status
+			}
+
+			log.Printf("SendLocalList for %s: %v %v", id, status, err)
+		}, authList.Version(), localauth.UpdateTypeFull, func(request *localauth.SendLocalListRequest) {
+			request.LocalAuthorizationList = authList.LocalList()
+		}); err != nil {
+			cs.log.ERROR.Printf("send SendLocalList for %s failed: %v", id, err)
+		}
+	}); err != nil {
+		cs.log.ERROR.Printf("send GetLocalListVersion for %s failed: %v", id, err)
+	}
+}
+
+func (cs *CS) syncLocalList201(id string) {
+	if err := cs.CSMS.GetLocalListVersion(id, func(resp *localauth201.GetLocalListVersionResponse, err error) {
+		if err != nil {
+			cs.log.DEBUG.Printf("GetLocalListVersion for %s: %v", id, err)
+			return
+		}
+
+		if resp.VersionNumber == authList.Version() {
+			return
+		}
+
+		var list []localauth201.AuthorizationData
+		for _, data := range authList.LocalList() {
+			list = append(list, localauth201.AuthorizationData{
+				IdToken:     types201.IdToken{IdToken: data.IdTag, Type: types201.IdTokenTypeISO14443},
+				IdTokenInfo: ocpp201.IdTokenInfo(data.IdTagInfo),
+			})
+		}
+
+		if err := cs.CSMS.SendLocalList(id, func(resp *localauth201.SendLocalListResponse, err error) {
+			log := cs.log.DEBUG
+			if err != nil || resp.Status != localauth201.SendLocalListStatusAccepted {
+				log = cs.log.ERROR
+			}
+
+			var status localauth201.SendLocalListStatus
+			if resp != nil {
+				status = resp.Status
+			}
+
+			log.Printf("SendLocalList for %s: %v %v", id, status, err)
+		}, authList.Version(), localauth201.UpdateTypeFull, func(request *localauth201.SendLocalListRequest) {
+			request.LocalAuthorizationList = list
+		}); err != nil {
+			cs.log.ERROR.Printf("send SendLocalList for %s failed: %v", id, err)
+		}
+	}); err != nil {
+		cs.log.ERROR.Printf("send GetLocalListVersion for %s failed: %v", id, err)
+	}
+}
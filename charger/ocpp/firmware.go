@@ -0,0 +1,254 @@
+package ocpp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evcc-io/evcc/push"
+	"github.com/evcc-io/evcc/util"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/firmware"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/diagnostics"
+	"github.com/samber/lo"
+)
+
+// operation types, also used as push event names
+const (
+	OperationFirmware    = "firmware"
+	OperationDiagnostics = "diagnostics"
+)
+
+// operation status until the station reports progress or if the request could not be sent
+const (
+	StatusRequested     = "Requested"
+	StatusRequestFailed = "RequestFailed"
+)
+
+// finalStatus completes operations
+var finalStatus = []string{
+	string(firmware.FirmwareStatusInstalled),
+	string(firmware.FirmwareStatusInstallationFailed),
+	string(firmware.FirmwareStatusDownloadFailed),
+	string(firmware.DiagnosticsStatusUploaded),
+	string(firmware.DiagnosticsStatusUploadFailed),
+	string(diagnostics.UploadLogStatusUploadFailure),
+	string(diagnostics.UploadLogStatusBadMessage),
+	string(diagnostics.UploadLogStatusPermissionDenied),
+	string(diagnostics.UploadLogStatusNotSupportedOp),
+	StatusRequestFailed,
+}
+
+const maxDiagnosticsSize = 64 << 20
+
+var (
+	publishMu sync.Mutex
+	uiChan    chan<- util.Param
+	pushChan  chan<- push.Event
+)
+
+// Prepare sets the channels for publishing operation progress to the ui and as push events
+func Prepare(ui chan<- util.Param, events chan<- push.Event) {
+	publishMu.Lock()
+	defer publishMu.Unlock()
+
+	uiChan = ui
+	pushChan = events
+}
+
+// publish sends the operation's progress
+func publish(op Operation) {
+	publishMu.Lock()
+	ui, events := uiChan, pushChan
+	publishMu.Unlock()
+
+	if ui == nil {
+		return
+	}
+
+	go func() {
+		// values are available to the push event's template
+		ui <- util.Param{Key: "ocppStation", Val: op.Station}
+		ui <- util.Param{Key: "ocppOperation", Val: op.Type}
+		ui <- util.Param{Key: "ocppOperationStatus", Val: op.Status}
+
+		if events != nil {
+			events <- push.Event{Event: op.Type}
+		}
+	}()
+}
+
+// setStatus updates the operation's status and completes it if final
+func (op *Operation) setStatus(status string) {
+	op.Status = status
+	op.Updated = time.Now()
+
+	if lo.Contains(finalStatus, status) {
+		op.Finished = op.Updated
+	}
+}
+
+// updateOperation records the station's progress of the operation given by request id (OCPP 2.0.1)
+// or else of the station's latest operation of the type
+func (cp *CP) updateOperation(typ string, requestId *int, status string) {
+	// stations report idle if triggered without operation in progress
+	if status == string(firmware.FirmwareStatusIdle) {
+		return
+	}
+
+	cp.mu.Lock()
+	id, ledger := cp.id, cp.ledger
+	cp.mu.Unlock()
+
+	if ledger == nil {
+		return
+	}
+
+	var (
+		op  *Operation
+		err error
+	)
+
+	if requestId != nil {
+		op, err = ledger.Operation(id, *requestId)
+	} else {
+		op, err = ledger.LatestOperation(id, typ)
+	}
+
+	switch {
+	case err != nil:
+		cp.log.ERROR.Printf("%s status: %v", typ, err)
+		return
+	case op == nil || op.Type != typ:
+		cp.log.WARN.Printf("%s status: unknown operation", typ)
+		return
+	case !op.Finished.IsZero():
+		cp.log.DEBUG.Printf("%s status: operation %d already finished", typ, op.ID)
+		return
+	}
+
+	op.setStatus(status)
+	if err := ledger.SaveOperation(op); err != nil {
+		cp.log.ERROR.Printf("%s status: %v", typ, err)
+	}
+
+	cp.log.DEBUG.Printf("%s status: %s", typ, status)
+
+	publish(*op)
+}
+
+// fileName validates the name against path traversal
+func fileName(name string) (string, error) {
+	if name == "" || name == "." || name == ".." || filepath.Base(name) != name || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid file name: %s", name)
+	}
+
+	return name, nil
+}
+
+// FirmwareFile returns the path of the firmware image served to stations
+func FirmwareFile(name string) (string, error) {
+	if config.Firmware == "" {
+		return "", errors.New("firmware directory not configured")
+	}
+
+	name, err := fileName(name)
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(config.Firmware, name)
+	if fi, err := os.Stat(path); err != nil || fi.IsDir() {
+		return "", fmt.Errorf("firmware %s: %w", name, fs.ErrNotExist)
+	}
+
+	return path, nil
+}
+
+// DiagnosticsFile returns the path of the station's uploaded diagnostics file
+func DiagnosticsFile(id, name string) (string, error) {
+	if config.Diagnostics == "" {
+		return "", errors.New("diagnostics directory not configured")
+	}
+
+	name, err := fileName(name)
+	if err != nil || !strings.HasPrefix(name, id+"-") {
+		return "", fmt.Errorf("diagnostics %s: %w", name, fs.ErrNotExist)
+	}
+
+	path := filepath.Join(config.Diagnostics, name)
+	if fi, err := os.Stat(path); err != nil || fi.IsDir() {
+		return "", fmt.Errorf("diagnostics %s: %w", name, fs.ErrNotExist)
+	}
+
+	return path, nil
+}
+
+// SaveDiagnostics stores the station's diagnostics upload for its unfinished diagnostics operation.
+// Uploads are rejected unless diagnostics have been requested.
+func SaveDiagnostics(id, name string, r io.Reader) (*Operation, error) {
+	if config.Diagnostics == "" {
+		return nil, errors.New("diagnostics directory not configured")
+	}
+
+	ledger, err := TransactionLedger()
+	if err != nil {
+		return nil, err
+	}
+
+	op, err := ledger.LatestOperation(id, OperationDiagnostics)
+	if err != nil {
+		return nil, err
+	}
+
+	if op == nil || !op.Finished.IsZero() {
+		return nil, fmt.Errorf("%w: no diagnostics requested", fs.ErrNotExist)
+	}
+
+	if name, err = fileName(filepath.Base(name)); err != nil {
+		name = OperationDiagnostics
+	}
+
+	// prefix with station and operation to avoid overwriting other uploads
+	name = fmt.Sprintf("%s-%d-%s", id, op.ID, name)
+
+	if err := os.MkdirAll(config.Diagnostics, 0o755); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(config.Diagnostics, name)
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := io.Copy(f, io.LimitReader(r, maxDiagnosticsSize+1))
+	if err == nil && n > maxDiagnosticsSize {
+		err = fmt.Errorf("diagnostics exceed %d bytes", maxDiagnosticsSize)
+	}
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	if err == nil {
+		op.File = name
+		op.Updated = time.Now()
+
+		err = ledger.SaveOperation(op)
+	}
+
+	// don't keep partial or unreferenced uploads
+	if err != nil {
+		_ = os.Remove(path)
+		return nil, err
+	}
+
+	return op, nil
+}
@@ -0,0 +1,132 @@
+package ocpp
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/firmware"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/diagnostics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileName(t *testing.T) {
+	for _, name := range []string{"", ".", "..", "../fw.bin", "dir/fw.bin", `dir\fw.bin`} {
+		_, err := fileName(name)
+		assert.Error(t, err, name)
+	}
+
+	name, err := fileName("fw-1.2.bin")
+	require.NoError(t, err)
+	assert.Equal(t, "fw-1.2.bin", name)
+}
+
+func TestUpdateOperation(t *testing.T) {
+	cp, _ := newTestConnectors(t, 1)
+
+	now := time.Now()
+	op := &Operation{Station: "cp1", Type: OperationFirmware, Status: StatusRequested, Created: now, Updated: now}
+	require.NoError(t, cp.ledger.StartOperation(op))
+
+	// idle doesn't change operations
+	_, err := cp.FirmwareStatusNotification(&firmware.FirmwareStatusNotificationRequest{Status: firmware.FirmwareStatusIdle})
+	require.NoError(t, err)
+
+	for _, status := range []firmware.FirmwareStatus{
+		firmware.FirmwareStatusDownloading,
+		firmware.FirmwareStatusDownloaded,
+		firmware.FirmwareStatusInstalling,
+	} {
+		_, err := cp.FirmwareStatusNotification(&firmware.FirmwareStatusNotificationRequest{Status: status})
+		require.NoError(t, err)
+
+		res, err := cp.ledger.Operation("cp1", op.ID)
+		require.NoError(t, err)
+		assert.Equal(t, string(status), res.Status)
+		assert.True(t, res.Finished.IsZero())
+	}
+
+	_, err = cp.FirmwareStatusNotification(&firmware.FirmwareStatusNotificationRequest{Status: firmware.FirmwareStatusInstalled})
+	require.NoError(t, err)
+
+	res, err := cp.ledger.Operation("cp1", op.ID)
+	require.NoError(t, err)
+	assert.Equal(t, string(firmware.FirmwareStatusInstalled), res.Status)
+	assert.False(t, res.Finished.IsZero())
+
+	// finished operations are not updated
+	_, err = cp.FirmwareStatusNotification(&firmware.FirmwareStatusNotificationRequest{Status: firmware.FirmwareStatusDownloading})
+	require.NoError(t, err)
+
+	res, err = cp.ledger.Operation("cp1", op.ID)
+	require.NoError(t, err)
+	assert.Equal(t, string(firmware.FirmwareStatusInstalled), res.Status)
+
+	// 2.0.1 reports by request id
+	diag := &Operation{Station: "cp1", Type: OperationDiagnostics, Status: StatusRequested, Created: now, Updated: now}
+	require.NoError(t, cp.ledger.StartOperation(diag))
+
+	cp.updateOperation(OperationDiagnostics, &diag.ID, string(diagnostics.UploadLogStatusUploadFailure))
+
+	res, err = cp.ledger.Operation("cp1", diag.ID)
+	require.NoError(t, err)
+	assert.Equal(t, string(diagnostics.UploadLogStatusUploadFailure), res.Status)
+	assert.False(t, res.Finished.IsZero())
+
+	ops, err := cp.ledger.Operations("cp1")
+	require.NoError(t, err)
+	assert.Len(t, ops, 2)
+}
+
+func TestSaveDiagnostics(t *testing.T) {
+	prev := config
+	t.Cleanup(func() { config = prev })
+
+	config.Diagnostics = t.TempDir()
+
+	// uploads require a requested operation
+	_, err := SaveDiagnostics("diag1", "log.zip", strings.NewReader("log"))
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	ledger, err := TransactionLedger()
+	require.NoError(t, err)
+
+	now := time.Now()
+	op := &Operation{Station: "diag1", Type: OperationDiagnostics, Status: StatusRequested, Created: now, Updated: now}
+	require.NoError(t, ledger.StartOperation(op))
+
+	// ledger is shared between test runs
+	t.Cleanup(func() {
+		op.Finished = time.Now()
+		_ = ledger.SaveOperation(op)
+	})
+
+	// oversized uploads are rejected and removed
+	_, err = SaveDiagnostics("diag1", "log.zip", bytes.NewReader(make([]byte, maxDiagnosticsSize+1)))
+	assert.Error(t, err)
+
+	entries, err := os.ReadDir(config.Diagnostics)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	res, err := SaveDiagnostics("diag1", "../log.zip", strings.NewReader("log"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(res.File, "diag1-"))
+
+	path, err := DiagnosticsFile("diag1", res.File)
+	require.NoError(t, err)
+	assert.Equal(t, config.Diagnostics, filepath.Dir(path))
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "log", string(b))
+
+	// other stations' uploads are not served
+	_, err = DiagnosticsFile("diag", res.File)
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
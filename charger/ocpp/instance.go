@@ -1,22 +1,48 @@
 package ocpp
 
 import (
-	"time"
-
 	"github.com/evcc-io/evcc/util"
+	"github.com/evcc-io/evcc/util/ocpprecord"
 	ocpp16 "github.com/lorenzodonini/ocpp-go/ocpp1.6"
+	ocpp2 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1"
+	"github.com/lorenzodonini/ocpp-go/ws"
 )
 
 var instance *CS
 
+// Running returns the central system or nil if not started
+func Running() *CS {
+	return instance
+}
+
 func Instance() *CS {
 	if instance == nil {
-		cs := ocpp16.NewCentralSystem(nil, nil)
+		log := util.NewLogger("ocpp")
+
+		var recorder *ocpprecord.Recorder
+		if config.Record != "" {
+			var err error
+			if recorder, err = ocpprecord.Create(config.Record); err != nil {
+				log.ERROR.Printf("record: %v", err)
+			}
+		}
+
+		// internal servers only accept connections authorized by the dispatching listener
+		token := newToken()
+
+		ws16 := ws.NewServer()
+		ws16.SetCheckOriginHandler(internalOnly(token))
+		cs := ocpp16.NewCentralSystem(nil, recordServer(log, ws16, recorder, ProtocolV16))
+
+		ws201 := ws.NewServer()
+		ws201.SetCheckOriginHandler(internalOnly(token))
+		cs2 := ocpp2.NewCSMS(nil, recordServer(log, ws201, recorder, ProtocolV201))
 
 		instance = &CS{
-			log:           util.NewLogger("ocpp"),
+			log:           log,
 			cps:           make(map[string]*CP),
 			CentralSystem: cs,
+			CSMS:          cs2,
 		}
 
 		// ocppj.SetLogger(instance)
@@ -26,10 +52,35 @@ func Instance() *CS {
 		cs.SetChargePointDisconnectedHandler(instance.ChargePointDisconnected)
 		cs.SetFirmwareManagementHandler(instance)
 
-		go Instance().errorHandler(cs.Errors())
-		go cs.Start(8887, "/{ws}")
+		handler := &csms{cs: instance}
+		cs2.SetAuthorizationHandler(handler)
+		cs2.SetAvailabilityHandler(handler)
+		cs2.SetDiagnosticsHandler(handler)
+		cs2.SetFirmwareHandler(handler)
+		cs2.SetMeterHandler(handler)
+		cs2.SetProvisioningHandler(handler)
+		cs2.SetTransactionsHandler(handler)
+		cs2.SetNewChargingStationHandler(instance.NewChargingStation)
+		cs2.SetChargingStationDisconnectedHandler(instance.ChargingStationDisconnected)
+
+		go instance.errorHandler(cs.Errors())
+		go instance.errorHandler(cs2.Errors())
+
+		// both central systems listen on internal ports, connections are dispatched by subprotocol
+		ports := make(map[string]int)
+		for protocol, start := range map[string]func(int){
+			ProtocolV16:  func(port int) { cs.Start(port, "/{ws}") },
+			ProtocolV201: func(port int) { cs2.Start(port, "/{ws}") },
+		} {
+			port, err := serve(start)
+			if err != nil {
+				log.ERROR.Printf("%s: %v", protocol, err)
+				return instance
+			}
+			ports[protocol] = port
+		}
 
-		time.Sleep(time.Second)
+		go instance.listen(config, ports, token)
 	}
 
 	return instance
@@ -0,0 +1,283 @@
+package ocpp
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	serverdb "github.com/evcc-io/evcc/server/db"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Transaction is a persisted OCPP transaction
+type Transaction struct {
+	ID          int       `json:"id" gorm:"primarykey"`
+	Station     string    `json:"station" gorm:"index:idx_ocpp_station"`
+	Connector   int       `json:"connector" gorm:"index:idx_ocpp_station"`
+	StationTxID string    `json:"stationTxId"` // transaction id assigned by OCPP 2.0.1 stations
+	IdTag       string    `json:"idTag"`
+	MeterStart  int       `json:"meterStart"` // Wh
+	MeterStop   int       `json:"meterStop"`  // Wh
+	Started     time.Time `json:"started"`
+	Finished    time.Time `json:"finished"`
+	Reason      string    `json:"reason"`
+}
+
+// TableName avoids conflicts with the legacy sessions table name
+func (Transaction) TableName() string {
+	return "ocpp_transactions"
+}
+
+// Operation is a persisted firmware update or diagnostics upload
+type Operation struct {
+	ID       int       `json:"id" gorm:"primarykey"` // request id for OCPP 2.0.1 stations
+	Station  string    `json:"station" gorm:"index:idx_ocpp_operation"`
+	Type     string    `json:"type" gorm:"index:idx_ocpp_operation"`
+	Location string    `json:"location"` // firmware download or diagnostics upload url
+	File     string    `json:"file"`     // firmware image or diagnostics file name
+	Status   string    `json:"status"`
+	Created  time.Time `json:"created"`
+	Updated  time.Time `json:"updated"`
+	Finished time.Time `json:"finished"`
+}
+
+// TableName avoids conflicts with other tables
+func (Operation) TableName() string {
+	return "ocpp_operations"
+}
+
+// Reservation is a persisted connector reservation
+type Reservation struct {
+	ID        int       `json:"id" gorm:"primarykey"` // reservation id sent to the station
+	Station   string    `json:"station" gorm:"index:idx_ocpp_reservation"`
+	Connector int       `json:"connector" gorm:"index:idx_ocpp_reservation"`
+	IdTag     string    `json:"idTag"`
+	Expiry    time.Time `json:"expiry"`
+	Status    string    `json:"status"`
+	Created   time.Time `json:"created"`
+	Finished  time.Time `json:"finished"`
+}
+
+// TableName avoids conflicts with other tables
+func (Reservation) TableName() string {
+	return "ocpp_reservations"
+}
+
+// Ledger persists transactions, operations and reservations. Ids are assigned by the database and remain unique across restarts.
+type Ledger struct {
+	mu sync.Mutex
+	db *gorm.DB
+}
+
+// NewLedger creates a transaction ledger. Without database, transactions are kept in memory.
+func NewLedger(db *gorm.DB) (*Ledger, error) {
+	if db == nil {
+		var err error
+		if db, err = gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{
+			Logger: logger.Default.LogMode(logger.Silent),
+		}); err != nil {
+			return nil, err
+		}
+
+		// each connection opens a separate in-memory database
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		sqlDB.SetMaxOpenConns(1)
+	}
+
+	if err := db.AutoMigrate(new(Transaction), new(Operation), new(Reservation)); err != nil {
+		return nil, err
+	}
+
+	return &Ledger{db: db}, nil
+}
+
+var (
+	ledger     *Ledger
+	ledgerOnce sync.Once
+	ledgerErr  error
+)
+
+// TransactionLedger returns the transaction ledger backed by the evcc database
+func TransactionLedger() (*Ledger, error) {
+	ledgerOnce.Do(func() {
+		ledger, ledgerErr = NewLedger(serverdb.Instance)
+	})
+
+	return ledger, ledgerErr
+}
+
+// Start records a new transaction and assigns its id
+func (l *Ledger) Start(txn *Transaction) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	txn.ID = 0
+
+	return l.db.Create(txn).Error
+}
+
+// Stop finishes the transaction
+func (l *Ledger) Stop(id, meterStop int, finished time.Time, reason string) (*Transaction, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var txn Transaction
+	if err := l.db.First(&txn, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("unknown transaction: %d", id)
+		}
+		return nil, err
+	}
+
+	if !txn.Finished.IsZero() {
+		return &txn, fmt.Errorf("transaction already finished: %d", id)
+	}
+
+	txn.MeterStop = meterStop
+	txn.Finished = finished
+	txn.Reason = reason
+
+	return &txn, l.db.Save(&txn).Error
+}
+
+// Transaction returns the transaction by id
+func (l *Ledger) Transaction(id int) (*Transaction, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var txn Transaction
+	if err := l.db.First(&txn, id).Error; err != nil {
+		return nil, err
+	}
+
+	return &txn, nil
+}
+
+// StationTransaction returns the transaction by the station-assigned transaction id or nil if not found
+func (l *Ledger) StationTransaction(station, stationTxID string) (*Transaction, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var txns []Transaction
+	if err := l.db.Where("station = ? AND station_tx_id = ?", station, stationTxID).Order("id desc").Limit(1).Find(&txns).Error; err != nil || len(txns) == 0 {
+		return nil, err
+	}
+
+	return &txns[0], nil
+}
+
+// Active returns the station connector's latest unfinished transaction or nil if none
+func (l *Ledger) Active(station string, connector int) (*Transaction, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var txns []Transaction
+	if err := l.db.Where("station = ? AND connector = ? AND finished = ?", station, connector, time.Time{}).Order("id desc").Limit(1).Find(&txns).Error; err != nil || len(txns) == 0 {
+		return nil, err
+	}
+
+	return &txns[0], nil
+}
+
+// Transactions returns the station's transactions, latest first
+func (l *Ledger) Transactions(station string) ([]Transaction, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var txns []Transaction
+	err := l.db.Where("station = ?", station).Order("id desc").Find(&txns).Error
+
+	return txns, err
+}
+
+// StartOperation records a new operation and assigns its id
+func (l *Ledger) StartOperation(op *Operation) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	op.ID = 0
+
+	return l.db.Create(op).Error
+}
+
+// SaveOperation updates the operation
+func (l *Ledger) SaveOperation(op *Operation) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.db.Save(op).Error
+}
+
+// Operation returns the station's operation by id or nil if not found
+func (l *Ledger) Operation(station string, id int) (*Operation, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var ops []Operation
+	if err := l.db.Where("station = ? AND id = ?", station, id).Limit(1).Find(&ops).Error; err != nil || len(ops) == 0 {
+		return nil, err
+	}
+
+	return &ops[0], nil
+}
+
+// LatestOperation returns the station's latest operation of given type or nil if none
+func (l *Ledger) LatestOperation(station, typ string) (*Operation, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var ops []Operation
+	if err := l.db.Where("station = ? AND type = ?", station, typ).Order("id desc").Limit(1).Find(&ops).Error; err != nil || len(ops) == 0 {
+		return nil, err
+	}
+
+	return &ops[0], nil
+}
+
+// Operations returns the station's operations, latest first
+func (l *Ledger) Operations(station string) ([]Operation, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var ops []Operation
+	err := l.db.Where("station = ?", station).Order("id desc").Find(&ops).Error
+
+	return ops, err
+}
+
+// StartReservation records a new reservation and assigns its id
+func (l *Ledger) StartReservation(res *Reservation) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	res.ID = 0
+
+	return l.db.Create(res).Error
+}
+
+// SaveReservation updates the reservation
+func (l *Ledger) SaveReservation(res *Reservation) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.db.Save(res).Error
+}
+
+// ActiveReservation returns the connector's unfinished reservation or nil if none
+func (l *Ledger) ActiveReservation(station string, connector int) (*Reservation, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var res []Reservation
+	if err := l.db.Where("station = ? AND connector = ? AND finished = ?", station, connector, time.Time{}).Order("id desc").Limit(1).Find(&res).Error; err != nil || len(res) == 0 {
+		return nil, err
+	}
+
+	return &res[0], nil
+}
@@ -0,0 +1,168 @@
+package ocpp
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/evcc-io/evcc/util"
+	"github.com/glebarez/sqlite"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestLedger(t *testing.T) {
+	l, err := NewLedger(nil)
+	require.NoError(t, err)
+
+	txn := &Transaction{Station: "cp1", Connector: 1, IdTag: "tag", MeterStart: 1000, Started: time.Now()}
+	require.NoError(t, l.Start(txn))
+	assert.NotZero(t, txn.ID)
+
+	active, err := l.Active("cp1", 1)
+	require.NoError(t, err)
+	require.NotNil(t, active)
+	assert.Equal(t, txn.ID, active.ID)
+
+	active, err = l.Active("cp1", 2)
+	require.NoError(t, err)
+	assert.Nil(t, active)
+
+	res, err := l.Stop(txn.ID, 3000, time.Now(), string(core.ReasonEVDisconnected))
+	require.NoError(t, err)
+	assert.Equal(t, 3000, res.MeterStop)
+	assert.Equal(t, "tag", res.IdTag)
+
+	_, err = l.Stop(txn.ID, 3000, time.Now(), "")
+	assert.Error(t, err, "already finished")
+
+	_, err = l.Stop(txn.ID+1, 3000, time.Now(), "")
+	assert.Error(t, err, "unknown")
+
+	active, err = l.Active("cp1", 1)
+	require.NoError(t, err)
+	assert.Nil(t, active)
+
+	txns, err := l.Transactions("cp1")
+	require.NoError(t, err)
+	assert.Len(t, txns, 1)
+}
+
+func TestLedgerPersistence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "evcc.db")
+
+	var ids []int
+	for i := 0; i < 2; i++ {
+		db, err := gorm.Open(sqlite.Open(file), new(gorm.Config))
+		require.NoError(t, err)
+
+		l, err := NewLedger(db)
+		require.NoError(t, err)
+
+		txn := &Transaction{Station: "cp1", StationTxID: "abc", Started: time.Now()}
+		require.NoError(t, l.Start(txn))
+		ids = append(ids, txn.ID)
+
+		res, err := l.StationTransaction("cp1", "abc")
+		require.NoError(t, err)
+		require.NotNil(t, res)
+		assert.Equal(t, txn.ID, res.ID)
+
+		sqlDB, _ := db.DB()
+		require.NoError(t, sqlDB.Close())
+	}
+
+	assert.Less(t, ids[0], ids[1])
+}
+
+func TestRestoreTransaction(t *testing.T) {
+	l, err := NewLedger(nil)
+	require.NoError(t, err)
+
+	cp := NewChargePoint(util.NewLogger("foo"), "cp1", time.Minute)
+	cp.ledger = l
+
+	conn, err := cp.RegisterConnector(1)
+	require.NoError(t, err)
+
+	res, err := cp.StartTransaction(&core.StartTransactionRequest{
+		ConnectorId: 1,
+		IdTag:       "tag",
+		MeterStart:  100,
+		Timestamp:   types.NewDateTime(time.Now()),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, res.TransactionId, conn.TransactionID())
+
+	// restart
+	cp = NewChargePoint(util.NewLogger("foo"), "cp1", time.Minute)
+	cp.ledger = l
+
+	conn, err = cp.RegisterConnector(1)
+	require.NoError(t, err)
+	conn.status = &core.StatusNotificationRequest{ConnectorId: 1, Status: core.ChargePointStatusAvailable}
+
+	require.NoError(t, conn.RestoreTransaction())
+	assert.Zero(t, conn.TransactionID(), "not charging")
+
+	conn.status.Status = core.ChargePointStatusCharging
+	require.NoError(t, conn.RestoreTransaction())
+	assert.Equal(t, res.TransactionId, conn.TransactionID())
+
+	_, err = cp.StopTransaction(&core.StopTransactionRequest{
+		TransactionId: res.TransactionId,
+		MeterStop:     200,
+		Timestamp:     types.NewDateTime(time.Now()),
+	})
+	require.NoError(t, err)
+	assert.Zero(t, conn.TransactionID())
+
+	txn, err := l.Transaction(res.TransactionId)
+	require.NoError(t, err)
+	assert.Equal(t, 200, txn.MeterStop)
+	assert.False(t, txn.Finished.IsZero())
+}
+
+func TestTransactionState(t *testing.T) {
+	cp, conns := newTestConnectors(t, 1)
+
+	// transactions started while offline are tracked regardless of their age
+	old, err := cp.StartTransaction(&core.StartTransactionRequest{
+		ConnectorId: 1,
+		Timestamp:   types.NewDateTime(time.Now().Add(-2 * time.Hour)),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, old.TransactionId, conns[0].TransactionID())
+
+	// stopping an unknown transaction doesn't affect the active one
+	_, err = cp.StopTransaction(&core.StopTransactionRequest{
+		TransactionId: old.TransactionId + 100,
+		Timestamp:     types.NewDateTime(time.Now()),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, old.TransactionId, conns[0].TransactionID())
+
+	// stopping a replaced transaction doesn't affect the active one
+	res, err := cp.StartTransaction(&core.StartTransactionRequest{
+		ConnectorId: 1,
+		Timestamp:   types.NewDateTime(time.Now()),
+	})
+	require.NoError(t, err)
+
+	_, err = cp.StopTransaction(&core.StopTransactionRequest{
+		TransactionId: old.TransactionId,
+		Timestamp:     types.NewDateTime(time.Now().Add(-time.Hour)),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, res.TransactionId, conns[0].TransactionID())
+
+	_, err = cp.StopTransaction(&core.StopTransactionRequest{
+		TransactionId: res.TransactionId,
+		Timestamp:     types.NewDateTime(time.Now().Add(-2 * time.Hour)),
+	})
+	require.NoError(t, err)
+	assert.Zero(t, conns[0].TransactionID())
+}
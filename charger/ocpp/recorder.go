@@ -0,0 +1,51 @@
+package ocpp
+
+import (
+	"github.com/evcc-io/evcc/util"
+	"github.com/evcc-io/evcc/util/ocpprecord"
+	"github.com/lorenzodonini/ocpp-go/ws"
+)
+
+// recordingServer records all OCPP-J frames exchanged by the websocket server
+type recordingServer struct {
+	ws.WsServer
+	log      *util.Logger
+	recorder *ocpprecord.Recorder
+	protocol string
+}
+
+// recordServer wraps the websocket server for recording if enabled
+func recordServer(log *util.Logger, server ws.WsServer, recorder *ocpprecord.Recorder, protocol string) ws.WsServer {
+	if recorder == nil {
+		return server
+	}
+
+	return &recordingServer{
+		WsServer: server,
+		log:      log,
+		recorder: recorder,
+		protocol: protocol,
+	}
+}
+
+func (s *recordingServer) record(id string, dir ocpprecord.Direction, data []byte) {
+	if err := s.recorder.Record(id, s.protocol, dir, data); err != nil {
+		s.log.ERROR.Printf("record: %v", err)
+	}
+}
+
+func (s *recordingServer) SetMessageHandler(handler func(ws ws.Channel, data []byte) error) {
+	s.WsServer.SetMessageHandler(func(ws ws.Channel, data []byte) error {
+		s.record(ws.ID(), ocpprecord.In, data)
+		return handler(ws, data)
+	})
+}
+
+func (s *recordingServer) Write(webSocketId string, data []byte) error {
+	err := s.WsServer.Write(webSocketId, data)
+	if err == nil {
+		s.record(webSocketId, ocpprecord.Out, data)
+	}
+
+	return err
+}
@@ -0,0 +1,205 @@
+package ocpp
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/reservation"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+)
+
+// reservation status beyond the station's ReserveNow response
+const (
+	ReservationUsed      = "Used"      // transaction started with the reservation
+	ReservationCancelled = "Cancelled" // cancelled by evcc
+	ReservationExpired   = "Expired"   // expired before use
+	ReservationRemoved   = "Removed"   // removed by the station before expiry
+)
+
+const maxIdTagLength = 20
+
+// Reservation returns the connector's active reservation or nil if none
+func (conn *Connector) Reservation() *Reservation {
+	conn.cp.mu.Lock()
+	defer conn.cp.mu.Unlock()
+
+	if res := conn.activeReservation(); res != nil {
+		res := *res
+		return &res
+	}
+
+	return nil
+}
+
+// activeReservation returns the active reservation and finishes it if expired. Lock must be held.
+func (conn *Connector) activeReservation() *Reservation {
+	if conn.reservation != nil && time.Now().After(conn.reservation.Expiry) {
+		conn.finishReservation(ReservationExpired)
+	}
+
+	return conn.reservation
+}
+
+// finishReservation finishes the active reservation. Lock must be held.
+func (conn *Connector) finishReservation(status string) {
+	res := conn.reservation
+	if res == nil {
+		return
+	}
+
+	conn.reservation = nil
+
+	res.Status = status
+	res.Finished = time.Now()
+
+	if err := conn.cp.ledger.SaveReservation(res); err != nil {
+		conn.cp.log.ERROR.Printf("save reservation: %v", err)
+	}
+
+	conn.cp.log.DEBUG.Printf("connector %d reservation %d: %s", conn.id, res.ID, status)
+}
+
+// updateReservation finishes the active reservation if the connector is no longer reserved. Lock must be held.
+func (conn *Connector) updateReservation(prev core.ChargePointStatus) {
+	if prev != core.ChargePointStatusReserved || conn.status.Status != core.ChargePointStatusAvailable || conn.reservation == nil {
+		return
+	}
+
+	if time.Now().Before(conn.reservation.Expiry) {
+		conn.finishReservation(ReservationRemoved)
+	} else {
+		conn.finishReservation(ReservationExpired)
+	}
+}
+
+// useReservation finishes the active reservation if the transaction was started with it. Lock must be held.
+func (conn *Connector) useReservation(id *int) {
+	if id != nil && conn.reservation != nil && conn.reservation.ID == *id {
+		conn.finishReservation(ReservationUsed)
+	}
+}
+
+// RestoreReservation recognizes the connector's reservation still active after restart
+func (conn *Connector) RestoreReservation() error {
+	conn.cp.mu.Lock()
+	defer conn.cp.mu.Unlock()
+
+	if conn.reservation != nil {
+		return nil
+	}
+
+	res, err := conn.cp.ledger.ActiveReservation(conn.cp.id, conn.id)
+	if err != nil || res == nil {
+		return err
+	}
+
+	conn.cp.log.DEBUG.Printf("restored reservation: %d", res.ID)
+
+	conn.reservation = res
+	conn.activeReservation()
+
+	return nil
+}
+
+// Reserve requests the station to reserve the connector for the id tag until expiry
+func (conn *Connector) Reserve(idTag string, expiry time.Time) error {
+	// ocpp-go models the request's id token by its type only, hence the id tag cannot be sent
+	if conn.cp.Protocol() == ProtocolV201 {
+		return errors.New("reservation not supported for OCPP 2.0.1")
+	}
+
+	if idTag == "" || len(idTag) > maxIdTagLength {
+		return fmt.Errorf("invalid id tag: %s", idTag)
+	}
+
+	if !expiry.After(time.Now()) {
+		return errors.New("expiry is in the past")
+	}
+
+	conn.cp.mu.Lock()
+	active := conn.activeReservation()
+	conn.cp.mu.Unlock()
+
+	if active != nil {
+		return fmt.Errorf("connector already reserved: %d", active.ID)
+	}
+
+	res := &Reservation{
+		Station:   conn.cp.ID(),
+		Connector: conn.id,
+		IdTag:     idTag,
+		Expiry:    expiry,
+		Status:    StatusRequested,
+		Created:   time.Now(),
+	}
+
+	if err := conn.cp.ledger.StartReservation(res); err != nil {
+		return fmt.Errorf("start reservation: %w", err)
+	}
+
+	resp, err := request(func(cb func(*reservation.ReserveNowConfirmation, error)) error {
+		return Instance().ReserveNow(res.Station, cb, conn.id, types.NewDateTime(expiry), idTag, res.ID)
+	})
+
+	switch {
+	case err != nil:
+		res.Status = StatusRequestFailed
+	case resp.Status != reservation.ReservationStatusAccepted:
+		res.Status = string(resp.Status)
+		err = errors.New(res.Status)
+	default:
+		res.Status = string(resp.Status)
+	}
+
+	if err != nil {
+		res.Finished = time.Now()
+	}
+
+	if serr := conn.cp.ledger.SaveReservation(res); serr != nil {
+		conn.cp.log.ERROR.Printf("save reservation: %v", serr)
+	}
+
+	if err != nil {
+		return fmt.Errorf("reserve: %w", err)
+	}
+
+	conn.cp.mu.Lock()
+	conn.reservation = res
+	conn.cp.mu.Unlock()
+
+	return nil
+}
+
+// CancelReservation requests the station to cancel the connector's active reservation
+func (conn *Connector) CancelReservation() error {
+	conn.cp.mu.Lock()
+	res := conn.activeReservation()
+	conn.cp.mu.Unlock()
+
+	if res == nil {
+		return nil
+	}
+
+	resp, err := request(func(cb func(*reservation.CancelReservationConfirmation, error)) error {
+		return Instance().CancelReservation(res.Station, cb, res.ID)
+	})
+	if err != nil {
+		return fmt.Errorf("cancel reservation: %w", err)
+	}
+
+	// rejected if the station doesn't know the reservation anymore
+	if resp.Status != reservation.CancelReservationStatusAccepted {
+		conn.cp.log.DEBUG.Printf("cancel reservation %d: %s", res.ID, resp.Status)
+	}
+
+	conn.cp.mu.Lock()
+	defer conn.cp.mu.Unlock()
+
+	if conn.reservation != nil && conn.reservation.ID == res.ID {
+		conn.finishReservation(ReservationCancelled)
+	}
+
+	return nil
+}
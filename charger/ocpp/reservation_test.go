@@ -0,0 +1,108 @@
+package ocpp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/evcc-io/evcc/api"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// reserve records an accepted reservation without sending it to the station
+func reserve(t *testing.T, conn *Connector, idTag string, expiry time.Time) *Reservation {
+	res := &Reservation{
+		Station:   conn.cp.ID(),
+		Connector: conn.id,
+		IdTag:     idTag,
+		Expiry:    expiry,
+		Status:    "Accepted",
+		Created:   time.Now(),
+	}
+	require.NoError(t, conn.cp.ledger.StartReservation(res))
+
+	conn.cp.mu.Lock()
+	conn.reservation = res
+	conn.cp.mu.Unlock()
+
+	return res
+}
+
+func TestReservationStatus(t *testing.T) {
+	cp, conns := newTestConnectors(t, 1)
+
+	_, err := cp.StatusNotification(&core.StatusNotificationRequest{ConnectorId: 1, ErrorCode: core.NoError, Status: core.ChargePointStatusReserved})
+	require.NoError(t, err)
+
+	status, err := conns[0].Status()
+	require.NoError(t, err)
+	assert.Equal(t, api.StatusA, status)
+}
+
+func TestReservationUsed(t *testing.T) {
+	cp, conns := newTestConnectors(t, 1, 2)
+	res := reserve(t, conns[0], "card", time.Now().Add(time.Hour))
+
+	// other connectors' transactions don't use the reservation
+	_, err := cp.StartTransaction(&core.StartTransactionRequest{ConnectorId: 2, IdTag: "card", Timestamp: types.NewDateTime(time.Now())})
+	require.NoError(t, err)
+	require.NotNil(t, conns[0].Reservation())
+
+	_, err = cp.StartTransaction(&core.StartTransactionRequest{ConnectorId: 1, IdTag: "card", ReservationId: &res.ID, Timestamp: types.NewDateTime(time.Now())})
+	require.NoError(t, err)
+	assert.Nil(t, conns[0].Reservation())
+
+	active, err := cp.ledger.ActiveReservation("cp1", 1)
+	require.NoError(t, err)
+	assert.Nil(t, active)
+}
+
+func TestReservationRemoved(t *testing.T) {
+	cp, conns := newTestConnectors(t, 1)
+	res := reserve(t, conns[0], "card", time.Now().Add(time.Hour))
+
+	for _, status := range []core.ChargePointStatus{core.ChargePointStatusReserved, core.ChargePointStatusAvailable} {
+		_, err := cp.StatusNotification(&core.StatusNotificationRequest{ConnectorId: 1, ErrorCode: core.NoError, Status: status})
+		require.NoError(t, err)
+	}
+
+	assert.Nil(t, conns[0].Reservation())
+	assert.Equal(t, ReservationRemoved, res.Status)
+	assert.False(t, res.Finished.IsZero())
+}
+
+func TestReservationRestore(t *testing.T) {
+	_, conns := newTestConnectors(t, 1)
+	reserve(t, conns[0], "card", time.Now().Add(time.Hour))
+
+	// forget reservation as after restart
+	conns[0].cp.mu.Lock()
+	conns[0].reservation = nil
+	conns[0].cp.mu.Unlock()
+
+	require.NoError(t, conns[0].RestoreReservation())
+
+	res := conns[0].Reservation()
+	require.NotNil(t, res)
+	assert.Equal(t, "card", res.IdTag)
+
+	// expired reservations are finished
+	conns[0].cp.mu.Lock()
+	conns[0].reservation.Expiry = time.Now().Add(-time.Minute)
+	conns[0].cp.mu.Unlock()
+
+	assert.Nil(t, conns[0].Reservation())
+}
+
+func TestReserveValidation(t *testing.T) {
+	cp, conns := newTestConnectors(t, 1)
+
+	assert.Error(t, conns[0].Reserve("", time.Now().Add(time.Hour)))
+	assert.Error(t, conns[0].Reserve("012345678901234567890", time.Now().Add(time.Hour)))
+	assert.Error(t, conns[0].Reserve("card", time.Now().Add(-time.Minute)))
+
+	cp.protocol = ProtocolV201
+	assert.Error(t, conns[0].Reserve("card", time.Now().Add(time.Hour)))
+}
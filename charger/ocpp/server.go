@@ -0,0 +1,182 @@
+package ocpp
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// internalHeader carries the token proving that a connection to an internal server has been authorized by listen
+const internalHeader = "X-Ocpp-Internal"
+
+// newToken returns a random token for authorizing dispatched connections
+func newToken() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// internalOnly rejects connections to the internal servers that have not been dispatched by listen.
+// The internal servers cannot be bound to loopback, hence stations connecting directly must be refused.
+func internalOnly(token string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if ip := net.ParseIP(host); err != nil || ip == nil || !ip.IsLoopback() {
+			return false
+		}
+
+		return subtle.ConstantTimeCompare([]byte(r.Header.Get(internalHeader)), []byte(token)) == 1
+	}
+}
+
+// listen dispatches websocket connections to the OCPP 1.6 or 2.0.1 central system by subprotocol.
+// Charge points offering OCPP 1.6 or no subprotocol at all are handled by the OCPP 1.6 central system.
+// Stations are authorized according to the configured security profile before being dispatched with the internal token.
+func (cs *CS) listen(conf Config, ports map[string]int, token string) {
+	tlsConfig, err := conf.tlsConfig()
+	if err != nil {
+		cs.log.ERROR.Println(err)
+		return
+	}
+
+	proxies := make(map[string]*httputil.ReverseProxy)
+
+	for protocol, port := range ports {
+		proxy := httputil.NewSingleHostReverseProxy(&url.URL{
+			Scheme: "http",
+			Host:   fmt.Sprintf("127.0.0.1:%d", port),
+		})
+		proxy.ErrorLog = cs.log.ERROR
+
+		proxies[protocol] = proxy
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := conf.stationID(r.URL.Path)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		if err := conf.authorize(id, r); err != nil {
+			cs.log.WARN.Printf("connection from %s rejected: %s: %v", r.RemoteAddr, id, err)
+
+			if conf.Profile == ProfileBasicAuth || conf.Profile == ProfileTLSBasicAuth {
+				w.Header().Set("WWW-Authenticate", `Basic realm="ocpp"`)
+			}
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+
+			return
+		}
+
+		// internal central systems listen on the root path
+		r.URL.Path = "/" + id
+		r.URL.RawPath = ""
+		r.Header.Set(internalHeader, token)
+
+		protocol := ProtocolV16
+
+		offered := websocket.Subprotocols(r)
+		for _, p := range offered {
+			if p == ProtocolV16 {
+				protocol = ProtocolV16
+				break
+			}
+			if p == ProtocolV201 {
+				protocol = ProtocolV201
+			}
+		}
+
+		cs.log.TRACE.Printf("connection from %s offering %v: %s", r.RemoteAddr, offered, protocol)
+
+		proxies[protocol].ServeHTTP(w, r)
+	})
+
+	srv := &http.Server{
+		Addr:      fmt.Sprintf(":%d", conf.Port),
+		Handler:   handler,
+		TLSConfig: tlsConfig,
+	}
+
+	if tlsConfig != nil {
+		err = srv.ListenAndServeTLS("", "")
+	} else {
+		err = srv.ListenAndServe()
+	}
+
+	cs.log.ERROR.Println(err)
+}
+
+// freePort returns a loopback port that is unused at the time of calling
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// serveAttempts is the number of ports tried for starting an internal server
+const serveAttempts = 3
+
+// serve starts an internal server on a free loopback port and waits until it accepts connections.
+// The port may be taken by another process before the server binds it, in which case start returns
+// early and the next port is tried. Returns the server's port.
+func serve(start func(port int)) (int, error) {
+	for i := 0; i < serveAttempts; i++ {
+		port, err := freePort()
+		if err != nil {
+			return 0, err
+		}
+
+		done := make(chan struct{})
+		go func() {
+			start(port)
+			close(done)
+		}()
+
+		if err := waitListening(port, done); err == nil {
+			return port, nil
+		}
+	}
+
+	return 0, fmt.Errorf("server not started after %d attempts", serveAttempts)
+}
+
+// waitListening waits for the internal websocket server to accept connections on the loopback port.
+// Done is closed once the server has stopped, e.g. if it could not bind the port.
+func waitListening(port int, done <-chan struct{}) error {
+	addr := fmt.Sprintf("127.0.0.1:%d", port)
+
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		select {
+		case <-done:
+			return fmt.Errorf("server on port %d stopped", port)
+		default:
+		}
+
+		if conn, err := net.DialTimeout("tcp", addr, time.Second); err == nil {
+			_ = conn.Close()
+
+			// connection may have been accepted by another process holding the port
+			select {
+			case <-done:
+				return fmt.Errorf("server on port %d stopped", port)
+			default:
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("server not listening on port %d", port)
+}
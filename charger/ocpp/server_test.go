@@ -0,0 +1,81 @@
+package ocpp
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/lorenzodonini/ocpp-go/ws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInternalOnly(t *testing.T) {
+	check := internalOnly("secret")
+
+	for _, tc := range []struct {
+		remote, token string
+		ok            bool
+	}{
+		{"192.0.2.1:1234", "secret", false},
+		{"127.0.0.1:1234", "", false},
+		{"127.0.0.1:1234", "foo", false},
+		{"127.0.0.1:1234", "secret", true},
+		{"[::1]:1234", "secret", true},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/cp1", nil)
+		r.RemoteAddr = tc.remote
+		if tc.token != "" {
+			r.Header.Set(internalHeader, tc.token)
+		}
+
+		assert.Equal(t, tc.ok, check(r), tc)
+	}
+}
+
+func TestInternalServerRejectsDirectConnections(t *testing.T) {
+	server := ws.NewServer()
+	server.SetCheckOriginHandler(internalOnly("secret"))
+	t.Cleanup(server.Stop)
+
+	port, err := serve(func(port int) { server.Start(port, "/{ws}") })
+	require.NoError(t, err)
+
+	uri := fmt.Sprintf("ws://127.0.0.1:%d/cp1", port)
+
+	// station bypassing the dispatching listener
+	_, res, err := websocket.DefaultDialer.Dial(uri, nil)
+	require.Error(t, err)
+	require.NotNil(t, res)
+	assert.Equal(t, http.StatusForbidden, res.StatusCode)
+
+	// connection dispatched by the listener
+	header := http.Header{internalHeader: []string{"secret"}}
+	conn, _, err := websocket.DefaultDialer.Dial(uri, header)
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+}
+
+func TestServeRetries(t *testing.T) {
+	server := ws.NewServer()
+	t.Cleanup(server.Stop)
+
+	// first port taken by another process
+	var attempts int32
+	port, err := serve(func(port int) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			return
+		}
+		server.Start(port, "/{ws}")
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+	assert.NotZero(t, port)
+
+	// server never starts
+	_, err = serve(func(int) {})
+	assert.Error(t, err)
+}
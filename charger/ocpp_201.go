@@ -0,0 +1,154 @@
+package charger
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/evcc-io/evcc/charger/ocpp"
+	"github.com/evcc-io/evcc/util/ocpp201"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/remotecontrol"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/smartcharging"
+	types201 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// getVariables reads the charging station's device model variables corresponding to the OCPP 1.6 configuration keys
+func (c *OCPP) getVariables(meterSampleInterval *time.Duration) error {
+	var data []provisioning.GetVariableData
+	for _, v := range ocpp201.Variables {
+		data = append(data, provisioning.GetVariableData{
+			Component: v.Component,
+			Variable:  v.Variable,
+		})
+	}
+
+	rc := make(chan error, 1)
+
+	err := ocpp.Instance().CSMS.GetVariables(c.cp.ID(), func(resp *provisioning.GetVariablesResponse, err error) {
+		if err == nil {
+			for _, res := range resp.GetVariableResult {
+				if res.AttributeStatus != provisioning.GetVariableStatusAccepted {
+					c.log.DEBUG.Printf("%s.%s: %s", res.Component.Name, res.Variable.Name, res.AttributeStatus)
+					continue
+				}
+
+				c.log.TRACE.Printf("%s.%s: %s", res.Component.Name, res.Variable.Name, res.AttributeValue)
+
+				if key, ok := ocpp201.Key(res.Component, res.Variable); ok {
+					if err = c.applyConfiguration(key, res.AttributeValue, meterSampleInterval); err != nil {
+						break
+					}
+				}
+			}
+		}
+
+		rc <- err
+	}, data)
+
+	return c.wait(err, rc)
+}
+
+// setVariable updates the device model variable corresponding to the OCPP 1.6 configuration key
+func (c *OCPP) setVariable(key, val string) error {
+	v, ok := ocpp201.Variables[key]
+	if !ok {
+		return fmt.Errorf("unsupported configuration key: %s", key)
+	}
+
+	rc := make(chan error, 1)
+
+	err := ocpp.Instance().CSMS.SetVariables(c.cp.ID(), func(resp *provisioning.SetVariablesResponse, err error) {
+		c.log.TRACE.Printf("%T: %+v", resp, resp)
+
+		if err == nil && resp != nil {
+			for _, res := range resp.SetVariableResult {
+				if res.AttributeStatus != provisioning.SetVariableStatusAccepted {
+					err = fmt.Errorf("SetVariables failed: %s", res.AttributeStatus)
+				}
+			}
+		}
+
+		rc <- err
+	}, []provisioning.SetVariableData{{
+		Component:      v.Component,
+		Variable:       v.Variable,
+		AttributeValue: val,
+	}})
+
+	return c.wait(err, rc)
+}
+
+// enable201 starts or stops the transaction
+func (c *OCPP) enable201(enable bool) error {
+	var err error
+	rc := make(chan error, 1)
+
+	if enable {
+		// ocpp-go models the request's id token by its type only, hence the id tag cannot be sent
+		err = ocpp.Instance().CSMS.RequestStartTransaction(c.cp.ID(), func(resp *remotecontrol.RequestStartTransactionResponse, err error) {
+			c.log.TRACE.Printf("%T: %+v", resp, resp)
+
+			if err == nil && resp != nil && resp.Status != remotecontrol.RequestStartStopStatusAccepted {
+				err = errors.New(string(resp.Status))
+			}
+
+			rc <- err
+		}, int(time.Now().Unix()), types201.IdTokenTypeCentral, func(request *remotecontrol.RequestStartTransactionRequest) {
+			request.EvseID = &c.connector
+			request.ChargingProfile = getTxChargingProfile201(c.current, c.phases, "")
+		})
+	} else {
+		err = ocpp.Instance().CSMS.RequestStopTransaction(c.cp.ID(), func(resp *remotecontrol.RequestStopTransactionResponse, err error) {
+			c.log.TRACE.Printf("%T: %+v", resp, resp)
+
+			if err == nil && resp != nil && resp.Status != remotecontrol.RequestStartStopStatusAccepted {
+				err = errors.New(string(resp.Status))
+			}
+
+			rc <- err
+		}, c.conn.StationTransactionID())
+	}
+
+	return c.wait(err, rc)
+}
+
+func (c *OCPP) setChargingProfile201(profile *types201.ChargingProfile) error {
+	c.log.TRACE.Printf("SetChargingProfileRequest: %+v (%+v)", profile, profile.ChargingSchedule)
+
+	rc := make(chan error, 1)
+	err := ocpp.Instance().CSMS.SetChargingProfile(c.cp.ID(), func(resp *smartcharging.SetChargingProfileResponse, err error) {
+		c.log.TRACE.Printf("%T: %+v", resp, resp)
+
+		if err == nil && resp != nil && resp.Status != smartcharging.ChargingProfileStatusAccepted {
+			err = errors.New(string(resp.Status))
+		}
+
+		rc <- err
+	}, c.connector, profile)
+
+	return c.wait(err, rc)
+}
+
+func getTxChargingProfile201(current float64, phases int, transactionId string) *types201.ChargingProfile {
+	period := types201.NewChargingSchedulePeriod(0, current)
+	if phases != 0 {
+		period.NumberPhases = &phases
+	}
+
+	profile := types201.NewChargingProfile(1, 0, types201.ChargingProfilePurposeTxProfile, types201.ChargingProfileKindRelative, []types201.ChargingSchedule{
+		*types201.NewChargingSchedule(1, types201.ChargingRateUnitAmperes, period),
+	})
+	profile.TransactionID = transactionId
+
+	return profile
+}
+
+// getTxDefaultChargingProfile201 returns the default profile
+func getTxDefaultChargingProfile201(current float64, phases int) *types201.ChargingProfile {
+	profile := getTxChargingProfile201(current, phases, "")
+	profile.ID = 2
+	profile.ChargingProfilePurpose = types201.ChargingProfilePurposeTxDefaultProfile
+
+	return profile
+}
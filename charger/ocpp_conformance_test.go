@@ -0,0 +1,558 @@
+package charger
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/evcc-io/evcc/api"
+	"github.com/evcc-io/evcc/charger/ocpp"
+	"github.com/gorilla/websocket"
+	ocppj "github.com/lorenzodonini/ocpp-go/ocpp"
+	ocpp16 "github.com/lorenzodonini/ocpp-go/ocpp1.6"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/remotetrigger"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/smartcharging"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testMeterValues = "Power.Active.Import,Energy.Active.Import.Register"
+	testTimeout     = 5 * time.Second
+)
+
+var (
+	testCSOnce sync.Once
+	testCSPort int
+)
+
+// testCentralSystem starts the central system on a free loopback port once for all tests
+func testCentralSystem(t *testing.T) int {
+	testCSOnce.Do(func() {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		testCSPort = l.Addr().(*net.TCPAddr).Port
+		require.NoError(t, l.Close())
+
+		require.NoError(t, ocpp.Configure(ocpp.Config{Port: testCSPort}))
+		ocpp.Instance()
+	})
+
+	return testCSPort
+}
+
+// testStation is a simulated OCPP 1.6 charge point with a single connector
+type testStation struct {
+	ocpp16.ChargePoint
+	t  *testing.T
+	id string
+
+	mu       sync.Mutex
+	config   map[string]string
+	reject   map[string]bool // rejected configuration keys
+	delay    time.Duration   // response delay
+	received []ocppj.Request
+	txnId    int
+}
+
+func newTestStation(t *testing.T, id string) *testStation {
+	st := &testStation{
+		ChargePoint: ocpp16.NewChargePoint(id, nil, nil),
+		t:           t,
+		id:          id,
+		config: map[string]string{
+			ocpp.KeyNumberOfConnectors:       "1",
+			ocpp.KeyMeterValuesSampledData:   "Energy.Active.Import.Register",
+			ocpp.KeyMeterValueSampleInterval: "60",
+		},
+		reject: make(map[string]bool),
+	}
+
+	st.SetCoreHandler(st)
+	st.SetRemoteTriggerHandler(st)
+	st.SetSmartChargingHandler(st)
+
+	return st
+}
+
+// connect connects the station once the charger has been registered and sends boot and optionally initial status
+func (st *testStation) connect(status bool) {
+	port := testCentralSystem(st.t)
+
+	require.Eventually(st.t, func() bool {
+		_, err := ocpp.Instance().Admin().Station(st.id)
+		return err == nil
+	}, testTimeout, 10*time.Millisecond)
+
+	require.NoError(st.t, st.Start(fmt.Sprintf("ws://127.0.0.1:%d", port)))
+	st.t.Cleanup(st.Stop)
+
+	_, err := st.BootNotification("model", "vendor")
+	require.NoError(st.t, err)
+
+	if status {
+		require.NoError(st.t, st.status(core.ChargePointStatusAvailable))
+	}
+}
+
+// newTestOCPP creates the charger while the station connects
+func newTestOCPP(t *testing.T, st *testStation, status bool) (*OCPP, error) {
+	testCentralSystem(t)
+
+	type result struct {
+		c   *OCPP
+		err error
+	}
+
+	resC := make(chan result, 1)
+	go func() {
+		c, err := NewOCPP(st.id, 1, defaultIdTag, testMeterValues, 10*time.Second, false, testTimeout)
+		resC <- result{c, err}
+	}()
+
+	st.connect(status)
+
+	select {
+	case res := <-resC:
+		return res.c, res.err
+	case <-time.After(statusTimeout):
+		require.FailNow(t, "charger not created")
+	}
+
+	return nil, nil
+}
+
+// trace records the request and delays the response
+func (st *testStation) trace(request ocppj.Request) {
+	st.mu.Lock()
+	st.received = append(st.received, request)
+	delay := st.delay
+	st.mu.Unlock()
+
+	time.Sleep(delay)
+}
+
+// features returns the feature names of the received requests in order
+func (st *testStation) features() []string {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	var res []string
+	for _, r := range st.received {
+		res = append(res, r.GetFeatureName())
+	}
+
+	return res
+}
+
+// last returns the last received request of the feature or nil
+func (st *testStation) last(feature string) ocppj.Request {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	for i := len(st.received) - 1; i >= 0; i-- {
+		if st.received[i].GetFeatureName() == feature {
+			return st.received[i]
+		}
+	}
+
+	return nil
+}
+
+// filter returns the received requests of the feature in order
+func (st *testStation) filter(feature string) []ocppj.Request {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	var res []ocppj.Request
+	for _, r := range st.received {
+		if r.GetFeatureName() == feature {
+			res = append(res, r)
+		}
+	}
+
+	return res
+}
+
+func (st *testStation) setDelay(delay time.Duration) {
+	st.mu.Lock()
+	st.delay = delay
+	st.mu.Unlock()
+}
+
+// status sends the connector status. Asynchronous sends may fail once the test has finished.
+func (st *testStation) status(status core.ChargePointStatus) error {
+	_, err := st.StatusNotification(1, core.NoError, status, func(request *core.StatusNotificationRequest) {
+		request.Timestamp = types.NewDateTime(time.Now())
+	})
+	return err
+}
+
+func (st *testStation) meterValues() error {
+	_, err := st.MeterValues(1, []types.MeterValue{{
+		Timestamp: types.NewDateTime(time.Now()),
+		SampledValue: []types.SampledValue{
+			{Measurand: types.MeasurandPowerActiveImport, Value: "2300", Unit: types.UnitOfMeasureW},
+			{Measurand: types.MeasurandEnergyActiveImportRegister, Value: "1500", Unit: types.UnitOfMeasureWh},
+		},
+	}})
+	return err
+}
+
+func (st *testStation) currents(currents ...float64) error {
+	var values []types.SampledValue
+	for i, current := range currents {
+		values = append(values, types.SampledValue{
+			Measurand: types.MeasurandCurrentImport,
+			Phase:     types.Phase(fmt.Sprintf("L%d", i+1)),
+			Value:     fmt.Sprintf("%.1f", current),
+			Unit:      types.UnitOfMeasureA,
+		})
+	}
+
+	// timestamps are sent with second precision, make sure values are newer than the previous ones
+	_, err := st.MeterValues(1, []types.MeterValue{{
+		Timestamp:    types.NewDateTime(time.Now().Add(time.Second)),
+		SampledValue: values,
+	}})
+	return err
+}
+
+func (st *testStation) OnChangeAvailability(request *core.ChangeAvailabilityRequest) (*core.ChangeAvailabilityConfirmation, error) {
+	st.trace(request)
+	return core.NewChangeAvailabilityConfirmation(core.AvailabilityStatusAccepted), nil
+}
+
+func (st *testStation) OnChangeConfiguration(request *core.ChangeConfigurationRequest) (*core.ChangeConfigurationConfirmation, error) {
+	st.trace(request)
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if st.reject[request.Key] {
+		return core.NewChangeConfigurationConfirmation(core.ConfigurationStatusRejected), nil
+	}
+
+	st.config[request.Key] = request.Value
+
+	return core.NewChangeConfigurationConfirmation(core.ConfigurationStatusAccepted), nil
+}
+
+func (st *testStation) OnClearCache(request *core.ClearCacheRequest) (*core.ClearCacheConfirmation, error) {
+	st.trace(request)
+	return core.NewClearCacheConfirmation(core.ClearCacheStatusAccepted), nil
+}
+
+func (st *testStation) OnDataTransfer(request *core.DataTransferRequest) (*core.DataTransferConfirmation, error) {
+	st.trace(request)
+	return core.NewDataTransferConfirmation(core.DataTransferStatusRejected), nil
+}
+
+func (st *testStation) OnGetConfiguration(request *core.GetConfigurationRequest) (*core.GetConfigurationConfirmation, error) {
+	st.trace(request)
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	keys := request.Key
+	if len(keys) == 0 {
+		for key := range st.config {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+	}
+
+	var (
+		res     []core.ConfigurationKey
+		unknown []string
+	)
+
+	for _, key := range keys {
+		if val, ok := st.config[key]; ok {
+			val := val
+			res = append(res, core.ConfigurationKey{Key: key, Value: &val})
+		} else {
+			unknown = append(unknown, key)
+		}
+	}
+
+	conf := core.NewGetConfigurationConfirmation(res)
+	conf.UnknownKey = unknown
+
+	return conf, nil
+}
+
+func (st *testStation) OnRemoteStartTransaction(request *core.RemoteStartTransactionRequest) (*core.RemoteStartTransactionConfirmation, error) {
+	st.trace(request)
+
+	go func() {
+		res, err := st.StartTransaction(1, request.IdTag, 1500, types.NewDateTime(time.Now()))
+		if err != nil {
+			return
+		}
+
+		st.mu.Lock()
+		st.txnId = res.TransactionId
+		st.mu.Unlock()
+
+		_ = st.status(core.ChargePointStatusCharging)
+	}()
+
+	return core.NewRemoteStartTransactionConfirmation(types.RemoteStartStopStatusAccepted), nil
+}
+
+func (st *testStation) OnRemoteStopTransaction(request *core.RemoteStopTransactionRequest) (*core.RemoteStopTransactionConfirmation, error) {
+	st.trace(request)
+
+	st.mu.Lock()
+	txnId := st.txnId
+	st.mu.Unlock()
+
+	if request.TransactionId != txnId {
+		return core.NewRemoteStopTransactionConfirmation(types.RemoteStartStopStatusRejected), nil
+	}
+
+	go func() {
+		if _, err := st.StopTransaction(2000, types.NewDateTime(time.Now()), txnId); err == nil {
+			_ = st.status(core.ChargePointStatusFinishing)
+		}
+	}()
+
+	return core.NewRemoteStopTransactionConfirmation(types.RemoteStartStopStatusAccepted), nil
+}
+
+func (st *testStation) OnReset(request *core.ResetRequest) (*core.ResetConfirmation, error) {
+	st.trace(request)
+	return core.NewResetConfirmation(core.ResetStatusRejected), nil
+}
+
+func (st *testStation) OnUnlockConnector(request *core.UnlockConnectorRequest) (*core.UnlockConnectorConfirmation, error) {
+	st.trace(request)
+	return core.NewUnlockConnectorConfirmation(core.UnlockStatusNotSupported), nil
+}
+
+func (st *testStation) OnTriggerMessage(request *remotetrigger.TriggerMessageRequest) (*remotetrigger.TriggerMessageConfirmation, error) {
+	st.trace(request)
+
+	switch request.RequestedMessage {
+	case core.MeterValuesFeatureName:
+		go func() { _ = st.meterValues() }()
+	case core.StatusNotificationFeatureName:
+		go func() { _ = st.status(core.ChargePointStatusAvailable) }()
+	default:
+		return remotetrigger.NewTriggerMessageConfirmation(remotetrigger.TriggerMessageStatusNotImplemented), nil
+	}
+
+	return remotetrigger.NewTriggerMessageConfirmation(remotetrigger.TriggerMessageStatusAccepted), nil
+}
+
+func (st *testStation) OnSetChargingProfile(request *smartcharging.SetChargingProfileRequest) (*smartcharging.SetChargingProfileConfirmation, error) {
+	st.trace(request)
+	return smartcharging.NewSetChargingProfileConfirmation(smartcharging.ChargingProfileStatusAccepted), nil
+}
+
+func (st *testStation) OnClearChargingProfile(request *smartcharging.ClearChargingProfileRequest) (*smartcharging.ClearChargingProfileConfirmation, error) {
+	st.trace(request)
+	return smartcharging.NewClearChargingProfileConfirmation(smartcharging.ClearChargingProfileStatusAccepted), nil
+}
+
+func (st *testStation) OnGetCompositeSchedule(request *smartcharging.GetCompositeScheduleRequest) (*smartcharging.GetCompositeScheduleConfirmation, error) {
+	st.trace(request)
+	return smartcharging.NewGetCompositeScheduleConfirmation(smartcharging.GetCompositeScheduleStatusRejected), nil
+}
+
+// requireProfile asserts a single period transaction profile with the current limit
+func requireProfile(t *testing.T, profile *types.ChargingProfile, current float64) {
+	require.NotNil(t, profile)
+	assert.Equal(t, types.ChargingProfilePurposeTxProfile, profile.ChargingProfilePurpose)
+	require.NotNil(t, profile.ChargingSchedule)
+	assert.Equal(t, types.ChargingRateUnitAmperes, profile.ChargingSchedule.ChargingRateUnit)
+	require.Len(t, profile.ChargingSchedule.ChargingSchedulePeriod, 1)
+	assert.Equal(t, current, profile.ChargingSchedule.ChargingSchedulePeriod[0].Limit)
+}
+
+func TestOCPPHandshake(t *testing.T) {
+	st := newTestStation(t, "conformance-handshake")
+
+	c, err := newTestOCPP(t, st, true)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		core.GetConfigurationFeatureName,
+		core.ChangeConfigurationFeatureName,
+		remotetrigger.TriggerMessageFeatureName,
+		core.ChangeConfigurationFeatureName,
+	}, st.features())
+
+	changes := st.filter(core.ChangeConfigurationFeatureName)
+	assert.Equal(t, &core.ChangeConfigurationRequest{Key: ocpp.KeyMeterValuesSampledData, Value: testMeterValues}, changes[0])
+	assert.Equal(t, &core.ChangeConfigurationRequest{Key: ocpp.KeyMeterValueSampleInterval, Value: "10"}, changes[1])
+
+	trigger := st.last(remotetrigger.TriggerMessageFeatureName).(*remotetrigger.TriggerMessageRequest)
+	assert.Equal(t, core.MeterValuesFeatureName, string(trigger.RequestedMessage))
+
+	assert.Equal(t, testMeterValues, c.meterValuesSample)
+
+	status, err := c.Status()
+	require.NoError(t, err)
+	assert.Equal(t, api.StatusA, status)
+
+	// triggered meter values
+	require.Eventually(t, func() bool {
+		power, err := c.currentPower()
+		return err == nil && power == 2300
+	}, testTimeout, 10*time.Millisecond)
+}
+
+func TestOCPPStatusTrigger(t *testing.T) {
+	st := newTestStation(t, "conformance-status")
+
+	// charger waits for initial status and triggers it if missing
+	c, err := newTestOCPP(t, st, false)
+	require.NoError(t, err)
+
+	trigger := st.last(remotetrigger.TriggerMessageFeatureName).(*remotetrigger.TriggerMessageRequest)
+	assert.Equal(t, core.StatusNotificationFeatureName, string(trigger.RequestedMessage))
+
+	status, err := c.Status()
+	require.NoError(t, err)
+	assert.Equal(t, api.StatusA, status)
+}
+
+func TestOCPPConfigurationRejected(t *testing.T) {
+	st := newTestStation(t, "conformance-rejected")
+	st.reject[ocpp.KeyMeterValuesSampledData] = true
+
+	_, err := newTestOCPP(t, st, true)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), string(core.ConfigurationStatusRejected))
+}
+
+func TestOCPPTransaction(t *testing.T) {
+	st := newTestStation(t, "conformance-transaction")
+
+	c, err := newTestOCPP(t, st, true)
+	require.NoError(t, err)
+
+	// not charging, current is applied to the next transaction
+	require.NoError(t, c.MaxCurrent(16))
+	assert.Nil(t, st.last(smartcharging.SetChargingProfileFeatureName))
+
+	// remote start with embedded transaction profile
+	require.NoError(t, c.Enable(true))
+
+	start := st.last(core.RemoteStartTransactionFeatureName).(*core.RemoteStartTransactionRequest)
+	assert.Equal(t, defaultIdTag, start.IdTag)
+	require.NotNil(t, start.ConnectorId)
+	assert.Equal(t, 1, *start.ConnectorId)
+	requireProfile(t, start.ChargingProfile, 16)
+
+	require.Eventually(t, func() bool {
+		status, err := c.Status()
+		enabled, _ := c.Enabled()
+		return err == nil && status == api.StatusC && enabled
+	}, testTimeout, 10*time.Millisecond)
+
+	// profile updates during transaction
+	require.NoError(t, c.MaxCurrentMillis(10.5))
+
+	profile := st.last(smartcharging.SetChargingProfileFeatureName).(*smartcharging.SetChargingProfileRequest)
+	assert.Equal(t, 1, profile.ConnectorId)
+	requireProfile(t, profile.ChargingProfile, 10.5)
+
+	// remote stop of the station's transaction
+	st.mu.Lock()
+	txnId := st.txnId
+	st.mu.Unlock()
+
+	require.NoError(t, c.Enable(false))
+
+	stop := st.last(core.RemoteStopTransactionFeatureName).(*core.RemoteStopTransactionRequest)
+	assert.Equal(t, txnId, stop.TransactionId)
+
+	require.Eventually(t, func() bool {
+		enabled, _ := c.Enabled()
+		return !enabled
+	}, testTimeout, 10*time.Millisecond)
+}
+
+func TestOCPPPhasesNotConfirmed(t *testing.T) {
+	st := newTestStation(t, "conformance-phases")
+
+	c, err := newTestOCPP(t, st, true)
+	require.NoError(t, err)
+
+	require.NoError(t, c.Enable(true))
+	require.Eventually(t, func() bool {
+		status, err := c.Status()
+		enabled, _ := c.Enabled()
+		return err == nil && status == api.StatusC && enabled
+	}, testTimeout, 10*time.Millisecond)
+
+	require.NoError(t, c.phases1p3p(1))
+	profiles := len(st.filter(smartcharging.SetChargingProfileFeatureName))
+
+	// vehicle keeps charging on 3 phases, profile is sent again until retries are exhausted
+	require.NoError(t, st.currents(10, 10, 10))
+
+	for i := 1; i <= maxPhaseRetries+1; i++ {
+		_, _, _, err := c.currents()
+		require.NoError(t, err)
+	}
+
+	assert.Len(t, st.filter(smartcharging.SetChargingProfileFeatureName), profiles+maxPhaseRetries)
+	assert.False(t, c.verifyPhases)
+}
+
+func TestOCPPTimeout(t *testing.T) {
+	st := newTestStation(t, "conformance-timeout")
+
+	c, err := newTestOCPP(t, st, true)
+	require.NoError(t, err)
+
+	c.timeout = 200 * time.Millisecond
+	st.setDelay(time.Second)
+
+	err = c.Enable(true)
+	assert.True(t, errors.Is(err, api.ErrTimeout), err)
+
+	// station still executes the timed out request, charger follows the transaction it starts
+	st.setDelay(0)
+	require.Eventually(t, func() bool {
+		enabled, _ := c.Enabled()
+		return enabled
+	}, testTimeout, 10*time.Millisecond)
+
+	// late confirmation is ignored, request isn't repeated and following requests get their own response
+	assert.Len(t, st.filter(core.RemoteStartTransactionFeatureName), 1)
+
+	require.NoError(t, c.MaxCurrent(10))
+	requireProfile(t, st.last(smartcharging.SetChargingProfileFeatureName).(*smartcharging.SetChargingProfileRequest).ChargingProfile, 10)
+}
+
+func TestOCPPSubprotocol(t *testing.T) {
+	port := testCentralSystem(t)
+
+	for i, tc := range []struct {
+		offered    []string
+		negotiated string
+	}{
+		{[]string{ocpp.ProtocolV16}, ocpp.ProtocolV16},
+		{[]string{ocpp.ProtocolV201}, ocpp.ProtocolV201},
+		{[]string{ocpp.ProtocolV201, ocpp.ProtocolV16}, ocpp.ProtocolV16},
+	} {
+		dialer := websocket.Dialer{Subprotocols: tc.offered}
+
+		conn, _, err := dialer.Dial(fmt.Sprintf("ws://127.0.0.1:%d/conformance-subprotocol-%d", port, i), nil)
+		require.NoError(t, err, tc)
+
+		assert.Equal(t, tc.negotiated, conn.Subprotocol(), tc)
+		require.NoError(t, conn.Close())
+	}
+}
@@ -0,0 +1,37 @@
+package charger
+
+import (
+	"testing"
+
+	"github.com/evcc-io/evcc/util"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOCPPCheckPhases(t *testing.T) {
+	c := &OCPP{log: util.NewLogger("foo"), phases: 1, verifyPhases: true}
+
+	// not charging yet
+	assert.NoError(t, c.checkPhases(0, 0.5, 0))
+	assert.True(t, c.verifyPhases)
+
+	assert.NoError(t, c.checkPhases(10, 0, 0))
+	assert.False(t, c.verifyPhases)
+
+	// phases didn't change
+	c.verifyPhases = true
+	assert.Error(t, c.checkPhases(10, 10, 10))
+
+	// vehicle charging on fewer phases
+	c.phases = 3
+	assert.NoError(t, c.checkPhases(10, 0, 0))
+}
+
+func TestOCPPTxDefaultProfile(t *testing.T) {
+	tx := getTxChargingProfile(10, 1)
+	def := getTxDefaultChargingProfile(10, 1)
+
+	assert.Equal(t, types.ChargingProfilePurposeTxDefaultProfile, def.ChargingProfilePurpose)
+	assert.NotEqual(t, tx.ChargingProfileId, def.ChargingProfileId)
+	assert.Equal(t, 1, *def.ChargingSchedule.ChargingSchedulePeriod[0].NumberPhases)
+}
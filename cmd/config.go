@@ -14,6 +14,7 @@ import (
 	"github.com/dustin/go-humanize"
 	"github.com/evcc-io/evcc/api"
 	"github.com/evcc-io/evcc/charger"
+	"github.com/evcc-io/evcc/charger/ocpp"
 	"github.com/evcc-io/evcc/meter"
 	"github.com/evcc-io/evcc/provider/mqtt"
 	"github.com/evcc-io/evcc/push"
@@ -63,6 +64,7 @@ type config struct {
 	Javascript   []javascriptConfig
 	Influx       server.InfluxConfig
 	EEBus        map[string]interface{}
+	OCPP         ocpp.Config
 	HEMS         typedConfig
 	Messaging    messagingConfig
 	Meters       []qualifiedConfig
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+)
+
+type route struct {
+	Methods     []string
+	Pattern     string
+	HandlerFunc http.HandlerFunc
+}
+
+// Handler returns the central system's REST api
+func (c *Central) Handler() http.Handler {
+	router := mux.NewRouter().StrictSlash(true)
+	api := router.PathPrefix("/api").Subrouter()
+
+	routes := map[string]route{
+		"stations":        {[]string{"GET"}, "/stations", c.stationsHandler},
+		"station":         {[]string{"GET"}, "/stations/{id}", c.stationHandler},
+		"messages":        {[]string{"GET"}, "/stations/{id}/messages", c.messagesHandler},
+		"remotestart":     {[]string{"POST"}, "/stations/{id}/remotestart/{connector:[0-9]+}/{idtag}", c.commandHandler(c.remoteStart)},
+		"remotestop":      {[]string{"POST"}, "/stations/{id}/remotestop/{connector:[0-9]+}", c.commandHandler(c.remoteStop)},
+		"chargingprofile": {[]string{"POST"}, "/stations/{id}/chargingprofile/{connector:[0-9]+}/{limit}", c.commandHandler(c.chargingProfile)},
+		"configuration":   {[]string{"POST"}, "/stations/{id}/configuration/{key}/{value}", c.commandHandler(c.changeConfiguration)},
+		"reset":           {[]string{"POST"}, "/stations/{id}/reset/{type:soft|hard}", c.commandHandler(c.reset)},
+		"trigger":         {[]string{"POST"}, "/stations/{id}/trigger/{message:[a-zA-Z]+}", c.commandHandler(c.triggerMessage)},
+	}
+
+	for _, r := range routes {
+		api.Methods(r.Methods...).Path(r.Pattern).Handler(r.HandlerFunc)
+	}
+
+	return router
+}
+
+func jsonWrite(w http.ResponseWriter, content interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	if err := json.NewEncoder(w).Encode(content); err != nil {
+		log.Printf("api: failed to encode JSON: %v", err)
+	}
+}
+
+func jsonResult(w http.ResponseWriter, res interface{}) {
+	jsonWrite(w, map[string]interface{}{"result": res})
+}
+
+func jsonError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, errUnknownStation):
+		status = http.StatusNotFound
+	case errors.Is(err, errStationOffline):
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
+}
+
+// stationsHandler returns all stations
+func (c *Central) stationsHandler(w http.ResponseWriter, r *http.Request) {
+	jsonResult(w, c.Stations())
+}
+
+// stationHandler returns the station
+func (c *Central) stationHandler(w http.ResponseWriter, r *http.Request) {
+	res, err := c.Station(mux.Vars(r)["id"])
+	if err != nil {
+		jsonError(w, err)
+		return
+	}
+
+	jsonResult(w, res)
+}
+
+// messagesHandler returns the station's message log, optionally since the RFC3339 time given by the since query parameter
+func (c *Central) messagesHandler(w http.ResponseWriter, r *http.Request) {
+	var since time.Time
+	if s := r.URL.Query().Get("since"); s != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339, s); err != nil {
+			jsonError(w, fmt.Errorf("invalid since: %w", err))
+			return
+		}
+	}
+
+	res, err := c.Messages(mux.Vars(r)["id"], since)
+	if err != nil {
+		jsonError(w, err)
+		return
+	}
+
+	jsonResult(w, res)
+}
+
+// commandHandler sends the command to the station and returns the station's response status
+func (c *Central) commandHandler(command func(vars map[string]string, r *http.Request) (string, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		res, err := command(mux.Vars(r), r)
+		if err != nil {
+			jsonError(w, err)
+			return
+		}
+
+		jsonResult(w, res)
+	}
+}
+
+// floatQuery returns the optional float query parameter
+func floatQuery(r *http.Request, key string) (float64, error) {
+	s := r.URL.Query().Get(key)
+	if s == "" {
+		return 0, nil
+	}
+
+	res, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+
+	return res, nil
+}
+
+// remoteStart starts a transaction, limited by the optional limit query parameter in A
+func (c *Central) remoteStart(vars map[string]string, r *http.Request) (string, error) {
+	connector, _ := strconv.Atoi(vars["connector"])
+
+	limit, err := floatQuery(r, "limit")
+	if err != nil {
+		return "", err
+	}
+
+	return c.RemoteStart(vars["id"], connector, vars["idtag"], limit)
+}
+
+// remoteStop stops the connector's active transaction
+func (c *Central) remoteStop(vars map[string]string, r *http.Request) (string, error) {
+	connector, _ := strconv.Atoi(vars["connector"])
+
+	txn, err := c.ActiveTransaction(vars["id"], connector)
+	if err != nil {
+		return "", err
+	}
+
+	return c.RemoteStop(vars["id"], txn)
+}
+
+// chargingProfile sets the limit in A with purpose given by the optional purpose query parameter
+func (c *Central) chargingProfile(vars map[string]string, r *http.Request) (string, error) {
+	connector, _ := strconv.Atoi(vars["connector"])
+
+	limit, err := strconv.ParseFloat(vars["limit"], 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid limit: %w", err)
+	}
+
+	purpose := types.ChargingProfilePurposeTxDefaultProfile
+	if s := r.URL.Query().Get("purpose"); s != "" {
+		purpose = types.ChargingProfilePurposeType(s)
+	}
+
+	return c.SetChargingProfile(vars["id"], connector, purpose, limit)
+}
+
+func (c *Central) changeConfiguration(vars map[string]string, r *http.Request) (string, error) {
+	return c.ChangeConfiguration(vars["id"], vars["key"], vars["value"])
+}
+
+func (c *Central) reset(vars map[string]string, r *http.Request) (string, error) {
+	return c.Reset(vars["id"], vars["type"] == "hard")
+}
+
+// triggerMessage requests the message for the connector given by the optional connector query parameter
+func (c *Central) triggerMessage(vars map[string]string, r *http.Request) (string, error) {
+	var connector int
+	if s := r.URL.Query().Get("connector"); s != "" {
+		var err error
+		if connector, err = strconv.Atoi(s); err != nil {
+			return "", fmt.Errorf("invalid connector: %w", err)
+		}
+	}
+
+	return c.TriggerMessage(vars["id"], vars["message"], connector)
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/evcc-io/evcc/util/ocpprecord"
+	ocpp16 "github.com/lorenzodonini/ocpp-go/ocpp1.6"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/lorenzodonini/ocpp-go/ws"
+)
+
+var (
+	errUnknownStation = errors.New("unknown station")
+	errStationOffline = errors.New("station offline")
+)
+
+// Message is a call exchanged with a station
+type Message struct {
+	Time      time.Time            `json:"time"`
+	Direction ocpprecord.Direction `json:"direction"`
+	Action    string               `json:"action"`
+}
+
+// Connector is the connector's state as reported by the station
+type Connector struct {
+	ID          int                       `json:"id"`
+	Status      core.ChargePointStatus    `json:"status"`
+	ErrorCode   core.ChargePointErrorCode `json:"errorCode"`
+	Transaction int                       `json:"transaction,omitempty"`
+	IdTag       string                    `json:"idTag,omitempty"`
+	MeterStart  int                       `json:"meterStart"` // Wh
+	Energy      float64                   `json:"energy"`     // Wh
+	Power       float64                   `json:"power"`      // W
+}
+
+// Station is the station's state and message log
+type Station struct {
+	ID         string                        `json:"id"`
+	Connected  bool                          `json:"connected"`
+	Boot       *core.BootNotificationRequest `json:"boot,omitempty"`
+	Heartbeat  time.Time                     `json:"heartbeat"`
+	Connectors []*Connector                  `json:"connectors"`
+	Violations []string                      `json:"violations"`
+
+	log      *log.Logger
+	messages []Message
+	sent     time.Time // timestamp of the last transaction related message
+}
+
+// connector returns the connector, creating it on first use. Lock must be held.
+func (s *Station) connector(id int) *Connector {
+	for _, conn := range s.Connectors {
+		if conn.ID == id {
+			return conn
+		}
+	}
+
+	conn := &Connector{ID: id}
+	s.Connectors = append(s.Connectors, conn)
+
+	sort.Slice(s.Connectors, func(i, j int) bool {
+		return s.Connectors[i].ID < s.Connectors[j].ID
+	})
+
+	return conn
+}
+
+// transaction returns the connector with given active transaction or nil. Lock must be held.
+func (s *Station) transaction(id int) *Connector {
+	for _, conn := range s.Connectors {
+		if id != 0 && conn.Transaction == id {
+			return conn
+		}
+	}
+
+	return nil
+}
+
+// violation records a message ordering violation. Lock must be held.
+func (s *Station) violation(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	s.log.Println("VIOLATION:", msg)
+	s.Violations = append(s.Violations, msg)
+}
+
+// ordered checks that transaction related messages are sent in chronological order. Lock must be held.
+func (s *Station) ordered(action string, ts *types.DateTime) {
+	if ts == nil {
+		return
+	}
+
+	if ts.Before(s.sent) {
+		s.violation("%s at %s sent after message at %s", action, ts.Format(time.RFC3339), s.sent.Format(time.RFC3339))
+		return
+	}
+
+	s.sent = ts.Time
+}
+
+// Central is a local central system. It records all messages and checks the stations' message ordering.
+type Central struct {
+	mu        sync.Mutex
+	cs        ocpp16.CentralSystem
+	stations  map[string]*Station
+	txnId     int
+	heartbeat time.Duration
+}
+
+// NewCentral creates the central system recording all frames if recorder is not nil
+func NewCentral(recorder *ocpprecord.Recorder, heartbeat time.Duration) *Central {
+	c := &Central{
+		stations:  make(map[string]*Station),
+		heartbeat: heartbeat,
+	}
+
+	c.cs = ocpp16.NewCentralSystem(nil, &messageServer{WsServer: ws.NewServer(), central: c, recorder: recorder})
+
+	c.cs.SetCoreHandler(c)
+	c.cs.SetNewChargePointHandler(func(cp ocpp16.ChargePointConnection) {
+		c.connected(cp.ID(), true)
+	})
+	c.cs.SetChargePointDisconnectedHandler(func(cp ocpp16.ChargePointConnection) {
+		c.connected(cp.ID(), false)
+	})
+
+	go func() {
+		for err := range c.cs.Errors() {
+			log.Println(err)
+		}
+	}()
+
+	return c
+}
+
+// Start listens for station connections
+func (c *Central) Start(port int) {
+	log.Printf("listening for stations on port %d", port)
+	c.cs.Start(port, "/{ws}")
+}
+
+// station returns the station, creating it on first use. Lock must be held.
+func (c *Central) station(id string) *Station {
+	s, ok := c.stations[id]
+	if !ok {
+		s = &Station{
+			ID:         id,
+			Connectors: make([]*Connector, 0),
+			Violations: make([]string, 0),
+			log:        log.New(os.Stdout, id+" ", log.LstdFlags),
+		}
+		c.stations[id] = s
+	}
+
+	return s
+}
+
+// connected updates the station's connection state
+func (c *Central) connected(id string, connected bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := c.station(id)
+	s.Connected = connected
+
+	if connected {
+		s.log.Println("connected")
+	} else {
+		s.log.Println("disconnected")
+	}
+}
+
+// record adds the call to the station's message log and checks the boot ordering
+func (c *Central) record(id string, dir ocpprecord.Direction, action string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := c.station(id)
+	s.log.Println(dir, action)
+	s.messages = append(s.messages, Message{Time: time.Now(), Direction: dir, Action: action})
+
+	if dir == ocpprecord.In && action != core.BootNotificationFeatureName && s.Boot == nil {
+		s.violation("%s before %s", action, core.BootNotificationFeatureName)
+	}
+}
+
+// Stations returns all stations ordered by id
+func (c *Central) Stations() []Station {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	res := make([]Station, 0, len(c.stations))
+	for _, s := range c.stations {
+		res = append(res, s.copy())
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ID < res[j].ID
+	})
+
+	return res
+}
+
+// Station returns the station's state
+func (c *Central) Station(id string) (Station, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s, ok := c.stations[id]
+	if !ok {
+		return Station{}, fmt.Errorf("%w: %s", errUnknownStation, id)
+	}
+
+	return s.copy(), nil
+}
+
+// Messages returns the station's message log since given time
+func (c *Central) Messages(id string, since time.Time) ([]Message, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s, ok := c.stations[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errUnknownStation, id)
+	}
+
+	res := make([]Message, 0)
+	for _, msg := range s.messages {
+		if !msg.Time.Before(since) {
+			res = append(res, msg)
+		}
+	}
+
+	return res, nil
+}
+
+// copy returns a copy of the station's state. Lock must be held.
+func (s *Station) copy() Station {
+	res := *s
+	res.Connectors = make([]*Connector, 0, len(s.Connectors))
+	for _, conn := range s.Connectors {
+		conn := *conn
+		res.Connectors = append(res.Connectors, &conn)
+	}
+	res.Violations = append(make([]string, 0, len(s.Violations)), s.Violations...)
+
+	return res
+}
+
+// OnAuthorize accepts all id tags
+func (c *Central) OnAuthorize(id string, request *core.AuthorizeRequest) (*core.AuthorizeConfirmation, error) {
+	return core.NewAuthorizationConfirmation(types.NewIdTagInfo(types.AuthorizationStatusAccepted)), nil
+}
+
+// OnBootNotification accepts the station
+func (c *Central) OnBootNotification(id string, request *core.BootNotificationRequest) (*core.BootNotificationConfirmation, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := c.station(id)
+	s.Boot = request
+	s.log.Printf("boot: %s %s %s", request.ChargePointVendor, request.ChargePointModel, request.FirmwareVersion)
+
+	return core.NewBootNotificationConfirmation(types.NewDateTime(time.Now()), int(c.heartbeat.Seconds()), core.RegistrationStatusAccepted), nil
+}
+
+// OnDataTransfer accepts all data
+func (c *Central) OnDataTransfer(id string, request *core.DataTransferRequest) (*core.DataTransferConfirmation, error) {
+	return core.NewDataTransferConfirmation(core.DataTransferStatusAccepted), nil
+}
+
+// OnHeartbeat records the heartbeat
+func (c *Central) OnHeartbeat(id string, request *core.HeartbeatRequest) (*core.HeartbeatConfirmation, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.station(id).Heartbeat = time.Now()
+
+	return core.NewHeartbeatConfirmation(types.NewDateTime(time.Now())), nil
+}
+
+// OnStatusNotification records the connector status
+func (c *Central) OnStatusNotification(id string, request *core.StatusNotificationRequest) (*core.StatusNotificationConfirmation, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := c.station(id)
+	s.log.Printf("connector %d: %s %s", request.ConnectorId, request.Status, request.ErrorCode)
+
+	if request.ConnectorId > 0 {
+		conn := s.connector(request.ConnectorId)
+		conn.Status = request.Status
+		conn.ErrorCode = request.ErrorCode
+	}
+
+	return core.NewStatusNotificationConfirmation(), nil
+}
+
+// OnStartTransaction assigns the transaction id
+func (c *Central) OnStartTransaction(id string, request *core.StartTransactionRequest) (*core.StartTransactionConfirmation, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := c.station(id)
+	s.ordered(request.GetFeatureName(), request.Timestamp)
+
+	conn := s.connector(request.ConnectorId)
+	if conn.Transaction != 0 {
+		s.violation("%s on connector %d with active transaction %d", request.GetFeatureName(), conn.ID, conn.Transaction)
+	}
+
+	c.txnId++
+
+	conn.Transaction = c.txnId
+	conn.IdTag = request.IdTag
+	conn.MeterStart = request.MeterStart
+	conn.Energy = float64(request.MeterStart)
+	conn.Power = 0
+
+	s.log.Printf("connector %d: started transaction %d: %s", conn.ID, conn.Transaction, conn.IdTag)
+
+	return core.NewStartTransactionConfirmation(types.NewIdTagInfo(types.AuthorizationStatusAccepted), c.txnId), nil
+}
+
+// OnStopTransaction finishes the transaction
+func (c *Central) OnStopTransaction(id string, request *core.StopTransactionRequest) (*core.StopTransactionConfirmation, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := c.station(id)
+	s.ordered(request.GetFeatureName(), request.Timestamp)
+
+	conn := s.transaction(request.TransactionId)
+	if conn == nil {
+		s.violation("%s for unknown transaction %d", request.GetFeatureName(), request.TransactionId)
+		return core.NewStopTransactionConfirmation(), nil
+	}
+
+	if request.MeterStop < conn.MeterStart {
+		s.violation("%s for transaction %d: meter stop %d below meter start %d", request.GetFeatureName(), conn.Transaction, request.MeterStop, conn.MeterStart)
+	}
+
+	s.log.Printf("connector %d: stopped transaction %d: %.0fWh, %s", conn.ID, conn.Transaction, float64(request.MeterStop-conn.MeterStart), request.Reason)
+
+	conn.Transaction = 0
+	conn.IdTag = ""
+	conn.Energy = float64(request.MeterStop)
+	conn.Power = 0
+
+	return core.NewStopTransactionConfirmation(), nil
+}
+
+// OnMeterValues records the connector's energy and power
+func (c *Central) OnMeterValues(id string, request *core.MeterValuesRequest) (*core.MeterValuesConfirmation, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := c.station(id)
+	conn := s.connector(request.ConnectorId)
+
+	if request.TransactionId != nil {
+		if s.transaction(*request.TransactionId) != conn {
+			s.violation("%s for unknown transaction %d on connector %d", request.GetFeatureName(), *request.TransactionId, request.ConnectorId)
+		}
+	}
+
+	for _, mv := range request.MeterValue {
+		if request.TransactionId != nil {
+			s.ordered(request.GetFeatureName(), mv.Timestamp)
+		}
+
+		for _, sv := range mv.SampledValue {
+			val, err := strconv.ParseFloat(sv.Value, 64)
+			if err != nil {
+				s.violation("%s: invalid value: %s", request.GetFeatureName(), sv.Value)
+				continue
+			}
+
+			if sv.Unit == types.UnitOfMeasureKW || sv.Unit == types.UnitOfMeasureKWh {
+				val *= 1e3
+			}
+
+			switch sv.Measurand {
+			case types.MeasurandEnergyActiveImportRegister, "":
+				conn.Energy = val
+			case types.MeasurandPowerActiveImport:
+				conn.Power = val
+			}
+		}
+	}
+
+	return core.NewMeterValuesConfirmation(), nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/evcc-io/evcc/util/ocppscript"
+	ocpp16 "github.com/lorenzodonini/ocpp-go/ocpp1.6"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testHandler accepts remote starts, other commands are not implemented
+type testHandler struct {
+	core.ChargePointHandler
+	idTag chan string
+}
+
+func (h *testHandler) OnRemoteStartTransaction(request *core.RemoteStartTransactionRequest) (*core.RemoteStartTransactionConfirmation, error) {
+	h.idTag <- request.IdTag
+	return core.NewRemoteStartTransactionConfirmation(types.RemoteStartStopStatusAccepted), nil
+}
+
+func newTestCentral(t *testing.T) (*Central, ocpp16.ChargePoint, *testHandler) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	require.NoError(t, l.Close())
+
+	c := NewCentral(nil, time.Minute)
+	go c.Start(port)
+
+	handler := &testHandler{idTag: make(chan string, 1)}
+	cp := ocpp16.NewChargePoint("test", nil, nil)
+	cp.SetCoreHandler(handler)
+
+	require.Eventually(t, func() bool {
+		return cp.Start(fmt.Sprintf("ws://127.0.0.1:%d", port)) == nil
+	}, 5*time.Second, 100*time.Millisecond)
+
+	t.Cleanup(cp.Stop)
+
+	return c, cp, handler
+}
+
+func TestCentralOrdering(t *testing.T) {
+	c, cp, _ := newTestCentral(t)
+	start := time.Now()
+
+	// status before boot
+	_, err := cp.StatusNotification(1, core.NoError, core.ChargePointStatusAvailable)
+	require.NoError(t, err)
+
+	_, err = cp.BootNotification("model", "vendor")
+	require.NoError(t, err)
+
+	txn, err := cp.StartTransaction(1, "tag", 100, types.NewDateTime(start))
+	require.NoError(t, err)
+
+	// meter values sent out of order
+	_, err = cp.MeterValues(1, []types.MeterValue{{
+		Timestamp:    types.NewDateTime(start.Add(-time.Minute)),
+		SampledValue: []types.SampledValue{{Value: "1100", Measurand: types.MeasurandEnergyActiveImportRegister}},
+	}}, func(request *core.MeterValuesRequest) {
+		request.TransactionId = &txn.TransactionId
+	})
+	require.NoError(t, err)
+
+	s, err := c.Station("test")
+	require.NoError(t, err)
+	require.Len(t, s.Connectors, 1)
+	assert.Equal(t, txn.TransactionId, s.Connectors[0].Transaction)
+	assert.Equal(t, 1100.0, s.Connectors[0].Energy)
+
+	_, err = cp.StopTransaction(1100, types.NewDateTime(start.Add(time.Minute)), txn.TransactionId)
+	require.NoError(t, err)
+
+	// unknown transaction
+	_, err = cp.StopTransaction(1100, types.NewDateTime(start.Add(time.Minute)), txn.TransactionId)
+	require.NoError(t, err)
+
+	s, err = c.Station("test")
+	require.NoError(t, err)
+	assert.Len(t, s.Violations, 3)
+	assert.Zero(t, s.Connectors[0].Transaction)
+
+	messages, err := c.Messages("test", start)
+	require.NoError(t, err)
+	assert.True(t, ocppscript.Ordered(received(messages), []string{"BootNotification", "StartTransaction", "StopTransaction"}))
+	assert.False(t, ocppscript.Ordered(received(messages), []string{"StopTransaction", "MeterValues"}))
+}
+
+func TestCentralApi(t *testing.T) {
+	c, cp, handler := newTestCentral(t)
+
+	_, err := cp.BootNotification("model", "vendor")
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(c.Handler())
+	defer srv.Close()
+
+	res, err := http.Post(srv.URL+"/api/stations/test/remotestart/1/card?limit=10", "", nil)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	var body struct {
+		Result string
+		Error  string
+	}
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&body))
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, string(types.RemoteStartStopStatusAccepted), body.Result)
+	assert.Equal(t, "card", <-handler.idTag)
+
+	res, err = http.Post(srv.URL+"/api/stations/foo/reset/soft", "", nil)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestLoadScript(t *testing.T) {
+	sc, err := LoadScript("scripts/hems.yaml")
+	require.NoError(t, err)
+
+	require.NotEmpty(t, sc.Steps)
+	assert.Equal(t, ocppscript.Actions{"BootNotification", "StatusNotification"}, sc.Steps[0].Expect)
+	assert.Equal(t, ocppscript.Actions{"StopTransaction"}, sc.Steps[len(sc.Steps)-1].Expect)
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/remotetrigger"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/smartcharging"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+)
+
+// requestTimeout is the time a command waits for the station's response
+const requestTimeout = 30 * time.Second
+
+// send sends the request to the station and waits for the response
+func (c *Central) send(id string, request ocpp.Request) (ocpp.Response, error) {
+	c.mu.Lock()
+	s, ok := c.stations[id]
+	connected := ok && s.Connected
+	c.mu.Unlock()
+
+	switch {
+	case !ok:
+		return nil, fmt.Errorf("%w: %s", errUnknownStation, id)
+	case !connected:
+		return nil, fmt.Errorf("%w: %s", errStationOffline, id)
+	}
+
+	type result struct {
+		res ocpp.Response
+		err error
+	}
+
+	rc := make(chan result, 1)
+
+	if err := c.cs.SendRequestAsync(id, request, func(res ocpp.Response, err error) {
+		rc <- result{res, err}
+	}); err != nil {
+		return nil, err
+	}
+
+	select {
+	case r := <-rc:
+		return r.res, r.err
+	case <-time.After(requestTimeout):
+		return nil, errors.New("timeout")
+	}
+}
+
+// status returns the response's status
+func status(res ocpp.Response) string {
+	switch res := res.(type) {
+	case *core.RemoteStartTransactionConfirmation:
+		return string(res.Status)
+	case *core.RemoteStopTransactionConfirmation:
+		return string(res.Status)
+	case *core.ChangeConfigurationConfirmation:
+		return string(res.Status)
+	case *core.ResetConfirmation:
+		return string(res.Status)
+	case *smartcharging.SetChargingProfileConfirmation:
+		return string(res.Status)
+	case *remotetrigger.TriggerMessageConfirmation:
+		return string(res.Status)
+	default:
+		return fmt.Sprintf("%T", res)
+	}
+}
+
+// command sends the request and returns the station's response status
+func (c *Central) command(id string, request ocpp.Request) (string, error) {
+	res, err := c.send(id, request)
+	if err != nil {
+		return "", err
+	}
+
+	return status(res), nil
+}
+
+// profile creates a charging profile with constant limit in A
+func profile(id int, purpose types.ChargingProfilePurposeType, limit float64) *types.ChargingProfile {
+	return &types.ChargingProfile{
+		ChargingProfileId:      id,
+		ChargingProfilePurpose: purpose,
+		ChargingProfileKind:    types.ChargingProfileKindRelative,
+		ChargingSchedule:       types.NewChargingSchedule(types.ChargingRateUnitAmperes, types.NewChargingSchedulePeriod(0, limit)),
+	}
+}
+
+// RemoteStart requests starting a transaction, limited by a transaction profile if limit is positive.
+// Connector 0 lets the station choose the connector.
+func (c *Central) RemoteStart(id string, connector int, idTag string, limit float64) (string, error) {
+	request := core.NewRemoteStartTransactionRequest(idTag)
+
+	if connector > 0 {
+		request.ConnectorId = &connector
+	}
+
+	if limit > 0 {
+		request.ChargingProfile = profile(1, types.ChargingProfilePurposeTxProfile, limit)
+	}
+
+	return c.command(id, request)
+}
+
+// RemoteStop requests stopping the transaction
+func (c *Central) RemoteStop(id string, transaction int) (string, error) {
+	return c.command(id, core.NewRemoteStopTransactionRequest(transaction))
+}
+
+// ActiveTransaction returns the connector's active transaction
+func (c *Central) ActiveTransaction(id string, connector int) (int, error) {
+	s, err := c.Station(id)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, conn := range s.Connectors {
+		if conn.ID == connector && conn.Transaction != 0 {
+			return conn.Transaction, nil
+		}
+	}
+
+	return 0, fmt.Errorf("no active transaction on connector %d", connector)
+}
+
+// SetChargingProfile sets a constant limit in A for the connector. Connector 0 addresses the station.
+func (c *Central) SetChargingProfile(id string, connector int, purpose types.ChargingProfilePurposeType, limit float64) (string, error) {
+	var profileId int
+
+	switch purpose {
+	case types.ChargingProfilePurposeChargePointMaxProfile:
+		profileId = 100
+	case types.ChargingProfilePurposeTxDefaultProfile:
+		profileId = 200
+	case types.ChargingProfilePurposeTxProfile:
+		profileId = 300
+	default:
+		return "", fmt.Errorf("invalid purpose: %s", purpose)
+	}
+
+	chargingProfile := profile(profileId+connector, purpose, limit)
+
+	if purpose == types.ChargingProfilePurposeTxProfile {
+		txn, err := c.ActiveTransaction(id, connector)
+		if err != nil {
+			return "", err
+		}
+
+		chargingProfile.TransactionId = txn
+	}
+
+	return c.command(id, smartcharging.NewSetChargingProfileRequest(connector, chargingProfile))
+}
+
+// ChangeConfiguration changes the station's configuration key
+func (c *Central) ChangeConfiguration(id, key, value string) (string, error) {
+	return c.command(id, core.NewChangeConfigurationRequest(key, value))
+}
+
+// Reset requests the station to reboot
+func (c *Central) Reset(id string, hard bool) (string, error) {
+	typ := core.ResetTypeSoft
+	if hard {
+		typ = core.ResetTypeHard
+	}
+
+	return c.command(id, core.NewResetRequest(typ))
+}
+
+// TriggerMessage requests the station to send the message. Connector 0 addresses the station.
+func (c *Central) TriggerMessage(id, message string, connector int) (string, error) {
+	request := remotetrigger.NewTriggerMessageRequest(remotetrigger.MessageTrigger(message))
+
+	if connector > 0 {
+		request.ConnectorId = &connector
+	}
+
+	return c.command(id, request)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/evcc-io/evcc/util/ocpprecord"
+	"github.com/spf13/cobra"
+)
+
+// csCmd represents the base command when called without any subcommands
+var csCmd = &cobra.Command{
+	Use:   "ocpp-cs",
+	Short: "Local OCPP 1.6 central system for validating charge points such as the evcc OCPP client",
+	Run:   runCentral,
+	Args:  cobra.NoArgs,
+}
+
+func main() {
+	csCmd.Flags().Int("port", 8887, "Listen port for stations")
+	csCmd.Flags().String("api", ":8888", "REST api listen address, empty to disable")
+	csCmd.Flags().String("record", "ocpp-cs.jsonl", "Record all messages to file (JSON lines), empty to disable")
+	csCmd.Flags().Duration("heartbeat", time.Minute, "Heartbeat interval returned on boot")
+	csCmd.Flags().String("script", "", "Run script file (yaml or json) and exit")
+
+	if err := csCmd.Execute(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func runCentral(cmd *cobra.Command, args []string) {
+	port, _ := cmd.Flags().GetInt("port")
+	addr, _ := cmd.Flags().GetString("api")
+	record, _ := cmd.Flags().GetString("record")
+	heartbeat, _ := cmd.Flags().GetDuration("heartbeat")
+	script, _ := cmd.Flags().GetString("script")
+
+	var sc *Script
+	if script != "" {
+		var err error
+		if sc, err = LoadScript(script); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var recorder *ocpprecord.Recorder
+	if record != "" {
+		var err error
+		if recorder, err = ocpprecord.Create(record); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	central := NewCentral(recorder, heartbeat)
+	go central.Start(port)
+
+	if addr != "" {
+		go func() {
+			log.Printf("api listening on %s", addr)
+			log.Fatal(http.ListenAndServe(addr, central.Handler()))
+		}()
+	}
+
+	// run script and exit
+	if sc != nil {
+		err := sc.Run(central)
+		if recorder != nil {
+			_ = recorder.Close()
+		}
+
+		if err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+
+		os.Exit(0)
+	}
+
+	select {}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/evcc-io/evcc/util/ocpprecord"
+	"github.com/evcc-io/evcc/util/ocppscript"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+)
+
+// Step is a single script timeline entry. Actions are remotestart, remotestop, chargingprofile, configuration,
+// reset, trigger or wait. Steps apply to the first station unless a station is given.
+type Step struct {
+	ocppscript.Step `yaml:",inline"`
+	Limit           float64 `yaml:"limit"`   // A
+	Purpose         string  `yaml:"purpose"` // charging profile purpose, defaults to TxDefaultProfile
+	Key             string  `yaml:"key"`
+	Value           string  `yaml:"value"`
+	Type            string  `yaml:"type"`    // reset type soft or hard
+	Message         string  `yaml:"message"` // message to trigger
+	Result          string  `yaml:"result"`  // expected response status, defaults to Accepted
+}
+
+// Script is a timeline of commands and expectations
+type Script struct {
+	Steps []Step
+}
+
+// LoadScript reads a script from a yaml or json file
+func LoadScript(file string) (*Script, error) {
+	steps, err := ocppscript.Load[Step](file)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Script{Steps: steps}, nil
+}
+
+// Run executes the script and returns an error if any command or expectation failed or stations violated message ordering
+func (sc *Script) Run(c *Central) error {
+	err := ocppscript.Timeline[Step]{
+		Steps: sc.Steps,
+		Execute: func(step Step) error {
+			return step.execute(c)
+		},
+		Check: func(step Step, from time.Time) error {
+			return step.check(c, from)
+		},
+	}.Run()
+
+	var violations int
+	for _, s := range c.Stations() {
+		if len(s.Violations) > 0 {
+			log.Printf("%s: %d message ordering violations", s.ID, len(s.Violations))
+			violations += len(s.Violations)
+		}
+	}
+
+	switch {
+	case err != nil && violations > 0:
+		return fmt.Errorf("%w, %d message ordering violations", err, violations)
+	case violations > 0:
+		return fmt.Errorf("%d message ordering violations", violations)
+	}
+
+	return err
+}
+
+// station returns the step's station or the first station
+func (step Step) station(c *Central) string {
+	if step.Station != "" {
+		return step.Station
+	}
+
+	if stations := c.Stations(); len(stations) > 0 {
+		return stations[0].ID
+	}
+
+	return ""
+}
+
+// execute sends the step's command and checks the station's response
+func (step Step) execute(c *Central) error {
+	id := step.station(c)
+
+	var (
+		res string
+		err error
+	)
+
+	switch step.Action {
+	case "remotestart":
+		res, err = c.RemoteStart(id, step.Connector, step.IdTag, step.Limit)
+
+	case "remotestop":
+		var txn int
+		if txn, err = c.ActiveTransaction(id, step.ConnectorID()); err == nil {
+			res, err = c.RemoteStop(id, txn)
+		}
+
+	case "chargingprofile":
+		purpose := types.ChargingProfilePurposeTxDefaultProfile
+		if step.Purpose != "" {
+			purpose = types.ChargingProfilePurposeType(step.Purpose)
+		}
+		res, err = c.SetChargingProfile(id, step.Connector, purpose, step.Limit)
+
+	case "configuration":
+		res, err = c.ChangeConfiguration(id, step.Key, step.Value)
+
+	case "reset":
+		res, err = c.Reset(id, step.Type == "hard")
+
+	case "trigger":
+		res, err = c.TriggerMessage(id, step.Message, step.Connector)
+
+	default:
+		return fmt.Errorf("unknown action: %s", step.Action)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	expect := step.Result
+	if expect == "" {
+		expect = "Accepted"
+	}
+
+	if res != expect {
+		return fmt.Errorf("%s: %s, expected %s", step.Action, res, expect)
+	}
+
+	return nil
+}
+
+// check returns nil if the step's expectation is met by the station
+func (step Step) check(c *Central, from time.Time) error {
+	id := step.station(c)
+	if id == "" {
+		return errors.New("no station connected")
+	}
+
+	messages, err := c.Messages(id, from)
+	if err != nil {
+		return err
+	}
+
+	if received := received(messages); !ocppscript.Ordered(received, step.Expect) {
+		return fmt.Errorf("station %s did not send %v, sent %v", id, step.Expect, received)
+	}
+
+	if step.Status == "" {
+		return nil
+	}
+
+	s, err := c.Station(id)
+	if err != nil {
+		return err
+	}
+
+	var status core.ChargePointStatus
+	for _, conn := range s.Connectors {
+		if conn.ID == step.ConnectorID() {
+			status = conn.Status
+		}
+	}
+
+	if status != core.ChargePointStatus(step.Status) {
+		return fmt.Errorf("connector %d did not reach status %s, is %s", step.ConnectorID(), step.Status, status)
+	}
+
+	return nil
+}
+
+// received returns the actions sent by the station
+func received(messages []Message) []string {
+	var res []string
+	for _, msg := range messages {
+		if msg.Direction == ocpprecord.In {
+			res = append(res, msg.Action)
+		}
+	}
+	return res
+}
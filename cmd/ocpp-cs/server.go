@@ -0,0 +1,44 @@
+package main
+
+import (
+	"log"
+
+	"github.com/evcc-io/evcc/util/ocpprecord"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/lorenzodonini/ocpp-go/ws"
+)
+
+// messageServer logs all calls exchanged by the websocket server and records the frames if enabled
+type messageServer struct {
+	ws.WsServer
+	central  *Central
+	recorder *ocpprecord.Recorder
+}
+
+func (s *messageServer) handle(id string, dir ocpprecord.Direction, data []byte) {
+	if s.recorder != nil {
+		if err := s.recorder.Record(id, types.V16Subprotocol, dir, data); err != nil {
+			log.Println("record:", err)
+		}
+	}
+
+	if msg, err := ocpprecord.ParseMessage(data); err == nil && msg.Type == ocpprecord.Call {
+		s.central.record(id, dir, msg.Action)
+	}
+}
+
+func (s *messageServer) SetMessageHandler(handler func(ws ws.Channel, data []byte) error) {
+	s.WsServer.SetMessageHandler(func(ws ws.Channel, data []byte) error {
+		s.handle(ws.ID(), ocpprecord.In, data)
+		return handler(ws, data)
+	})
+}
+
+func (s *messageServer) Write(webSocketId string, data []byte) error {
+	err := s.WsServer.Write(webSocketId, data)
+	if err == nil {
+		s.handle(webSocketId, ocpprecord.Out, data)
+	}
+
+	return err
+}
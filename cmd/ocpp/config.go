@@ -0,0 +1,298 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	KeyNumberOfConnectors                      = "NumberOfConnectors"
+	KeyHeartbeatInterval                       = "HeartbeatInterval"
+	KeyMeterValueSampleInterval                = "MeterValueSampleInterval"
+	KeyMeterValuesSampledData                  = "MeterValuesSampledData"
+	KeyWebSocketPingInterval                   = "WebSocketPingInterval"
+	KeyChargeProfileMaxStackLevel              = "ChargeProfileMaxStackLevel"
+	KeyChargingScheduleAllowedChargingRateUnit = "ChargingScheduleAllowedChargingRateUnit"
+	KeyChargingScheduleMaxPeriods              = "ChargingScheduleMaxPeriods"
+	KeyMaxChargingProfilesInstalled            = "MaxChargingProfilesInstalled"
+	KeyConnectorSwitch3to1PhaseSupported       = "ConnectorSwitch3to1PhaseSupported"
+)
+
+// configuration value types
+const (
+	TypeString = "string"
+	TypeInt    = "int"
+	TypeBool   = "bool"
+	TypeList   = "csl" // comma separated list
+)
+
+// supportedMeasurands are the measurands the simulator can report
+var supportedMeasurands = []string{
+	string(types.MeasurandPowerActiveImport),
+	string(types.MeasurandEnergyActiveImportRegister),
+	string(types.MeasurandCurrentImport),
+	string(types.MeasurandCurrentOffered),
+	string(types.MeasueandSoC),
+}
+
+// ConfigKey is a single configuration key
+type ConfigKey struct {
+	Value    string   `yaml:"value"`
+	Type     string   `yaml:"type"`     // string (default), int, bool or csl
+	Readonly bool     `yaml:"readonly"` // key cannot be changed by the central system
+	Reboot   bool     `yaml:"reboot"`   // changes take effect after reboot only
+	Values   []string `yaml:"values"`   // allowed values or list items
+}
+
+// validate checks if value is valid for the key's type
+func (k ConfigKey) validate(value string) error {
+	switch k.Type {
+	case TypeInt:
+		if i, err := strconv.Atoi(value); err != nil || i < 0 {
+			return fmt.Errorf("invalid integer: %s", value)
+		}
+
+	case TypeBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("invalid boolean: %s", value)
+		}
+
+	case TypeList:
+		for _, item := range strings.Split(value, ",") {
+			if err := k.allowed(strings.TrimSpace(item)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return k.allowed(value)
+}
+
+// allowed checks if value is one of the key's allowed values
+func (k ConfigKey) allowed(value string) error {
+	if len(k.Values) == 0 {
+		return nil
+	}
+
+	for _, v := range k.Values {
+		if strings.EqualFold(v, value) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid value: %s", value)
+}
+
+// DefaultConfig returns the simulator's built-in configuration keys
+func DefaultConfig(connectors int, meterInterval int) map[string]ConfigKey {
+	return map[string]ConfigKey{
+		KeyNumberOfConnectors:                      {Value: strconv.Itoa(connectors), Type: TypeInt, Readonly: true},
+		KeyHeartbeatInterval:                       {Value: "0", Type: TypeInt},
+		KeyMeterValueSampleInterval:                {Value: strconv.Itoa(meterInterval), Type: TypeInt},
+		KeyMeterValuesSampledData:                  {Value: strings.Join(supportedMeasurands, ","), Type: TypeList, Values: supportedMeasurands},
+		KeyWebSocketPingInterval:                   {Value: "0", Type: TypeInt, Reboot: true},
+		KeyChargeProfileMaxStackLevel:              {Value: "10", Type: TypeInt, Readonly: true},
+		KeyChargingScheduleAllowedChargingRateUnit: {Value: "Current,Power", Type: TypeList, Readonly: true},
+		KeyChargingScheduleMaxPeriods:              {Value: "24", Type: TypeInt, Readonly: true},
+		KeyMaxChargingProfilesInstalled:            {Value: "10", Type: TypeInt, Readonly: true},
+		KeyConnectorSwitch3to1PhaseSupported:       {Value: "false", Type: TypeBool, Readonly: true},
+	}
+}
+
+// LoadConfigSeed reads configuration keys from a yaml or json file
+func LoadConfigSeed(file string) (map[string]ConfigKey, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var res map[string]ConfigKey
+	if err := yaml.Unmarshal(b, &res); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	for key, val := range res {
+		if err := val.validate(val.Value); err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+	}
+
+	return res, nil
+}
+
+// ConfigStore holds the charge point configuration.
+// Values changed by the central system are persisted to the state file.
+type ConfigStore struct {
+	mu      sync.Mutex
+	file    string
+	keys    map[string]ConfigKey
+	changed map[string]string
+}
+
+// NewConfigStore creates a configuration store from the given keys, restoring
+// values previously changed by the central system from the state file if it exists.
+func NewConfigStore(keys map[string]ConfigKey, file string) (*ConfigStore, error) {
+	s := &ConfigStore{
+		file:    file,
+		keys:    make(map[string]ConfigKey, len(keys)),
+		changed: make(map[string]string),
+	}
+
+	for key, val := range keys {
+		s.keys[key] = val
+	}
+
+	if file == "" {
+		return s, nil
+	}
+
+	b, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(b, &s.changed); err != nil {
+		return nil, fmt.Errorf("invalid state: %w", err)
+	}
+
+	for key, value := range s.changed {
+		val, ok := s.keys[key]
+		if !ok || val.Readonly || val.validate(value) != nil {
+			delete(s.changed, key)
+			continue
+		}
+
+		val.Value = value
+		s.keys[key] = val
+	}
+
+	return s, nil
+}
+
+// Get returns the key's value
+func (s *ConfigStore) Get(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	val, ok := s.keys[key]
+	return val.Value, ok
+}
+
+// Int returns the key's value as integer or 0
+func (s *ConfigStore) Int(key string) int {
+	value, _ := s.Get(key)
+	i, _ := strconv.Atoi(value)
+	return i
+}
+
+// List returns the key's value as list
+func (s *ConfigStore) List(key string) []string {
+	value, _ := s.Get(key)
+
+	var res []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			res = append(res, item)
+		}
+	}
+
+	return res
+}
+
+// Set adds or updates a key without persisting it
+func (s *ConfigStore) Set(key string, val ConfigKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[key] = val
+}
+
+// Change updates the key's value on request of the central system.
+// The returned error indicates that the change could not be persisted.
+func (s *ConfigStore) Change(key, value string) (core.ConfigurationStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	val, ok := s.keys[key]
+	if !ok {
+		return core.ConfigurationStatusNotSupported, nil
+	}
+
+	if val.Readonly || val.validate(value) != nil {
+		return core.ConfigurationStatusRejected, nil
+	}
+
+	val.Value = value
+	s.keys[key] = val
+	s.changed[key] = value
+
+	status := core.ConfigurationStatusAccepted
+	if val.Reboot {
+		status = core.ConfigurationStatusRebootRequired
+	}
+
+	return status, s.persist()
+}
+
+// persist writes the changed values to the state file
+func (s *ConfigStore) persist() error {
+	if s.file == "" {
+		return nil
+	}
+
+	b, err := yaml.Marshal(s.changed)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.file), 0o755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(s.file, b, 0o644)
+}
+
+// Keys returns the requested keys and the names of unknown keys.
+// All keys are returned if none are requested.
+func (s *ConfigStore) Keys(requested []string) ([]core.ConfigurationKey, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(requested) == 0 {
+		for key := range s.keys {
+			requested = append(requested, key)
+		}
+		sort.Strings(requested)
+	}
+
+	var (
+		res     []core.ConfigurationKey
+		unknown []string
+	)
+
+	for _, key := range requested {
+		val, ok := s.keys[key]
+		if !ok {
+			unknown = append(unknown, key)
+			continue
+		}
+
+		value := val.Value
+		res = append(res, core.ConfigurationKey{Key: key, Readonly: val.Readonly, Value: &value})
+	}
+
+	return res, unknown
+}
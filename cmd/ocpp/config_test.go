@@ -0,0 +1,59 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigStoreChange(t *testing.T) {
+	s, err := NewConfigStore(DefaultConfig(2, 10), "")
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		key, value string
+		status     core.ConfigurationStatus
+	}{
+		{"Unknown", "1", core.ConfigurationStatusNotSupported},
+		{KeyNumberOfConnectors, "3", core.ConfigurationStatusRejected},
+		{KeyMeterValueSampleInterval, "foo", core.ConfigurationStatusRejected},
+		{KeyMeterValueSampleInterval, "-1", core.ConfigurationStatusRejected},
+		{KeyMeterValueSampleInterval, "5", core.ConfigurationStatusAccepted},
+		{KeyMeterValuesSampledData, "Power.Active.Import,Voltage", core.ConfigurationStatusRejected},
+		{KeyMeterValuesSampledData, "Power.Active.Import,SoC", core.ConfigurationStatusAccepted},
+		{KeyWebSocketPingInterval, "30", core.ConfigurationStatusRebootRequired},
+	} {
+		status, err := s.Change(tc.key, tc.value)
+		require.NoError(t, err)
+		assert.Equal(t, tc.status, status, tc)
+	}
+
+	assert.Equal(t, 5, s.Int(KeyMeterValueSampleInterval))
+	assert.Equal(t, []string{"Power.Active.Import", "SoC"}, s.List(KeyMeterValuesSampledData))
+
+	keys, unknown := s.Keys([]string{KeyNumberOfConnectors, "Unknown"})
+	require.Len(t, keys, 1)
+	assert.Equal(t, "2", *keys[0].Value)
+	assert.True(t, keys[0].Readonly)
+	assert.Equal(t, []string{"Unknown"}, unknown)
+}
+
+func TestConfigStorePersistence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cp0001.yaml")
+
+	s, err := NewConfigStore(DefaultConfig(1, 10), file)
+	require.NoError(t, err)
+
+	status, err := s.Change(KeyMeterValueSampleInterval, "60")
+	require.NoError(t, err)
+	require.Equal(t, core.ConfigurationStatusAccepted, status)
+
+	// restart with different defaults
+	s, err = NewConfigStore(DefaultConfig(1, 30), file)
+	require.NoError(t, err)
+
+	assert.Equal(t, 60, s.Int(KeyMeterValueSampleInterval))
+}
@@ -0,0 +1,759 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/evcc-io/evcc/util/chargingprofile"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/reservation"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+)
+
+const (
+	nominalVoltage = 230
+	defaultCurrent = 16
+
+	busyRetry = time.Second // retry interval for timed actions while the action queue is full
+)
+
+// Connector simulates a single connector of a charge point.
+// All state transitions are executed sequentially by the connector's run loop.
+// Public methods only enqueue actions and never block on the central system or a full action queue.
+type Connector struct {
+	mu  sync.Mutex
+	log *log.Logger
+	cp  ChargePoint
+	id  int
+
+	quirks Quirks
+	config *ConfigStore
+
+	actionC   chan func()
+	intervalC chan time.Duration
+
+	status    core.ChargePointStatus
+	errorCode core.ChargePointErrorCode
+	plugged   bool
+	suspended bool   // EV does not draw power
+	pending   string // idTag authorized before plug-in
+
+	pendingProfile *types.ChargingProfile // tx profile of remote start before plug-in
+
+	reservation *reservationState // active reservation
+
+	inoperative bool // made unavailable by the central system
+	scheduled   bool // becomes inoperative once the active transaction stops
+
+	idTag     string
+	txnId     int
+	txnStart  time.Time
+	lastTxnId int // previous transaction id
+
+	ev       *EV
+	profiles *chargingprofile.Store // charging profiles of the station
+	offered  float64                // offered current limit in A
+	current  float64                // drawn current per phase in A
+	phases   int                    // number of phases used
+
+	energy    float64 // meter register in Wh
+	power     float64 // active power in W
+	metered   time.Time
+	lastMeter *types.MeterValue // previously sent meter value
+}
+
+// reservationState holds the connector for an idTag until expiry
+type reservationState struct {
+	id     int
+	idTag  string
+	expiry time.Time
+}
+
+// NewConnector creates a connector in Available state
+func NewConnector(cp ChargePoint, log *log.Logger, id int, ev *EV, profiles *chargingprofile.Store, config *ConfigStore, quirks Quirks) *Connector {
+	c := &Connector{
+		log:       log,
+		cp:        cp,
+		id:        id,
+		quirks:    quirks,
+		config:    config,
+		actionC:   make(chan func(), 16),
+		intervalC: make(chan time.Duration, 1),
+		status:    core.ChargePointStatusAvailable,
+		errorCode: core.NoError,
+		ev:        ev,
+		profiles:  profiles,
+		phases:    ev.Phases,
+	}
+
+	go c.run()
+
+	return c
+}
+
+func (c *Connector) logf(format string, args ...any) {
+	c.log.Printf("connector %d: "+format, append([]any{c.id}, args...)...)
+}
+
+// run executes queued actions and sends periodic meter values during transactions
+func (c *Connector) run() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	c.SetMeterInterval(time.Duration(c.config.Int(KeyMeterValueSampleInterval)) * time.Second)
+
+	for {
+		select {
+		case action := <-c.actionC:
+			action()
+
+		case interval := <-c.intervalC:
+			if interval > 0 {
+				ticker.Reset(interval)
+			} else {
+				ticker.Stop()
+			}
+
+		case <-ticker.C:
+			if c.TransactionID() != 0 {
+				c.applyLimit()
+				c.sendMeterValues(types.ReadingContextSamplePeriodic)
+			}
+		}
+	}
+}
+
+// SetMeterInterval changes the sample interval of periodic meter values. Zero disables sampling.
+func (c *Connector) SetMeterInterval(interval time.Duration) {
+	// replace pending interval
+	select {
+	case <-c.intervalC:
+	default:
+	}
+
+	c.intervalC <- interval
+}
+
+// do enqueues an action for the run loop. Returns false if the queue is full and the action was dropped.
+// busy returns true if the action queue is full
+func (c *Connector) busy() bool {
+	return len(c.actionC) == cap(c.actionC)
+}
+
+func (c *Connector) do(action func()) bool {
+	select {
+	case c.actionC <- action:
+		return true
+	default:
+		c.logf("busy, action dropped")
+		return false
+	}
+}
+
+// Status returns the connector's status
+func (c *Connector) Status() core.ChargePointStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.status
+}
+
+// TransactionID returns the active transaction id or 0
+func (c *Connector) TransactionID() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.txnId
+}
+
+// Transaction returns the active transaction or nil
+func (c *Connector) Transaction() *chargingprofile.Transaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.transaction()
+}
+
+func (c *Connector) transaction() *chargingprofile.Transaction {
+	if c.txnId == 0 {
+		return nil
+	}
+	return &chargingprofile.Transaction{ID: c.txnId, Start: c.txnStart}
+}
+
+// Free returns true if the connector can accept a new transaction for given idTag.
+// Reserved connectors only accept the reservation's idTag.
+func (c *Connector) Free(idTag string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch c.status {
+	case core.ChargePointStatusUnavailable, core.ChargePointStatusFaulted:
+		return false
+	}
+
+	if c.reservation != nil && c.reservation.idTag != idTag {
+		return false
+	}
+
+	return c.txnId == 0 && c.pending == ""
+}
+
+// idleStatus returns the status without EV. Lock must be held.
+func (c *Connector) idleStatus() core.ChargePointStatus {
+	if c.inoperative {
+		return core.ChargePointStatusUnavailable
+	}
+	if c.reservation != nil {
+		return core.ChargePointStatusReserved
+	}
+	return core.ChargePointStatusAvailable
+}
+
+// setIdle sets the status without EV
+func (c *Connector) setIdle() {
+	c.mu.Lock()
+	status := c.idleStatus()
+	c.mu.Unlock()
+
+	c.setStatus(status, core.NoError)
+}
+
+// String implements Stringer
+func (c *Connector) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return fmt.Sprintf("connector %d: %s (plugged: %t, txn: %d, offered: %.1fA, current: %.1fA@%dp, power: %.0fW, energy: %.0fWh, soc: %.1f%%)",
+		c.id, c.status, c.plugged, c.txnId, c.offered, c.current, c.phases, c.power, c.energy, c.ev.Soc)
+}
+
+// setStatus updates the connector status and notifies the central system if changed
+func (c *Connector) setStatus(status core.ChargePointStatus, errorCode core.ChargePointErrorCode) {
+	c.mu.Lock()
+	changed := c.status != status || c.errorCode != errorCode
+	c.status = status
+	c.errorCode = errorCode
+	c.mu.Unlock()
+
+	if changed {
+		c.sendStatus()
+	}
+}
+
+// sendStatus sends the current status to the central system
+func (c *Connector) sendStatus() {
+	c.mu.Lock()
+	status, errorCode := c.status, c.errorCode
+	c.mu.Unlock()
+
+	c.logf("status: %s (%s)", status, errorCode)
+
+	if _, err := c.cp.StatusNotification(c.id, errorCode, status, func(request *core.StatusNotificationRequest) {
+		if !c.quirks.NoTimestamps {
+			request.Timestamp = types.NewDateTime(time.Now())
+		}
+	}); err != nil {
+		c.logf("StatusNotification: %v", err)
+	}
+}
+
+// updateMeter integrates the energy register and updates the active power
+func (c *Connector) updateMeter() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if !c.metered.IsZero() {
+		energy := c.power * now.Sub(c.metered).Hours()
+		c.energy += energy
+		c.ev.Charge(energy)
+	}
+	c.metered = now
+
+	// charging connectors share the station's max limit
+	c.profiles.SetCharging(c.id, c.txnId != 0 && !c.suspended)
+
+	// offered limit is the effective profile limit capped by the connector's rating
+	offered, phases := float64(defaultCurrent), 0
+	if limit, ok := c.profiles.Limit(c.id, now, c.transaction()); ok {
+		offered = math.Min(offered, limit.Current)
+		phases = limit.Phases
+	}
+	c.offered = offered
+
+	c.current, c.phases = 0, c.ev.Phases
+	if c.txnId != 0 && !c.suspended {
+		c.current, c.phases = c.ev.Draw(offered, phases)
+	}
+
+	c.power = c.current * float64(c.phases) * nominalVoltage
+}
+
+// chargingStatus returns the status during an active transaction
+func (c *Connector) chargingStatus() core.ChargePointStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch {
+	case c.suspended, c.ev.Full():
+		return core.ChargePointStatusSuspendedEV
+	case c.offered < minCurrent:
+		return core.ChargePointStatusSuspendedEVSE
+	default:
+		return core.ChargePointStatusCharging
+	}
+}
+
+// sampledValues returns the current meter readings for the configured measurands
+func (c *Connector) sampledValues(context types.ReadingContext) []types.SampledValue {
+	measurands := make(map[types.Measurand]bool)
+	for _, m := range c.config.List(KeyMeterValuesSampledData) {
+		measurands[types.Measurand(m)] = true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	all := []types.SampledValue{
+		{Measurand: types.MeasurandPowerActiveImport, Value: strconv.FormatFloat(c.power, 'f', 0, 64), Unit: types.UnitOfMeasureW, Context: context},
+		{Measurand: types.MeasurandEnergyActiveImportRegister, Value: strconv.FormatFloat(c.energy, 'f', 0, 64), Unit: types.UnitOfMeasureWh, Context: context},
+		{Measurand: types.MeasurandCurrentOffered, Value: strconv.FormatFloat(c.offered, 'f', 1, 64), Unit: types.UnitOfMeasureA, Context: context},
+		{Measurand: types.MeasueandSoC, Value: strconv.FormatFloat(c.ev.Soc, 'f', 0, 64), Unit: types.UnitOfMeasurePercent, Context: context},
+	}
+
+	for i, phase := range []types.Phase{types.PhaseL1, types.PhaseL2, types.PhaseL3} {
+		var current float64
+		if i < c.phases {
+			current = c.current
+		}
+
+		if c.quirks.NoPhaseLabels {
+			phase = ""
+		}
+
+		all = append(all, types.SampledValue{
+			Measurand: types.MeasurandCurrentImport, Phase: phase, Value: strconv.FormatFloat(current, 'f', 1, 64), Unit: types.UnitOfMeasureA, Context: context,
+		})
+	}
+
+	var res []types.SampledValue
+	for _, sample := range all {
+		if measurands[sample.Measurand] {
+			res = append(res, sample)
+		}
+	}
+
+	return res
+}
+
+// sendMeterValues sends the current meter readings to the central system
+func (c *Connector) sendMeterValues(context types.ReadingContext) {
+	c.updateMeter()
+
+	samples := c.sampledValues(context)
+	if len(samples) == 0 {
+		return
+	}
+
+	txnId := c.reportedTransactionID()
+	meterValue := types.MeterValue{
+		Timestamp:    types.NewDateTime(time.Now()),
+		SampledValue: samples,
+	}
+
+	c.mu.Lock()
+	previous := c.lastMeter
+	c.lastMeter = &meterValue
+	c.mu.Unlock()
+
+	values := []types.MeterValue{meterValue}
+	if c.quirks.OutOfOrderMeterValues && previous != nil {
+		values = append(values, *previous)
+	}
+
+	for _, value := range values {
+		if _, err := c.cp.MeterValues(c.id, []types.MeterValue{value}, func(request *core.MeterValuesRequest) {
+			if txnId != 0 {
+				request.TransactionId = &txnId
+			}
+		}); err != nil {
+			c.logf("MeterValues: %v", err)
+		}
+	}
+}
+
+// reportedTransactionID returns the transaction id sent to the central system
+func (c *Connector) reportedTransactionID() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.quirks.StaleTransactionId && c.txnId != 0 && c.lastTxnId != 0 {
+		return c.lastTxnId
+	}
+
+	return c.txnId
+}
+
+// startTransaction starts a transaction for given idTag
+func (c *Connector) startTransaction(idTag string, profile *types.ChargingProfile) {
+	c.updateMeter()
+
+	c.mu.Lock()
+	meterStart := int(c.energy)
+	c.pending = ""
+	c.pendingProfile = nil
+	res := c.reservation
+	c.mu.Unlock()
+
+	var reservationId *int
+	if res != nil {
+		if res.idTag != idTag {
+			c.logf("StartTransaction: idTag %s does not match reservation %d", idTag, res.id)
+			c.setStatus(core.ChargePointStatusFinishing, core.NoError)
+			return
+		}
+
+		reservationId = &res.id
+
+		c.mu.Lock()
+		c.reservation = nil
+		c.mu.Unlock()
+	}
+
+	resp, err := c.cp.StartTransaction(c.id, idTag, meterStart, types.NewDateTime(time.Now()), func(request *core.StartTransactionRequest) {
+		request.ReservationId = reservationId
+	})
+	if err != nil {
+		c.logf("StartTransaction: %v", err)
+		return
+	}
+
+	if resp.IdTagInfo == nil || resp.IdTagInfo.Status != types.AuthorizationStatusAccepted {
+		c.logf("StartTransaction: idTag %s not accepted", idTag)
+		c.setStatus(core.ChargePointStatusFinishing, core.NoError)
+		return
+	}
+
+	c.mu.Lock()
+	c.idTag = idTag
+	c.txnId = resp.TransactionId
+	c.txnStart = time.Now()
+	c.mu.Unlock()
+
+	if profile != nil {
+		profile.TransactionId = resp.TransactionId
+		if err := c.profiles.Set(c.id, profile); err != nil {
+			c.logf("invalid charging profile: %v", err)
+		}
+	}
+
+	c.logf("transaction started: %d", resp.TransactionId)
+
+	c.updateMeter()
+	c.setStatus(c.chargingStatus(), core.NoError)
+	c.sendMeterValues(types.ReadingContextTransactionBegin)
+}
+
+// stopTransaction stops the active transaction
+func (c *Connector) stopTransaction(reason core.Reason) {
+	txnId := c.TransactionID()
+	if txnId == 0 {
+		return
+	}
+
+	c.updateMeter()
+
+	reportedId := c.reportedTransactionID()
+
+	c.mu.Lock()
+	meterStop, idTag := int(c.energy), c.idTag
+	c.lastTxnId = txnId
+	c.txnId = 0
+	c.txnStart = time.Time{}
+	c.idTag = ""
+	c.power = 0
+	scheduled := c.scheduled
+	if scheduled {
+		c.inoperative = true
+		c.scheduled = false
+	}
+	c.mu.Unlock()
+
+	// tx profiles end with the transaction
+	c.profiles.ClearTransaction(c.id)
+
+	if _, err := c.cp.StopTransaction(meterStop, types.NewDateTime(time.Now()), reportedId, func(request *core.StopTransactionRequest) {
+		request.IdTag = idTag
+		request.Reason = reason
+	}); err != nil {
+		c.logf("StopTransaction: %v", err)
+	}
+
+	c.logf("transaction stopped: %d (%s)", txnId, reason)
+
+	c.sendMeterValues(types.ReadingContextTransactionEnd)
+
+	if scheduled {
+		c.logf("scheduled availability change applied")
+	}
+}
+
+// Plug connects the EV
+func (c *Connector) Plug() {
+	c.do(func() {
+		c.mu.Lock()
+		if c.plugged {
+			c.mu.Unlock()
+			return
+		}
+		c.plugged = true
+		idTag, profile := c.pending, c.pendingProfile
+		inoperative := c.inoperative
+		c.mu.Unlock()
+
+		if inoperative {
+			return
+		}
+
+		c.setStatus(core.ChargePointStatusPreparing, core.NoError)
+
+		if idTag != "" {
+			c.startTransaction(idTag, profile)
+		}
+	})
+}
+
+// Unplug disconnects the EV, stopping any active transaction
+func (c *Connector) Unplug() {
+	c.do(func() {
+		c.mu.Lock()
+		if !c.plugged {
+			c.mu.Unlock()
+			return
+		}
+		c.plugged = false
+		c.suspended = false
+		c.mu.Unlock()
+
+		c.stopTransaction(core.ReasonEVDisconnected)
+		c.setIdle()
+	})
+}
+
+// Authorize starts a transaction for given idTag once the EV is plugged.
+// The optional profile is installed as TxProfile for the transaction.
+// Returns false if the connector is busy.
+func (c *Connector) Authorize(idTag string, profile *types.ChargingProfile) bool {
+	return c.do(func() {
+		if c.TransactionID() != 0 {
+			return
+		}
+
+		c.mu.Lock()
+		plugged := c.plugged
+		if !plugged {
+			c.pending = idTag
+			c.pendingProfile = profile
+		}
+		c.mu.Unlock()
+
+		if !plugged {
+			c.setStatus(core.ChargePointStatusPreparing, core.NoError)
+			return
+		}
+
+		c.startTransaction(idTag, profile)
+	})
+}
+
+// applyLimit updates power and status after the offered current has changed
+func (c *Connector) applyLimit() {
+	c.updateMeter()
+
+	if c.TransactionID() != 0 {
+		c.setStatus(c.chargingStatus(), core.NoError)
+	}
+}
+
+// Stop stops the active transaction with given reason. Returns false if the connector is busy.
+func (c *Connector) Stop(reason core.Reason) bool {
+	return c.do(func() {
+		c.mu.Lock()
+		active := c.txnId != 0 || c.pending != ""
+		plugged := c.plugged
+		c.pending = ""
+		c.pendingProfile = nil
+		c.mu.Unlock()
+
+		if !active {
+			return
+		}
+
+		c.stopTransaction(reason)
+
+		if plugged && !c.Inoperative() {
+			c.setStatus(core.ChargePointStatusFinishing, core.NoError)
+		} else {
+			c.setIdle()
+		}
+	})
+}
+
+// Suspend lets the EV pause or resume drawing power
+func (c *Connector) Suspend(suspend bool) {
+	c.do(func() {
+		c.mu.Lock()
+		c.suspended = suspend
+		c.mu.Unlock()
+
+		c.applyLimit()
+	})
+}
+
+// Fault sets or clears an error condition
+func (c *Connector) Fault(errorCode core.ChargePointErrorCode) {
+	c.do(func() {
+		if errorCode != core.NoError {
+			c.stopTransaction(core.ReasonOther)
+			c.setStatus(core.ChargePointStatusFaulted, errorCode)
+			return
+		}
+
+		c.mu.Lock()
+		plugged := c.plugged && !c.inoperative
+		c.mu.Unlock()
+
+		if plugged {
+			c.setStatus(core.ChargePointStatusPreparing, core.NoError)
+		} else {
+			c.setIdle()
+		}
+	})
+}
+
+// Trigger sends the requested message. Returns false if the connector is busy.
+func (c *Connector) Trigger(message func()) bool {
+	return c.do(message)
+}
+
+// Inoperative returns true if the connector has been made unavailable
+func (c *Connector) Inoperative() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.inoperative
+}
+
+// available returns true unless the connector is or becomes inoperative
+func (c *Connector) available() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !c.inoperative && !c.scheduled
+}
+
+// SetAvailable changes the operative state of the connector. Making the connector inoperative
+// during a transaction is scheduled until the transaction stops. Returns false if the connector is busy.
+func (c *Connector) SetAvailable(available bool) bool {
+	return c.do(func() {
+		c.mu.Lock()
+		if !available && c.txnId != 0 {
+			c.scheduled = true
+			c.mu.Unlock()
+			c.logf("unavailable after transaction")
+			return
+		}
+
+		c.inoperative = !available
+		c.scheduled = false
+		plugged := c.plugged
+		c.mu.Unlock()
+
+		if !available {
+			c.setStatus(core.ChargePointStatusUnavailable, core.NoError)
+			return
+		}
+
+		if plugged {
+			c.setStatus(core.ChargePointStatusPreparing, core.NoError)
+		} else {
+			c.setIdle()
+		}
+	})
+}
+
+// Reserve reserves the connector for idTag until expiry. Reservations with the same id are replaced.
+func (c *Connector) Reserve(id int, idTag string, expiry time.Time) reservation.ReservationStatus {
+	c.mu.Lock()
+
+	var status reservation.ReservationStatus
+	switch {
+	case c.status == core.ChargePointStatusFaulted:
+		status = reservation.ReservationStatusFaulted
+	case c.status == core.ChargePointStatusUnavailable:
+		status = reservation.ReservationStatusUnavailable
+	case c.plugged || c.txnId != 0 || c.pending != "":
+		status = reservation.ReservationStatusOccupied
+	case c.reservation != nil && c.reservation.id != id:
+		status = reservation.ReservationStatusOccupied
+	default:
+		status = reservation.ReservationStatusAccepted
+		c.reservation = &reservationState{id: id, idTag: idTag, expiry: expiry}
+	}
+
+	c.mu.Unlock()
+
+	if status != reservation.ReservationStatusAccepted {
+		return status
+	}
+
+	if !c.do(c.setIdle) {
+		c.mu.Lock()
+		c.reservation = nil
+		c.mu.Unlock()
+
+		return reservation.ReservationStatusRejected
+	}
+
+	c.logf("reserved for %s until %s", idTag, expiry.Format(time.RFC3339))
+
+	// expire via the queue, retry while the queue is full
+	var expire func()
+	expire = func() {
+		if !c.do(func() { c.endReservation(id, "expired") }) {
+			time.AfterFunc(busyRetry, expire)
+		}
+	}
+	time.AfterFunc(time.Until(expiry), expire)
+
+	return status
+}
+
+// CancelReservation cancels the reservation with given id
+func (c *Connector) CancelReservation(id int) bool {
+	c.mu.Lock()
+	ok := c.reservation != nil && c.reservation.id == id
+	c.mu.Unlock()
+
+	return ok && c.do(func() { c.endReservation(id, "cancelled") })
+}
+
+// endReservation removes the reservation with given id if still active
+func (c *Connector) endReservation(id int, reason string) {
+	c.mu.Lock()
+	active := c.reservation != nil && c.reservation.id == id
+	if active {
+		c.reservation = nil
+	}
+	reserved := c.status == core.ChargePointStatusReserved
+	c.mu.Unlock()
+
+	if !active {
+		return
+	}
+
+	c.logf("reservation %d %s", id, reason)
+
+	if reserved {
+		c.setIdle()
+	}
+}
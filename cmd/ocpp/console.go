@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+)
+
+const consoleHelp = `commands (connector defaults to 1):
+  plug [connector]                 connect EV
+  unplug [connector]               disconnect EV
+  authorize <idtag> [connector]    authorize idTag and start transaction
+  stop [connector]                 stop transaction locally
+  suspend [connector]              EV stops drawing power
+  resume [connector]               EV resumes drawing power
+  fault <errorcode> [connector]    raise error, e.g. GroundFailure
+  clear [connector]                clear error
+  status                           print connector status
+  station <id>                     select station for following commands`
+
+// console executes interactive commands against the selected station
+func console(stations []*Station, r io.Reader) {
+	s := stations[0]
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		args := strings.Fields(scanner.Text())
+
+		switch {
+		case len(args) > 0 && args[0] == "station":
+			if len(args) < 2 {
+				fmt.Println("selected station:", s.id)
+				continue
+			}
+
+			if station := stationByID(stations, args[1]); station != nil {
+				s = station
+			} else {
+				fmt.Println("unknown station:", args[1])
+			}
+
+		case len(args) > 0 && args[0] == "status":
+			for _, station := range stations {
+				for _, conn := range station.connectors {
+					fmt.Println(station.id, conn)
+				}
+			}
+
+		default:
+			if err := execute(s, args); err != nil {
+				fmt.Println(err)
+			}
+		}
+	}
+}
+
+// stationByID returns the station with given id or nil
+func stationByID(stations []*Station, id string) *Station {
+	for _, s := range stations {
+		if s.id == id {
+			return s
+		}
+	}
+	return nil
+}
+
+// connectorArg returns the connector addressed by the optional argument at given index
+func connectorArg(s *Station, args []string, idx int) (*Connector, error) {
+	id := 1
+	if len(args) > idx {
+		var err error
+		if id, err = strconv.Atoi(args[idx]); err != nil {
+			return nil, fmt.Errorf("invalid connector: %s", args[idx])
+		}
+	}
+
+	conn := s.Connector(id)
+	if conn == nil {
+		return nil, fmt.Errorf("unknown connector: %d", id)
+	}
+
+	return conn, nil
+}
+
+func execute(s *Station, args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+
+	// commands with mandatory argument
+	argIdx := 1
+	switch args[0] {
+	case "authorize", "fault":
+		if len(args) < 2 {
+			return fmt.Errorf("missing argument: %s", args[0])
+		}
+		argIdx = 2
+	}
+
+	if args[0] == "status" {
+		for _, conn := range s.connectors {
+			fmt.Println(conn)
+		}
+		return nil
+	}
+
+	conn, err := connectorArg(s, args, argIdx)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "plug":
+		conn.Plug()
+
+	case "unplug":
+		conn.Unplug()
+
+	case "authorize":
+		idTag := args[1]
+		go func() {
+			res, err := s.cp.Authorize(idTag)
+			if err != nil {
+				s.log.Println("Authorize:", err)
+				return
+			}
+
+			if res.IdTagInfo == nil || res.IdTagInfo.Status != types.AuthorizationStatusAccepted {
+				s.log.Printf("Authorize: idTag %s rejected", idTag)
+				return
+			}
+
+			conn.Authorize(idTag, nil)
+		}()
+
+	case "stop":
+		conn.Stop(core.ReasonLocal)
+
+	case "suspend":
+		conn.Suspend(true)
+
+	case "resume":
+		conn.Suspend(false)
+
+	case "fault":
+		conn.Fault(core.ChargePointErrorCode(args[1]))
+
+	case "clear":
+		conn.Fault(core.NoError)
+
+	default:
+		return fmt.Errorf("unknown command: %s\n%s", args[0], consoleHelp)
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import "math"
+
+// minCurrent is the minimum current an EV can charge with according to IEC 61851
+const minCurrent = 6
+
+// EV simulates the vehicle's battery and charging behaviour
+type EV struct {
+	Capacity   float64 // battery capacity in kWh
+	Soc        float64 // state of charge in %
+	MaxCurrent float64 // max AC current per phase in A
+	Phases     int     // number of phases the onboard charger can use
+	TaperSoc   float64 // soc above which charging current decreases linearly
+}
+
+// NewEV creates an EV with given parameters
+func NewEV(capacity, soc, maxCurrent float64, phases int, taperSoc float64) *EV {
+	return &EV{
+		Capacity:   capacity,
+		Soc:        soc,
+		MaxCurrent: maxCurrent,
+		Phases:     phases,
+		TaperSoc:   taperSoc,
+	}
+}
+
+// Full returns true if the battery is fully charged
+func (ev *EV) Full() bool {
+	return ev.Soc >= 100
+}
+
+// Draw returns the current per phase and number of phases the EV draws given the offered current and phases
+func (ev *EV) Draw(offered float64, phases int) (float64, int) {
+	if phases == 0 || phases > ev.Phases {
+		phases = ev.Phases
+	}
+
+	if ev.Full() || offered < minCurrent {
+		return 0, phases
+	}
+
+	current := math.Min(offered, ev.MaxCurrent)
+
+	// reduce current linearly towards minCurrent at 100% soc
+	if ev.TaperSoc < 100 && ev.Soc > ev.TaperSoc {
+		taper := minCurrent + (ev.MaxCurrent-minCurrent)*(100-ev.Soc)/(100-ev.TaperSoc)
+		current = math.Min(current, taper)
+	}
+
+	return current, phases
+}
+
+// Charge adds given energy in Wh to the battery
+func (ev *EV) Charge(energy float64) {
+	if ev.Capacity <= 0 {
+		return
+	}
+
+	ev.Soc = math.Min(100, ev.Soc+energy/ev.Capacity/10)
+}
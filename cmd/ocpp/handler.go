@@ -1,78 +1,164 @@
 package main
 
 import (
-	"fmt"
+	"errors"
 
+	"github.com/lorenzodonini/ocpp-go/ocpp"
 	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
 	"github.com/lorenzodonini/ocpp-go/ocpp1.6/remotetrigger"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/reservation"
 	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
 )
 
 type ChargePointHandler struct {
-	triggerC chan remotetrigger.MessageTrigger
+	station *Station
+}
+
+// trace prints and records requests received from the central system
+func (handler *ChargePointHandler) trace(request ocpp.Request) {
+	handler.station.log.Printf("%T %+v", request, request)
+	handler.station.received.Add(request.GetFeatureName())
 }
 
 func (handler *ChargePointHandler) OnChangeAvailability(request *core.ChangeAvailabilityRequest) (confirmation *core.ChangeAvailabilityConfirmation, err error) {
-	fmt.Printf("%T %+v\n", request, request)
-	return core.NewChangeAvailabilityConfirmation(core.AvailabilityStatusAccepted), nil
+	handler.trace(request)
+
+	status := handler.station.ChangeAvailability(request.ConnectorId, request.Type == core.AvailabilityTypeOperative)
+
+	return core.NewChangeAvailabilityConfirmation(status), nil
 }
 
 func (handler *ChargePointHandler) OnChangeConfiguration(request *core.ChangeConfigurationRequest) (confirmation *core.ChangeConfigurationConfirmation, err error) {
-	fmt.Printf("%T %+v\n", request, request)
-	return core.NewChangeConfigurationConfirmation(core.ConfigurationStatusAccepted), nil
+	handler.trace(request)
+
+	status, err := handler.station.config.Change(request.Key, request.Value)
+	if err != nil {
+		handler.station.log.Println("ChangeConfiguration:", err)
+	}
+
+	if status == core.ConfigurationStatusAccepted {
+		handler.station.configure(request.Key)
+	}
+
+	return core.NewChangeConfigurationConfirmation(status), nil
 }
 
 func (handler *ChargePointHandler) OnClearCache(request *core.ClearCacheRequest) (confirmation *core.ClearCacheConfirmation, err error) {
-	fmt.Printf("%T %+v\n", request, request)
+	handler.trace(request)
 	return core.NewClearCacheConfirmation(core.ClearCacheStatusAccepted), nil
 }
 
 func (handler *ChargePointHandler) OnDataTransfer(request *core.DataTransferRequest) (confirmation *core.DataTransferConfirmation, err error) {
-	fmt.Printf("%T %+v\n", request, request)
+	handler.trace(request)
 	return core.NewDataTransferConfirmation(core.DataTransferStatusAccepted), nil
 }
 
 func (handler *ChargePointHandler) OnGetConfiguration(request *core.GetConfigurationRequest) (confirmation *core.GetConfigurationConfirmation, err error) {
-	fmt.Printf("%T %+v\n", request, request)
-	one := "1"
-	return core.NewGetConfigurationConfirmation([]core.ConfigurationKey{
-		{Key: "NumberOfConnectors", Value: &one},
-		{Key: "ChargeProfileMaxStackLevel", Value: &one},
-		{Key: "ChargingScheduleMaxPeriods", Value: &one},
-		{Key: "MaxChargingProfilesInstalled", Value: &one},
-		{Key: "ChargingScheduleAllowedChargingRateUnit", Value: &one},
-	}), nil
+	handler.trace(request)
+
+	if handler.station.quirks.RejectGetConfiguration {
+		return nil, errors.New("GetConfiguration not supported")
+	}
+
+	keys, unknown := handler.station.config.Keys(request.Key)
+
+	res := core.NewGetConfigurationConfirmation(keys)
+	res.UnknownKey = unknown
+
+	return res, nil
 }
 
 func (handler *ChargePointHandler) OnRemoteStartTransaction(request *core.RemoteStartTransactionRequest) (confirmation *core.RemoteStartTransactionConfirmation, err error) {
-	fmt.Printf("%T %+v\n", request, request)
+	handler.trace(request)
+
+	conn := handler.station.RemoteStartConnector(request.ConnectorId, request.IdTag)
+	if conn == nil {
+		return core.NewRemoteStartTransactionConfirmation(types.RemoteStartStopStatusRejected), nil
+	}
+
+	if !conn.Authorize(request.IdTag, request.ChargingProfile) {
+		return core.NewRemoteStartTransactionConfirmation(types.RemoteStartStopStatusRejected), nil
+	}
+
 	return core.NewRemoteStartTransactionConfirmation(types.RemoteStartStopStatusAccepted), nil
 }
 
 func (handler *ChargePointHandler) OnRemoteStopTransaction(request *core.RemoteStopTransactionRequest) (confirmation *core.RemoteStopTransactionConfirmation, err error) {
-	fmt.Printf("%T %+v\n", request, request)
+	handler.trace(request)
+
+	conn := handler.station.ConnectorByTransaction(request.TransactionId)
+	if request.TransactionId == 0 || conn == nil {
+		return core.NewRemoteStopTransactionConfirmation(types.RemoteStartStopStatusRejected), nil
+	}
+
+	if !conn.Stop(core.ReasonRemote) {
+		return core.NewRemoteStopTransactionConfirmation(types.RemoteStartStopStatusRejected), nil
+	}
+
 	return core.NewRemoteStopTransactionConfirmation(types.RemoteStartStopStatusAccepted), nil
 }
 
 func (handler *ChargePointHandler) OnReset(request *core.ResetRequest) (confirmation *core.ResetConfirmation, err error) {
-	fmt.Printf("%T %+v\n", request, request)
+	handler.trace(request)
+
+	reason := core.ReasonSoftReset
+	if request.Type == core.ResetTypeHard {
+		reason = core.ReasonHardReset
+	}
+
+	handler.station.Reset(reason)
+
 	return core.NewResetConfirmation(core.ResetStatusAccepted), nil
 }
 
 func (handler *ChargePointHandler) OnUnlockConnector(request *core.UnlockConnectorRequest) (confirmation *core.UnlockConnectorConfirmation, err error) {
-	fmt.Printf("%T %+v\n", request, request)
+	handler.trace(request)
+
+	conn := handler.station.Connector(request.ConnectorId)
+	if conn == nil {
+		return core.NewUnlockConnectorConfirmation(core.UnlockStatusNotSupported), nil
+	}
+
+	if conn.TransactionID() != 0 && !conn.Stop(core.ReasonUnlockCommand) {
+		return core.NewUnlockConnectorConfirmation(core.UnlockStatusUnlockFailed), nil
+	}
+
 	return core.NewUnlockConnectorConfirmation(core.UnlockStatusUnlocked), nil
 }
 
 func (handler *ChargePointHandler) OnTriggerMessage(request *remotetrigger.TriggerMessageRequest) (confirmation *remotetrigger.TriggerMessageConfirmation, err error) {
-	fmt.Printf("%T %+v\n", request, request)
+	handler.trace(request)
 
-	if c := handler.triggerC; request != nil && c != nil {
-		select {
-		case c <- request.RequestedMessage:
-		default:
-		}
+	if !handler.station.Trigger(request) {
+		return remotetrigger.NewTriggerMessageConfirmation(remotetrigger.TriggerMessageStatusRejected), nil
 	}
 
 	return remotetrigger.NewTriggerMessageConfirmation(remotetrigger.TriggerMessageStatusAccepted), nil
 }
+
+func (handler *ChargePointHandler) OnReserveNow(request *reservation.ReserveNowRequest) (confirmation *reservation.ReserveNowConfirmation, err error) {
+	handler.trace(request)
+
+	// reservations of connector 0 are not supported
+	conn := handler.station.Connector(request.ConnectorId)
+	if conn == nil {
+		return reservation.NewReserveNowConfirmation(reservation.ReservationStatusRejected), nil
+	}
+
+	status := conn.Reserve(request.ReservationId, request.IdTag, request.ExpiryDate.Time)
+
+	return reservation.NewReserveNowConfirmation(status), nil
+}
+
+func (handler *ChargePointHandler) OnCancelReservation(request *reservation.CancelReservationRequest) (confirmation *reservation.CancelReservationConfirmation, err error) {
+	handler.trace(request)
+
+	status := reservation.CancelReservationStatusRejected
+	for _, conn := range handler.station.connectors {
+		if conn.CancelReservation(request.ReservationId) {
+			status = reservation.CancelReservationStatusAccepted
+		}
+	}
+
+	return reservation.NewCancelReservationConfirmation(status), nil
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/evcc-io/evcc/util/ocpp201"
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/remotetrigger"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/availability"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/remotecontrol"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/transactions"
+	types201 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// ChargingStationHandler handles OCPP 2.0.1 requests. Since handler names equal their OCPP 1.6
+// counterparts, it is separate from the ChargePointHandler.
+type ChargingStationHandler struct {
+	station *Station
+	cp      *chargingStation201
+}
+
+// trace prints and records requests received from the central system
+func (handler *ChargingStationHandler) trace(request ocpp.Request) {
+	handler.station.log.Printf("%T %+v", request, request)
+	handler.station.received.Add(request.GetFeatureName())
+}
+
+// provisioning
+
+func (handler *ChargingStationHandler) OnGetBaseReport(request *provisioning.GetBaseReportRequest) (response *provisioning.GetBaseReportResponse, err error) {
+	handler.trace(request)
+	return provisioning.NewGetBaseReportResponse(types201.GenericDeviceModelStatusNotSupported), nil
+}
+
+func (handler *ChargingStationHandler) OnGetReport(request *provisioning.GetReportRequest) (response *provisioning.GetReportResponse, err error) {
+	handler.trace(request)
+	return provisioning.NewGetReportResponse(types201.GenericDeviceModelStatusNotSupported), nil
+}
+
+func (handler *ChargingStationHandler) OnGetVariables(request *provisioning.GetVariablesRequest) (response *provisioning.GetVariablesResponse, err error) {
+	handler.trace(request)
+
+	if handler.station.quirks.RejectGetConfiguration {
+		return nil, errors.New("GetVariables not supported")
+	}
+
+	var res []provisioning.GetVariableResult
+	for _, data := range request.GetVariableData {
+		result := provisioning.GetVariableResult{
+			AttributeStatus: provisioning.GetVariableStatusUnknownVariable,
+			AttributeType:   data.AttributeType,
+			Component:       data.Component,
+			Variable:        data.Variable,
+		}
+
+		if key, ok := ocpp201.Key(data.Component, data.Variable); ok {
+			if value, ok := handler.station.config.Get(key); ok {
+				result.AttributeStatus = provisioning.GetVariableStatusAccepted
+				result.AttributeValue = value
+			}
+		}
+
+		// only actual values are supported
+		if data.AttributeType != "" && data.AttributeType != types201.AttributeActual {
+			result.AttributeStatus = provisioning.GetVariableStatusNotSupported
+			result.AttributeValue = ""
+		}
+
+		res = append(res, result)
+	}
+
+	return provisioning.NewGetVariablesResponse(res), nil
+}
+
+func (handler *ChargingStationHandler) OnSetVariables(request *provisioning.SetVariablesRequest) (response *provisioning.SetVariablesResponse, err error) {
+	handler.trace(request)
+
+	var res []provisioning.SetVariableResult
+	for _, data := range request.SetVariableData {
+		result := provisioning.SetVariableResult{
+			AttributeStatus: provisioning.SetVariableStatusUnknownVariable,
+			AttributeType:   data.AttributeType,
+			Component:       data.Component,
+			Variable:        data.Variable,
+		}
+
+		key, ok := ocpp201.Key(data.Component, data.Variable)
+
+		switch {
+		case data.AttributeType != "" && data.AttributeType != types201.AttributeActual:
+			result.AttributeStatus = provisioning.SetVariableStatusNotSupported
+
+		case ok:
+			status, err := handler.station.config.Change(key, data.AttributeValue)
+			if err != nil {
+				handler.station.log.Println("SetVariables:", err)
+			}
+
+			switch status {
+			case core.ConfigurationStatusAccepted:
+				result.AttributeStatus = provisioning.SetVariableStatusAccepted
+				handler.station.configure(key)
+			case core.ConfigurationStatusRebootRequired:
+				result.AttributeStatus = provisioning.SetVariableStatusRebootRequired
+			case core.ConfigurationStatusRejected:
+				result.AttributeStatus = provisioning.SetVariableStatusRejected
+			}
+		}
+
+		res = append(res, result)
+	}
+
+	return provisioning.NewSetVariablesResponse(res), nil
+}
+
+func (handler *ChargingStationHandler) OnReset(request *provisioning.ResetRequest) (response *provisioning.ResetResponse, err error) {
+	handler.trace(request)
+
+	// resetting single EVSEs is not supported
+	if request.EvseID != nil && *request.EvseID > 0 {
+		return provisioning.NewResetResponse(provisioning.ResetStatusRejected), nil
+	}
+
+	reason := core.ReasonSoftReset
+	if request.Type == provisioning.ResetTypeImmediate {
+		reason = core.ReasonHardReset
+	}
+
+	handler.station.Reset(reason)
+
+	return provisioning.NewResetResponse(provisioning.ResetStatusAccepted), nil
+}
+
+func (handler *ChargingStationHandler) OnSetNetworkProfile(request *provisioning.SetNetworkProfileRequest) (response *provisioning.SetNetworkProfileResponse, err error) {
+	handler.trace(request)
+	return &provisioning.SetNetworkProfileResponse{Status: provisioning.SetNetworkProfileStatusRejected}, nil
+}
+
+// availability
+
+func (handler *ChargingStationHandler) OnChangeAvailability(request *availability.ChangeAvailabilityRequest) (response *availability.ChangeAvailabilityResponse, err error) {
+	handler.trace(request)
+
+	var evse int
+	if request.Evse != nil {
+		evse = request.Evse.ID
+	}
+
+	status := handler.station.ChangeAvailability(evse, request.OperationalStatus == availability.OperationalStatusOperative)
+
+	return availability.NewChangeAvailabilityResponse(availability.ChangeAvailabilityStatus(status)), nil
+}
+
+// transactions
+
+func (handler *ChargingStationHandler) OnGetTransactionStatus(request *transactions.GetTransactionStatusRequest) (response *transactions.GetTransactionStatusResponse, err error) {
+	handler.trace(request)
+
+	// messages are not queued
+	res := transactions.NewGetTransactionStatusResponse(false)
+
+	if request.TransactionID != "" {
+		txnId, _ := strconv.Atoi(request.TransactionID)
+		ongoing := txnId != 0 && handler.station.ConnectorByTransaction(txnId) != nil
+		res.OngoingIndicator = &ongoing
+	}
+
+	return res, nil
+}
+
+// remote control
+
+func (handler *ChargingStationHandler) OnRequestStartTransaction(request *remotecontrol.RequestStartTransactionRequest) (response *remotecontrol.RequestStartTransactionResponse, err error) {
+	handler.trace(request)
+
+	res := remotecontrol.NewRequestStartTransactionResponse(remotecontrol.RequestStartStopStatusRejected)
+
+	profile, err := ocpp201.ChargingProfile16(request.ChargingProfile)
+	if err != nil {
+		handler.station.log.Println("RequestStartTransaction:", err)
+		return res, nil
+	}
+
+	// ocpp-go models the request's id token by its type only, hence the type is used as id tag
+	idTag := string(request.IDToken)
+
+	conn := handler.station.RemoteStartConnector(request.EvseID, idTag)
+	if conn == nil {
+		return res, nil
+	}
+
+	handler.cp.RemoteStart(conn.id, request)
+
+	if !conn.Authorize(idTag, profile) {
+		return res, nil
+	}
+
+	res.Status = remotecontrol.RequestStartStopStatusAccepted
+
+	return res, nil
+}
+
+func (handler *ChargingStationHandler) OnRequestStopTransaction(request *remotecontrol.RequestStopTransactionRequest) (response *remotecontrol.RequestStopTransactionResponse, err error) {
+	handler.trace(request)
+
+	txnId, _ := strconv.Atoi(request.TransactionID)
+
+	conn := handler.station.ConnectorByTransaction(txnId)
+	if txnId == 0 || conn == nil {
+		return remotecontrol.NewRequestStopTransactionResponse(remotecontrol.RequestStartStopStatusRejected), nil
+	}
+
+	if !conn.Stop(core.ReasonRemote) {
+		return remotecontrol.NewRequestStopTransactionResponse(remotecontrol.RequestStartStopStatusRejected), nil
+	}
+
+	return remotecontrol.NewRequestStopTransactionResponse(remotecontrol.RequestStartStopStatusAccepted), nil
+}
+
+func (handler *ChargingStationHandler) OnTriggerMessage(request *remotecontrol.TriggerMessageRequest) (response *remotecontrol.TriggerMessageResponse, err error) {
+	handler.trace(request)
+
+	var message string
+	switch request.RequestedMessage {
+	case remotecontrol.MessageTriggerBootNotification:
+		message = core.BootNotificationFeatureName
+	case remotecontrol.MessageTriggerHeartbeat:
+		message = core.HeartbeatFeatureName
+	case remotecontrol.MessageTriggerStatusNotification:
+		message = core.StatusNotificationFeatureName
+	case remotecontrol.MessageTriggerMeterValues, remotecontrol.MessageTriggerTransactionEvent:
+		// meter values are sent as transaction event during transactions
+		message = core.MeterValuesFeatureName
+	default:
+		return remotecontrol.NewTriggerMessageResponse(remotecontrol.TriggerMessageStatusNotImplemented), nil
+	}
+
+	trigger := remotetrigger.NewTriggerMessageRequest(remotetrigger.MessageTrigger(message))
+	if request.Evse != nil {
+		trigger.ConnectorId = &request.Evse.ID
+	}
+
+	if !handler.station.Trigger(trigger) {
+		return remotecontrol.NewTriggerMessageResponse(remotecontrol.TriggerMessageStatusRejected), nil
+	}
+
+	return remotecontrol.NewTriggerMessageResponse(remotecontrol.TriggerMessageStatusAccepted), nil
+}
+
+func (handler *ChargingStationHandler) OnUnlockConnector(request *remotecontrol.UnlockConnectorRequest) (response *remotecontrol.UnlockConnectorResponse, err error) {
+	handler.trace(request)
+
+	conn := handler.station.Connector(request.EvseID)
+	if conn == nil || request.ConnectorID != evseConnector {
+		return remotecontrol.NewUnlockConnectorResponse(remotecontrol.UnlockStatusUnknownConnector), nil
+	}
+
+	if conn.TransactionID() != 0 {
+		return remotecontrol.NewUnlockConnectorResponse(remotecontrol.UnlockStatusOngoingAuthorizedTransaction), nil
+	}
+
+	return remotecontrol.NewUnlockConnectorResponse(remotecontrol.UnlockStatusUnlocked), nil
+}
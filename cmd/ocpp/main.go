@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	ocpp16 "github.com/lorenzodonini/ocpp-go/ocpp1.6"
 	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
@@ -13,13 +16,29 @@ import (
 	"github.com/lorenzodonini/ocpp-go/ocpp1.6/reservation"
 	"github.com/lorenzodonini/ocpp-go/ocpp1.6/smartcharging"
 	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	ocpp2 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/authorization"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/availability"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/meter"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/remotecontrol"
+	smartcharging201 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1/smartcharging"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/transactions"
+	types201 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
 	"github.com/lorenzodonini/ocpp-go/ocppj"
 	"github.com/lorenzodonini/ocpp-go/ws"
+	"github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
 )
 
 var chargePointId = "cp0001"
 
+// supported protocol versions
+const (
+	protocolV16  = "1.6"
+	protocolV201 = "2.0.1"
+)
+
 // ocppCmd represents the base command when called without any subcommands
 var ocppCmd = &cobra.Command{
 	Use:  "ocpp",
@@ -29,6 +48,26 @@ var ocppCmd = &cobra.Command{
 
 func main() {
 	ocppCmd.Flags().String("uri", "ws://localhost:8887", "Central system uri")
+	ocppCmd.Flags().String("protocol", protocolV16, "OCPP version ("+protocolV16+", "+protocolV201+")")
+	ocppCmd.Flags().String("password", "", "Basic auth password (security profiles 1, 2)")
+	ocppCmd.Flags().String("ca-cert", "", "Central system CA certificate file (security profiles 2, 3)")
+	ocppCmd.Flags().String("cert", "", "Client certificate file (security profile 3)")
+	ocppCmd.Flags().String("key", "", "Client certificate key file (security profile 3)")
+	ocppCmd.Flags().Bool("insecure", false, "Skip central system certificate verification")
+	ocppCmd.Flags().Int("stations", 1, "Number of charge points")
+	ocppCmd.Flags().String("id-pattern", "cp%04d", "Charge point id pattern for multiple stations")
+	ocppCmd.Flags().Int("connectors", 1, "Number of connectors per charge point")
+	ocppCmd.Flags().Duration("meter-interval", 10*time.Second, "Initial MeterValueSampleInterval")
+	ocppCmd.Flags().String("config", "", "Configuration keys seed file (yaml or json)")
+	ocppCmd.Flags().String("state-dir", "~/.evcc/ocpp", "Directory for persisting configuration changes")
+	ocppCmd.Flags().Bool("plug", false, "Plug in EV after boot")
+	ocppCmd.Flags().String("scenario", "", "Run scenario file (yaml or json) headless and exit")
+	ocppCmd.Flags().Float64("capacity", 50, "EV battery capacity in kWh")
+	ocppCmd.Flags().Float64("soc", 20, "EV initial soc in %")
+	ocppCmd.Flags().Float64("max-current", 16, "EV max AC current per phase in A")
+	ocppCmd.Flags().Int("phases", 3, "EV onboard charger phases")
+	ocppCmd.Flags().Float64("taper-soc", 80, "EV soc above which charging current tapers off")
+	ocppCmd.Flags().StringSlice("quirks", nil, "Vendor quirk profiles ("+strings.Join(quirkProfileNames(), ", ")+")")
 
 	if err := ocppCmd.Execute(); err != nil {
 		fmt.Println(err)
@@ -38,76 +77,213 @@ func main() {
 
 func runOcpp(cmd *cobra.Command, args []string) {
 	url := cmd.Flags().Lookup("uri").Value.String()
+	protocol, _ := cmd.Flags().GetString("protocol")
+	count, _ := cmd.Flags().GetInt("stations")
+	idPattern, _ := cmd.Flags().GetString("id-pattern")
+	connectors, _ := cmd.Flags().GetInt("connectors")
+	meterInterval, _ := cmd.Flags().GetDuration("meter-interval")
+	configFile, _ := cmd.Flags().GetString("config")
+	stateDir, _ := cmd.Flags().GetString("state-dir")
+	plug, _ := cmd.Flags().GetBool("plug")
+	scenario, _ := cmd.Flags().GetString("scenario")
+
+	capacity, _ := cmd.Flags().GetFloat64("capacity")
+	soc, _ := cmd.Flags().GetFloat64("soc")
+	maxCurrent, _ := cmd.Flags().GetFloat64("max-current")
+	phases, _ := cmd.Flags().GetInt("phases")
+	taperSoc, _ := cmd.Flags().GetFloat64("taper-soc")
+	ev := NewEV(capacity, soc, maxCurrent, phases, taperSoc)
+
+	if protocol != protocolV16 && protocol != protocolV201 {
+		log.Fatalf("unsupported protocol: %s", protocol)
+	}
+
+	password, _ := cmd.Flags().GetString("password")
+	caFile, _ := cmd.Flags().GetString("ca-cert")
+	certFile, _ := cmd.Flags().GetString("cert")
+	keyFile, _ := cmd.Flags().GetString("key")
+	insecure, _ := cmd.Flags().GetBool("insecure")
+
+	security, err := NewSecurity(password, caFile, certFile, keyFile, insecure)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	quirkNames, _ := cmd.Flags().GetStringSlice("quirks")
+	quirks, err := ParseQuirks(quirkNames)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	keys := DefaultConfig(connectors, int(meterInterval.Seconds()))
+	if configFile != "" {
+		seed, err := LoadConfigSeed(configFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for key, val := range seed {
+			keys[key] = val
+		}
+	}
+
+	for key, value := range quirks.ConfigurationKeys {
+		keys[key] = ConfigKey{Value: value, Readonly: true}
+	}
+
+	if stateDir != "" {
+		if stateDir, err = homedir.Expand(stateDir); err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	if len(args) > 0 {
 		chargePointId = args[0]
 	}
 
+	ids, err := stationIDs(chargePointId, idPattern, count)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var stations []*Station
+	for _, id := range ids {
+		var stateFile string
+		if stateDir != "" {
+			stateFile = filepath.Join(stateDir, id+".yaml")
+		}
+
+		config, err := NewConfigStore(keys, stateFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		stations = append(stations, newStation(id, protocol, security, connectors, *ev, config, quirks))
+	}
+
+	// run scenario headless
+	if scenario != "" {
+		sc, err := LoadScenario(scenario)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if err := sc.Run(stations, url); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+
+		os.Exit(0)
+	}
+
+	// Connects to central system
+	for _, station := range stations {
+		if err := station.Connect(url); err != nil {
+			log.Fatal(err)
+		}
+
+		station.Boot()
+
+		if plug {
+			for _, conn := range station.connectors {
+				conn.Plug()
+			}
+		}
+	}
+
+	console(stations, os.Stdin)
+
+	select {}
+}
+
+// stationIDs returns the charge point ids of the fleet. A single station uses id, multiple stations are numbered from 1 using pattern.
+func stationIDs(id, pattern string, count int) ([]string, error) {
+	if count < 1 {
+		return nil, fmt.Errorf("invalid number of stations: %d", count)
+	}
+
+	if count == 1 {
+		return []string{id}, nil
+	}
+
+	res := make([]string, 0, count)
+	seen := make(map[string]bool)
+
+	for i := 1; i <= count; i++ {
+		id := fmt.Sprintf(pattern, i)
+		if seen[id] || strings.Contains(id, "%!") {
+			return nil, fmt.Errorf("id pattern %q does not create unique ids", pattern)
+		}
+
+		seen[id] = true
+		res = append(res, id)
+	}
+
+	return res, nil
+}
+
+// newStation creates a simulated station with its own websocket connection
+func newStation(id, protocol string, security Security, connectors int, ev EV, config *ConfigStore, quirks Quirks) *Station {
 	// create websocket client
-	client := ws.NewClient()
+	client := security.Client(id)
+
+	if ping := time.Duration(config.Int(KeyWebSocketPingInterval)) * time.Second; ping > 0 {
+		timeouts := ws.NewClientTimeoutConfig()
+		timeouts.PingPeriod = ping
+		timeouts.PongWait = ping * 10 / 9
+		client.SetTimeoutConfig(timeouts)
+	}
+
+	if protocol == protocolV201 {
+		return newStation201(id, client, connectors, ev, config, quirks)
+	}
+
 	client.SetRequestedSubProtocol(types.V16Subprotocol)
 
 	// create chargepoint with connection tracking
-	endpoint := ocppj.NewClient(chargePointId, client, nil, nil, core.Profile, localauth.Profile, firmware.Profile, reservation.Profile, remotetrigger.Profile, smartcharging.Profile)
-	endpoint.SetOnReconnectedHandler(func() {
-		fmt.Println("reconnect")
-	})
-	endpoint.SetOnDisconnectedHandler(func(err error) {
-		fmt.Println("disconnect")
-	})
-	chargePoint := ocpp16.NewChargePoint(chargePointId, endpoint, client)
+	endpoint := ocppj.NewClient(id, client, nil, nil, core.Profile, localauth.Profile, firmware.Profile, reservation.Profile, remotetrigger.Profile, smartcharging.Profile)
+	chargePoint := ocpp16.NewChargePoint(id, endpoint, client)
+
+	station := NewStation(id, chargePoint, client, connectors, ev, config, quirks)
+	handleReconnect(station, endpoint)
 
 	// set a handler for all callback functions
-	handler := &ChargePointHandler{triggerC: make(chan remotetrigger.MessageTrigger, 1)}
+	handler := &ChargePointHandler{station: station}
 	chargePoint.SetCoreHandler(handler)
 	chargePoint.SetRemoteTriggerHandler(handler)
+	chargePoint.SetReservationHandler(handler)
+	chargePoint.SetSmartChargingHandler(handler)
 
-	go func() {
-		for err := range chargePoint.Errors() {
-			fmt.Println(err)
-		}
-	}()
-
-	go func() {
-		for msg := range handler.triggerC {
-			switch msg {
-			case core.BootNotificationFeatureName:
-				if res, err := chargePoint.BootNotification("demo", "evcc"); err != nil {
-					log.Println("BootNotification:", err)
-				} else {
-					log.Println("BootNotification:", res)
-				}
-
-			case core.StatusNotificationFeatureName:
-				if res, err := chargePoint.StatusNotification(1, core.NoError, core.ChargePointStatusAvailable); err != nil {
-					log.Println("StatusNotification:", err)
-				} else {
-					log.Println("StatusNotification:", res)
-				}
-
-			case core.MeterValuesFeatureName:
-				if res, err := chargePoint.MeterValues(1, []types.MeterValue{
-					{SampledValue: []types.SampledValue{
-						{Measurand: types.MeasurandPowerActiveImport, Value: "1000"},
-					}},
-				}); err != nil {
-					log.Println("MeterValues:", err)
-				} else {
-					log.Println("MeterValues:", res)
-				}
-
-			default:
-				fmt.Println(msg)
-			}
-		}
-	}()
+	return station
+}
 
-	// Connects to central system
-	if err := chargePoint.Start(url); err != nil {
-		log.Fatal(err)
-	}
+// newStation201 creates a simulated OCPP 2.0.1 charging station
+func newStation201(id string, client *ws.Client, connectors int, ev EV, config *ConfigStore, quirks Quirks) *Station {
+	client.SetRequestedSubProtocol(types201.V201Subprotocol)
 
-	log.Printf("connected to central system at %v", url)
+	endpoint := ocppj.NewClient(id, client, nil, nil, authorization.Profile, availability.Profile, meter.Profile, provisioning.Profile, remotecontrol.Profile, smartcharging201.Profile, transactions.Profile)
+	chargingStation := newChargingStation201(ocpp2.NewChargingStation(id, endpoint, client))
 
-	select {}
+	station := NewStation(id, chargingStation, client, connectors, ev, config, quirks)
+	handleReconnect(station, endpoint)
+
+	handler := &ChargingStationHandler{station: station, cp: chargingStation}
+	chargingStation.cs.SetProvisioningHandler(handler)
+	chargingStation.cs.SetAvailabilityHandler(handler)
+	chargingStation.cs.SetTransactionsHandler(handler)
+	chargingStation.cs.SetRemoteControlHandler(handler)
+	chargingStation.cs.SetSmartChargingHandler(handler)
+
+	return station
+}
+
+// handleReconnect boots the station after the connection has been re-established
+func handleReconnect(station *Station, endpoint *ocppj.Client) {
+	endpoint.SetOnReconnectedHandler(func() {
+		station.log.Println("reconnect")
+		go station.Boot()
+	})
+	endpoint.SetOnDisconnectedHandler(func(err error) {
+		station.log.Println("disconnect")
+	})
 }
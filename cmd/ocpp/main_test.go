@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStationIDs(t *testing.T) {
+	ids, err := stationIDs("cp0001", "cp%04d", 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cp0001"}, ids)
+
+	ids, err = stationIDs("cp0001", "site-%d", 3)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"site-1", "site-2", "site-3"}, ids)
+
+	for _, pattern := range []string{"cp", "cp%d%d", "cp%s"} {
+		_, err = stationIDs("cp0001", pattern, 2)
+		assert.Error(t, err, pattern)
+	}
+
+	_, err = stationIDs("cp0001", "cp%04d", 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Quirks reproduce non-conforming vendor behaviour
+type Quirks struct {
+	RejectGetConfiguration bool              // GetConfiguration is answered with a CallError
+	NoTimestamps           bool              // StatusNotification is sent without timestamp
+	OutOfOrderMeterValues  bool              // previous MeterValues are resent after the current ones
+	NoPhaseLabels          bool              // Current.Import samples are sent without phase
+	StaleTransactionId     bool              // MeterValues and StopTransaction refer to the previous transaction
+	ConfigurationKeys      map[string]string // additional vendor configuration keys
+}
+
+// quirkProfiles are the selectable quirk profiles
+var quirkProfiles = map[string]Quirks{
+	"reject-getconfiguration":  {RejectGetConfiguration: true},
+	"no-timestamps":            {NoTimestamps: true},
+	"out-of-order-metervalues": {OutOfOrderMeterValues: true},
+	"no-phase-labels":          {NoPhaseLabels: true},
+	"stale-transaction-id":     {StaleTransactionId: true},
+	"alfen": {
+		ConfigurationKeys: map[string]string{"PlugAndChargeIdentifier": "alfen"},
+	},
+}
+
+// quirkProfileNames returns the sorted list of quirk profiles
+func quirkProfileNames() []string {
+	res := make([]string, 0, len(quirkProfiles))
+	for name := range quirkProfiles {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// ParseQuirks combines the named quirk profiles
+func ParseQuirks(names []string) (Quirks, error) {
+	var res Quirks
+
+	for _, name := range names {
+		q, ok := quirkProfiles[strings.ToLower(name)]
+		if !ok {
+			return res, fmt.Errorf("unknown quirk profile: %s (available: %s)", name, strings.Join(quirkProfileNames(), ", "))
+		}
+
+		res.RejectGetConfiguration = res.RejectGetConfiguration || q.RejectGetConfiguration
+		res.NoTimestamps = res.NoTimestamps || q.NoTimestamps
+		res.OutOfOrderMeterValues = res.OutOfOrderMeterValues || q.OutOfOrderMeterValues
+		res.NoPhaseLabels = res.NoPhaseLabels || q.NoPhaseLabels
+		res.StaleTransactionId = res.StaleTransactionId || q.StaleTransactionId
+
+		for k, v := range q.ConfigurationKeys {
+			if res.ConfigurationKeys == nil {
+				res.ConfigurationKeys = make(map[string]string)
+			}
+			res.ConfigurationKeys[k] = v
+		}
+	}
+
+	return res, nil
+}
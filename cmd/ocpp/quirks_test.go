@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseQuirks(t *testing.T) {
+	q, err := ParseQuirks([]string{"No-Timestamps", "alfen"})
+	require.NoError(t, err)
+	assert.True(t, q.NoTimestamps)
+	assert.False(t, q.StaleTransactionId)
+	assert.Equal(t, map[string]string{"PlugAndChargeIdentifier": "alfen"}, q.ConfigurationKeys)
+
+	_, err = ParseQuirks([]string{"foo"})
+	assert.Error(t, err)
+}
+
+func TestQuirkStaleTransactionId(t *testing.T) {
+	cp := new(testChargePoint)
+	s := newTestStation(t, cp, 1, Quirks{StaleTransactionId: true})
+	conn := s.Connector(1)
+
+	conn.Plug()
+
+	// first transaction has no previous transaction id
+	for txnId := 1; txnId <= 2; txnId++ {
+		require.True(t, conn.Authorize("tag", nil))
+		waitStatus(t, conn, core.ChargePointStatusCharging)
+		require.Equal(t, txnId, conn.TransactionID())
+
+		require.True(t, conn.Stop(core.ReasonLocal))
+		waitStatus(t, conn, core.ChargePointStatusFinishing)
+	}
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	// second transaction reports the first transaction's id
+	assert.Equal(t, []int{1, 1}, cp.stopped)
+	assert.Equal(t, []int{1, 1}, cp.metered)
+}
+
+func TestQuirkNoPhaseLabels(t *testing.T) {
+	s := newTestStation(t, new(testChargePoint), 1, Quirks{NoPhaseLabels: true})
+
+	var currents int
+	for _, sample := range s.Connector(1).sampledValues(types.ReadingContextSamplePeriodic) {
+		if sample.Measurand == types.MeasurandCurrentImport {
+			currents++
+			assert.Empty(t, sample.Phase)
+		}
+	}
+
+	assert.Equal(t, 3, currents)
+}
+
+func TestQuirkOutOfOrderMeterValues(t *testing.T) {
+	cp := new(testChargePoint)
+	s := newTestStation(t, cp, 1, Quirks{OutOfOrderMeterValues: true})
+	conn := s.Connector(1)
+
+	// previous meter value is resent after the current one
+	conn.sendMeterValues(types.ReadingContextSamplePeriodic)
+	time.Sleep(10 * time.Millisecond)
+	conn.sendMeterValues(types.ReadingContextSamplePeriodic)
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	require.Len(t, cp.values, 3)
+	assert.True(t, cp.values[2].Timestamp.Before(cp.values[1].Timestamp.Time))
+	assert.Equal(t, cp.values[0].Timestamp, cp.values[2].Timestamp)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/evcc-io/evcc/util/ocpprecord"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/lorenzodonini/ocpp-go/ws"
+	"github.com/spf13/cobra"
+)
+
+// replay targets
+const (
+	targetCentral = "central" // replay the station's frames against a central system
+	targetStation = "station" // replay the central system's frames against a station
+)
+
+// replayCmd replays a central system recording
+var replayCmd = &cobra.Command{
+	Use:   "replay <recording>",
+	Short: "Replay a recording of the evcc central system against a central system or station",
+	Args:  cobra.ExactArgs(1),
+	Run:   runReplay,
+}
+
+func init() {
+	replayCmd.Flags().String("target", targetCentral, "Replay against the central system at uri ("+targetCentral+") or stations connecting on port ("+targetStation+")")
+	replayCmd.Flags().String("uri", "ws://localhost:8887", "Central system uri")
+	replayCmd.Flags().Int("port", 8887, "Listen port for stations")
+	replayCmd.Flags().String("station", "", "Station id to replay (default first recorded station)")
+	replayCmd.Flags().Float64("speed", 1, "Replay speed factor, 0 sends calls without delay")
+	replayCmd.Flags().Duration("wait", 5*time.Second, "Time to keep answering calls after the last replayed call")
+
+	ocppCmd.AddCommand(replayCmd)
+}
+
+func runReplay(cmd *cobra.Command, args []string) {
+	target, _ := cmd.Flags().GetString("target")
+	uri, _ := cmd.Flags().GetString("uri")
+	port, _ := cmd.Flags().GetInt("port")
+	station, _ := cmd.Flags().GetString("station")
+	speed, _ := cmd.Flags().GetFloat64("speed")
+	wait, _ := cmd.Flags().GetDuration("wait")
+
+	frames, err := ocpprecord.Load(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if station == "" {
+		if stations := ocpprecord.Stations(frames); len(stations) > 0 {
+			station = stations[0]
+		}
+	}
+
+	frames = ocpprecord.Filter(frames, station)
+	if len(frames) == 0 {
+		log.Fatalf("no frames recorded for station: %s", station)
+	}
+
+	protocol := frames[0].Protocol
+	if protocol == "" {
+		protocol = types.V16Subprotocol
+	}
+
+	logger := log.New(os.Stdout, station+" ", log.LstdFlags)
+	logger.Printf("replaying %d frames (%s) against %s", len(frames), protocol, target)
+
+	switch target {
+	case targetCentral:
+		err = replayCentral(logger, frames, station, protocol, uri, speed)
+	case targetStation:
+		err = replayStation(logger, frames, station, protocol, port, speed)
+	default:
+		err = fmt.Errorf("invalid target: %s", target)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// answer the peer's remaining calls
+	time.Sleep(wait)
+
+	logger.Println("replay finished")
+}
+
+// replayCentral connects to the central system as the recorded station
+func replayCentral(logger *log.Logger, frames []ocpprecord.Frame, station, protocol, uri string, speed float64) error {
+	client := ws.NewClient()
+	client.SetRequestedSubProtocol(protocol)
+
+	replay, err := ocpprecord.NewReplay(logger, frames, ocpprecord.In, speed, client.Write)
+	if err != nil {
+		return err
+	}
+
+	client.SetMessageHandler(replay.Handle)
+
+	if err := client.Start(uri + "/" + station); err != nil {
+		return err
+	}
+
+	return replay.Run(context.Background())
+}
+
+// replayStation waits for the recorded station to connect and replays the central system
+func replayStation(logger *log.Logger, frames []ocpprecord.Frame, station, protocol string, port int, speed float64) error {
+	server := ws.NewServer()
+	server.AddSupportedSubprotocol(protocol)
+
+	replay, err := ocpprecord.NewReplay(logger, frames, ocpprecord.Out, speed, func(data []byte) error {
+		return server.Write(station, data)
+	})
+	if err != nil {
+		return err
+	}
+
+	connected := make(chan struct{})
+
+	server.SetNewClientHandler(func(ws ws.Channel) {
+		if ws.ID() != station {
+			logger.Printf("ignoring station: %s", ws.ID())
+			return
+		}
+
+		logger.Println("station connected")
+
+		select {
+		case <-connected:
+		default:
+			close(connected)
+		}
+	})
+
+	server.SetMessageHandler(func(ws ws.Channel, data []byte) error {
+		if ws.ID() != station {
+			return errors.New("unexpected station")
+		}
+
+		return replay.Handle(data)
+	})
+
+	go server.Start(port, "/{ws}")
+
+	logger.Printf("waiting for station to connect on port %d", port)
+	<-connected
+
+	return replay.Run(context.Background())
+}
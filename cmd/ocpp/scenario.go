@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/evcc-io/evcc/util/ocppscript"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+)
+
+// Step is a single scenario timeline entry. Actions are connect, disconnect, reconnect, boot, heartbeat,
+// wait or any console command. Steps apply to all stations unless a station is given.
+type Step struct {
+	ocppscript.Step `yaml:",inline"`
+	ErrorCode       string `yaml:"errorcode"`
+}
+
+// Scenario is a timeline of actions and expectations
+type Scenario struct {
+	Steps []Step
+}
+
+// LoadScenario reads a scenario from a yaml or json file
+func LoadScenario(file string) (*Scenario, error) {
+	steps, err := ocppscript.Load[Step](file)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Scenario{Steps: steps}, nil
+}
+
+// Run executes the scenario against the stations and returns an error if any action or expectation failed.
+// A failed action aborts the scenario after pending expectations have completed.
+func (sc *Scenario) Run(stations []*Station, url string) error {
+	for i, step := range sc.Steps {
+		if step.Station != "" && stationByID(stations, step.Station) == nil {
+			return fmt.Errorf("step %d: unknown station: %s", i+1, step.Station)
+		}
+	}
+
+	err := ocppscript.Timeline[Step]{
+		Steps: sc.Steps,
+		Abort: true,
+		Execute: func(step Step) error {
+			return step.execute(step.targets(stations), url)
+		},
+		Check: func(step Step, from time.Time) error {
+			for _, s := range step.targets(stations) {
+				if err := step.check(s, from); err != nil {
+					return fmt.Errorf("%s: %w", s.id, err)
+				}
+			}
+			return nil
+		},
+	}.Run()
+
+	for _, s := range stations {
+		s.Disconnect()
+	}
+
+	return err
+}
+
+// targets returns the step's station or all stations
+func (step Step) targets(stations []*Station) []*Station {
+	if step.Station != "" {
+		return []*Station{stationByID(stations, step.Station)}
+	}
+	return stations
+}
+
+// execute runs the step's action on all target stations in parallel
+func (step Step) execute(targets []*Station, url string) error {
+	errC := make(chan error, len(targets))
+	for _, s := range targets {
+		go func(s *Station) {
+			errC <- step.executeStation(s, url)
+		}(s)
+	}
+
+	var err error
+	for range targets {
+		if e := <-errC; e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
+
+// executeStation runs the step's action on the station
+func (step Step) executeStation(s *Station, url string) error {
+	switch step.Action {
+	case "connect":
+		return s.Connect(url)
+
+	case "disconnect":
+		s.Disconnect()
+
+	case "reconnect":
+		s.Disconnect()
+		if err := s.Connect(url); err != nil {
+			return err
+		}
+		s.Boot()
+
+	case "boot":
+		s.Boot()
+
+	case "heartbeat":
+		s.heartbeat()
+
+	default:
+		args := []string{step.Action}
+		switch {
+		case step.IdTag != "":
+			args = append(args, step.IdTag)
+		case step.ErrorCode != "":
+			args = append(args, step.ErrorCode)
+		}
+		if step.Connector > 0 {
+			args = append(args, strconv.Itoa(step.Connector))
+		}
+
+		return execute(s, args)
+	}
+
+	return nil
+}
+
+// check returns nil if the step's expectation is met by the station
+func (step Step) check(s *Station, from time.Time) error {
+	if received := s.received.Since(from); !ocppscript.Ordered(received, step.Expect) {
+		return fmt.Errorf("central system did not send %v, sent %v", step.Expect, received)
+	}
+
+	if step.Status == "" {
+		return nil
+	}
+
+	id := step.ConnectorID()
+
+	conn := s.Connector(id)
+	if conn == nil {
+		return fmt.Errorf("unknown connector: %d", id)
+	}
+
+	if status := conn.Status(); status != core.ChargePointStatus(step.Status) {
+		return fmt.Errorf("connector %d did not reach status %s, is %s", id, step.Status, status)
+	}
+
+	return nil
+}
+
+// messageLog records the messages received from the central system
+type messageLog struct {
+	mu       sync.Mutex
+	received []receivedMessage
+}
+
+type receivedMessage struct {
+	feature string
+	time    time.Time
+}
+
+func newMessageLog() *messageLog {
+	return new(messageLog)
+}
+
+// Add records a received message
+func (l *messageLog) Add(feature string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.received = append(l.received, receivedMessage{feature, time.Now()})
+}
+
+// Since returns the messages received at or after given time in order
+func (l *messageLog) Since(from time.Time) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var res []string
+	for _, msg := range l.received {
+		if !msg.time.Before(from) {
+			res = append(res, msg.feature)
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeScenario(t *testing.T, content string) string {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), "scenario.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(content), 0o644))
+
+	return file
+}
+
+func TestLoadScenario(t *testing.T) {
+	sc, err := LoadScenario("scenarios/remotestart.yaml")
+	require.NoError(t, err)
+	require.NotEmpty(t, sc.Steps)
+	assert.Equal(t, "connect", sc.Steps[0].Action)
+
+	// sorted by time, simultaneous steps keep their order
+	sc, err = LoadScenario(writeScenario(t, `
+steps:
+  - at: 2s
+    action: unplug
+  - action: plug
+  - action: authorize
+    idtag: tag
+`))
+	require.NoError(t, err)
+	require.Len(t, sc.Steps, 3)
+	assert.Equal(t, []string{"plug", "authorize", "unplug"}, []string{sc.Steps[0].Action, sc.Steps[1].Action, sc.Steps[2].Action})
+
+	_, err = LoadScenario(writeScenario(t, `
+steps:
+  - connector: 1
+`))
+	assert.Error(t, err)
+}
+
+func TestScenarioRun(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		scenario string
+		err      string
+	}{
+		{"pass", `
+steps:
+  - action: plug
+  - action: authorize
+    idtag: tag
+  - status: Charging
+    within: 1s
+  - at: 500ms
+    action: stop
+  - at: 500ms
+    status: Finishing
+    within: 1s
+`, ""},
+		{"timeout", `
+steps:
+  - expect: SetChargingProfile
+    within: 200ms
+`, "1 of 1 steps failed"},
+		{"fail", `
+steps:
+  - status: Charging
+    within: 200ms
+  - action: foo
+  - at: 1s
+    action: plug
+`, "step 2: unknown command: foo"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sc, err := LoadScenario(writeScenario(t, tc.scenario))
+			require.NoError(t, err)
+
+			s := newTestStation(t, new(testChargePoint), 1, Quirks{})
+
+			err = sc.Run([]*Station{s}, "")
+			if tc.err == "" {
+				require.NoError(t, err)
+				return
+			}
+
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.err)
+
+			// pending expectations are evaluated after a failed action
+			if tc.name == "fail" {
+				assert.Contains(t, err.Error(), "2 of 2 steps failed")
+				assert.Equal(t, "Available", string(s.Connector(1).Status()))
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/lorenzodonini/ocpp-go/ws"
+)
+
+// Security configures the station's OCPP security profile:
+// basic auth (profile 1), basic auth over TLS (profile 2) or TLS client certificates (profile 3).
+type Security struct {
+	Password  string
+	TLSConfig *tls.Config
+}
+
+// NewSecurity loads the CA and client certificate files. TLS is enabled if any of them is given.
+func NewSecurity(password, caFile, certFile, keyFile string, insecure bool) (Security, error) {
+	res := Security{Password: password}
+
+	if caFile == "" && certFile == "" && !insecure {
+		return res, nil
+	}
+
+	res.TLSConfig = &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: insecure,
+	}
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return res, err
+		}
+
+		res.TLSConfig.RootCAs = x509.NewCertPool()
+		if !res.TLSConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return res, fmt.Errorf("no certificates found: %s", caFile)
+		}
+	}
+
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return res, errors.New("client certificate requires cert and key")
+		}
+
+		certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return res, err
+		}
+
+		res.TLSConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	return res, nil
+}
+
+// Client creates the station's websocket client
+func (s Security) Client(id string) *ws.Client {
+	client := ws.NewClient()
+	if s.TLSConfig != nil {
+		client = ws.NewTLSClient(s.TLSConfig)
+	}
+
+	// the station id is the basic auth user
+	if s.Password != "" {
+		client.SetBasicAuth(id, s.Password)
+	}
+
+	return client
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"time"
+
+	"github.com/evcc-io/evcc/util/chargingprofile"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/smartcharging"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+)
+
+// SetChargingProfile installs the profile for the connector or, if zero, the station.
+// Returns false if the profile is rejected.
+func (s *Station) SetChargingProfile(connector int, profile *types.ChargingProfile) bool {
+	if connector > 0 {
+		conn := s.Connector(connector)
+		if conn == nil {
+			return false
+		}
+
+		// tx profiles require an active transaction
+		if profile.ChargingProfilePurpose == types.ChargingProfilePurposeTxProfile {
+			txnId := conn.TransactionID()
+			if txnId == 0 || profile.TransactionId != 0 && profile.TransactionId != txnId {
+				return false
+			}
+		}
+	}
+
+	if err := s.profiles.Set(connector, profile); err != nil {
+		s.log.Println("SetChargingProfile:", err)
+		return false
+	}
+
+	s.applyLimit()
+
+	return true
+}
+
+// CompositeSchedule returns the connector's or, if zero, the station's schedule for the given duration or nil if it does not exist
+func (s *Station) CompositeSchedule(connector int, duration int, unit types.ChargingRateUnitType) *types.ChargingSchedule {
+	var conn *Connector
+	if connector > 0 {
+		if conn = s.Connector(connector); conn == nil {
+			return nil
+		}
+	}
+
+	max := float64(defaultCurrent)
+	if conn == nil {
+		max *= float64(len(s.connectors))
+	}
+
+	var txn *chargingprofile.Transaction
+	if conn != nil {
+		txn = conn.Transaction()
+	}
+
+	return s.profiles.CompositeSchedule(connector, time.Now(), duration, unit, max, txn)
+}
+
+func (handler *ChargePointHandler) OnSetChargingProfile(request *smartcharging.SetChargingProfileRequest) (confirmation *smartcharging.SetChargingProfileConfirmation, err error) {
+	handler.trace(request)
+
+	status := smartcharging.ChargingProfileStatusRejected
+	if handler.station.SetChargingProfile(request.ConnectorId, request.ChargingProfile) {
+		status = smartcharging.ChargingProfileStatusAccepted
+	}
+
+	return smartcharging.NewSetChargingProfileConfirmation(status), nil
+}
+
+func (handler *ChargePointHandler) OnClearChargingProfile(request *smartcharging.ClearChargingProfileRequest) (confirmation *smartcharging.ClearChargingProfileConfirmation, err error) {
+	handler.trace(request)
+
+	status := smartcharging.ClearChargingProfileStatusUnknown
+	if handler.station.profiles.Clear(request) {
+		status = smartcharging.ClearChargingProfileStatusAccepted
+		handler.station.applyLimit()
+	}
+
+	return smartcharging.NewClearChargingProfileConfirmation(status), nil
+}
+
+func (handler *ChargePointHandler) OnGetCompositeSchedule(request *smartcharging.GetCompositeScheduleRequest) (confirmation *smartcharging.GetCompositeScheduleConfirmation, err error) {
+	handler.trace(request)
+
+	res := smartcharging.NewGetCompositeScheduleConfirmation(smartcharging.GetCompositeScheduleStatusRejected)
+
+	schedule := handler.station.CompositeSchedule(request.ConnectorId, request.Duration, request.ChargingRateUnit)
+	if schedule == nil {
+		return res, nil
+	}
+
+	res.Status = smartcharging.GetCompositeScheduleStatusAccepted
+	res.ScheduleStart = schedule.StartSchedule
+	res.ChargingSchedule = schedule
+	if request.ConnectorId > 0 {
+		res.ConnectorId = &request.ConnectorId
+	}
+
+	return res, nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"github.com/evcc-io/evcc/util/ocpp201"
+	smartcharging16 "github.com/lorenzodonini/ocpp-go/ocpp1.6/smartcharging"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/smartcharging"
+	types201 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+	"github.com/samber/lo"
+)
+
+func (handler *ChargingStationHandler) OnSetChargingProfile(request *smartcharging.SetChargingProfileRequest) (response *smartcharging.SetChargingProfileResponse, err error) {
+	handler.trace(request)
+
+	status := smartcharging.ChargingProfileStatusRejected
+
+	profile, err := ocpp201.ChargingProfile16(request.ChargingProfile)
+	if err != nil {
+		handler.station.log.Println("SetChargingProfile:", err)
+	} else if handler.station.SetChargingProfile(request.EvseID, profile) {
+		status = smartcharging.ChargingProfileStatusAccepted
+	}
+
+	return smartcharging.NewSetChargingProfileResponse(status), nil
+}
+
+func (handler *ChargingStationHandler) OnClearChargingProfile(request *smartcharging.ClearChargingProfileRequest) (response *smartcharging.ClearChargingProfileResponse, err error) {
+	handler.trace(request)
+
+	clear := &smartcharging16.ClearChargingProfileRequest{
+		Id: request.ChargingProfileID,
+	}
+
+	if criteria := request.ChargingProfileCriteria; criteria != nil {
+		clear.ConnectorId = criteria.EvseID
+		clear.StackLevel = criteria.StackLevel
+
+		if criteria.ChargingProfilePurpose == types201.ChargingProfilePurposeChargingStationMaxProfile {
+			clear.ChargingProfilePurpose = types.ChargingProfilePurposeChargePointMaxProfile
+		} else {
+			clear.ChargingProfilePurpose = types.ChargingProfilePurposeType(criteria.ChargingProfilePurpose)
+		}
+	}
+
+	status := smartcharging.ClearChargingProfileStatusUnknown
+	if handler.station.profiles.Clear(clear) {
+		status = smartcharging.ClearChargingProfileStatusAccepted
+		handler.station.applyLimit()
+	}
+
+	return smartcharging.NewClearChargingProfileResponse(status), nil
+}
+
+// OnGetChargingProfiles reports the matching profiles asynchronously per EVSE
+func (handler *ChargingStationHandler) OnGetChargingProfiles(request *smartcharging.GetChargingProfilesRequest) (response *smartcharging.GetChargingProfilesResponse, err error) {
+	handler.trace(request)
+
+	evses := lo.Range(len(handler.station.connectors) + 1)
+	if request.EvseID != nil {
+		evses = []int{*request.EvseID}
+	}
+
+	criterion := request.ChargingProfile
+	reports := make(map[int][]types201.ChargingProfile)
+
+	for _, evse := range evses {
+		for _, p := range handler.station.profiles.Profiles(evse) {
+			profile := ocpp201.ChargingProfile(p)
+
+			if criterion.ChargingProfilePurpose != "" && criterion.ChargingProfilePurpose != profile.ChargingProfilePurpose ||
+				criterion.StackLevel != nil && *criterion.StackLevel != profile.StackLevel ||
+				len(criterion.ChargingProfileID) > 0 && !lo.Contains(criterion.ChargingProfileID, profile.ID) {
+				continue
+			}
+
+			reports[evse] = append(reports[evse], *profile)
+		}
+	}
+
+	if len(reports) == 0 {
+		return smartcharging.NewGetChargingProfilesResponse(smartcharging.GetChargingProfileStatusNoProfiles), nil
+	}
+
+	go func() {
+		sent := 0
+		for _, evse := range evses {
+			profiles, ok := reports[evse]
+			if !ok {
+				continue
+			}
+
+			sent++
+			if _, err := handler.cp.cs.ReportChargingProfiles(request.RequestID, types201.ChargingLimitSourceCSO, evse, profiles, func(report *smartcharging.ReportChargingProfilesRequest) {
+				report.Tbc = sent < len(reports)
+			}); err != nil {
+				handler.station.log.Println("ReportChargingProfiles:", err)
+			}
+		}
+	}()
+
+	return smartcharging.NewGetChargingProfilesResponse(smartcharging.GetChargingProfileStatusAccepted), nil
+}
+
+func (handler *ChargingStationHandler) OnGetCompositeSchedule(request *smartcharging.GetCompositeScheduleRequest) (response *smartcharging.GetCompositeScheduleResponse, err error) {
+	handler.trace(request)
+
+	res := smartcharging.NewGetCompositeScheduleResponse(smartcharging.GetCompositeScheduleStatusRejected, request.EvseID)
+
+	schedule := handler.station.CompositeSchedule(request.EvseID, request.Duration, types.ChargingRateUnitType(request.ChargingRateUnit))
+	if schedule == nil {
+		return res, nil
+	}
+
+	composite := ocpp201.ChargingSchedule(1, schedule)
+
+	res.Status = smartcharging.GetCompositeScheduleStatusAccepted
+	res.Schedule = &smartcharging.CompositeSchedule{
+		StartDateTime:    composite.StartSchedule,
+		ChargingSchedule: composite,
+	}
+
+	return res, nil
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/evcc-io/evcc/util/chargingprofile"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/remotetrigger"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/lorenzodonini/ocpp-go/ws"
+)
+
+// resetDelay is the time the simulated charge point needs to reboot
+const resetDelay = 3 * time.Second
+
+// Station simulates a charge point with one or more connectors
+type Station struct {
+	mu        sync.Mutex
+	connected bool
+
+	id         string
+	log        *log.Logger
+	cp         ChargePoint
+	quirks     Quirks
+	config     *ConfigStore
+	client     ws.WsClient
+	received   *messageLog
+	connectors []*Connector
+	profiles   *chargingprofile.Store
+	triggerC   chan *remotetrigger.TriggerMessageRequest
+	heartbeatC chan time.Duration
+}
+
+// NewStation creates a station with given number of connectors, each with a copy of the given EV
+func NewStation(id string, cp ChargePoint, client ws.WsClient, connectors int, ev EV, config *ConfigStore, quirks Quirks) *Station {
+	s := &Station{
+		id:         id,
+		log:        log.New(os.Stderr, id+" ", log.LstdFlags),
+		cp:         cp,
+		quirks:     quirks,
+		config:     config,
+		client:     client,
+		profiles:   chargingprofile.NewStore(),
+		received:   newMessageLog(),
+		triggerC:   make(chan *remotetrigger.TriggerMessageRequest, 1),
+		heartbeatC: make(chan time.Duration, 1),
+	}
+
+	for id := 1; id <= connectors; id++ {
+		ev := ev
+		s.connectors = append(s.connectors, NewConnector(cp, s.log, id, &ev, s.profiles, config, quirks))
+	}
+
+	go s.run()
+
+	s.setHeartbeat(time.Duration(config.Int(KeyHeartbeatInterval)) * time.Second)
+
+	return s
+}
+
+// Connect opens the websocket connection to the central system
+func (s *Station) Connect(url string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.connected {
+		return nil
+	}
+
+	if err := s.cp.Start(url); err != nil {
+		return err
+	}
+
+	s.connected = true
+
+	go func(errC <-chan error) {
+		for err := range errC {
+			s.log.Println(err)
+		}
+	}(s.cp.Errors())
+
+	s.log.Printf("connected to central system at %v", url)
+
+	return nil
+}
+
+// Disconnect closes the websocket connection to the central system
+func (s *Station) Disconnect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.connected {
+		return
+	}
+
+	// stopping the charge point blocks unless the websocket is connected
+	if s.client.IsConnected() {
+		s.cp.Stop()
+	} else {
+		s.client.Stop()
+	}
+
+	s.connected = false
+}
+
+// Connector returns the connector with given id or nil if it does not exist
+func (s *Station) Connector(id int) *Connector {
+	if id < 1 || id > len(s.connectors) {
+		return nil
+	}
+	return s.connectors[id-1]
+}
+
+// ConnectorByTransaction returns the connector running the given transaction or nil
+func (s *Station) ConnectorByTransaction(txnId int) *Connector {
+	for _, conn := range s.connectors {
+		if conn.TransactionID() == txnId {
+			return conn
+		}
+	}
+	return nil
+}
+
+// ChangeAvailability changes the operative state of the connector or, if zero, of all connectors.
+// Making connectors inoperative is scheduled while transactions are active and applied once they stop.
+func (s *Station) ChangeAvailability(connector int, available bool) core.AvailabilityStatus {
+	connectors := s.connectors
+	if connector > 0 {
+		conn := s.Connector(connector)
+		if conn == nil {
+			return core.AvailabilityStatusRejected
+		}
+		connectors = []*Connector{conn}
+	}
+
+	status := core.AvailabilityStatusAccepted
+	previous := make([]bool, 0, len(connectors))
+
+	for _, conn := range connectors {
+		// reject before applying anything
+		if conn.busy() {
+			return core.AvailabilityStatusRejected
+		}
+
+		if !available && conn.TransactionID() != 0 {
+			status = core.AvailabilityStatusScheduled
+		}
+
+		previous = append(previous, conn.available())
+	}
+
+	// connectors with active transactions apply the change once the transaction stops
+	for i, conn := range connectors {
+		if !conn.SetAvailable(available) {
+			// queue filled up meanwhile, restore connectors already changed
+			for j, conn := range connectors[:i] {
+				conn.SetAvailable(previous[j])
+			}
+
+			return core.AvailabilityStatusRejected
+		}
+	}
+
+	return status
+}
+
+// Trigger queues the message requested by the central system. Returns false if the trigger queue is full.
+func (s *Station) Trigger(req *remotetrigger.TriggerMessageRequest) bool {
+	select {
+	case s.triggerC <- req:
+		return true
+	default:
+		return false
+	}
+}
+
+// RemoteStartConnector returns the given or the first free connector for starting a transaction remotely for idTag.
+// Returns nil if the request must be rejected.
+func (s *Station) RemoteStartConnector(connector *int, idTag string) *Connector {
+	var conn *Connector
+	if connector != nil {
+		conn = s.Connector(*connector)
+	} else {
+		// pick first connector able to start a transaction
+		for _, c := range s.connectors {
+			if c.Free(idTag) {
+				conn = c
+				break
+			}
+		}
+	}
+
+	if conn == nil || !conn.Free(idTag) {
+		return nil
+	}
+
+	return conn
+}
+
+// Reset stops all transactions and reboots the station
+func (s *Station) Reset(reason core.Reason) {
+	for _, conn := range s.connectors {
+		conn.Stop(reason)
+	}
+
+	time.AfterFunc(resetDelay, s.Boot)
+}
+
+// applyLimit updates all connectors after charging profiles have changed
+func (s *Station) applyLimit() {
+	for _, conn := range s.connectors {
+		conn.do(conn.applyLimit)
+	}
+}
+
+// Boot sends the boot notification followed by the connector status
+func (s *Station) Boot() {
+	res, err := s.cp.BootNotification("demo", "evcc")
+	if err != nil {
+		s.log.Println("BootNotification:", err)
+		return
+	}
+
+	s.log.Println("BootNotification:", res.Status)
+
+	if res.Status == core.RegistrationStatusAccepted && res.Interval > 0 {
+		s.setHeartbeat(time.Duration(res.Interval) * time.Second)
+	}
+
+	s.sendStationStatus()
+	for _, conn := range s.connectors {
+		conn.Trigger(conn.sendStatus)
+	}
+}
+
+// setHeartbeat changes the heartbeat interval. Zero keeps the current interval.
+func (s *Station) setHeartbeat(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	select {
+	case s.heartbeatC <- interval:
+	default:
+	}
+}
+
+// configure applies a changed configuration key
+func (s *Station) configure(key string) {
+	switch key {
+	case KeyHeartbeatInterval:
+		s.setHeartbeat(time.Duration(s.config.Int(key)) * time.Second)
+
+	case KeyMeterValueSampleInterval:
+		interval := time.Duration(s.config.Int(key)) * time.Second
+		for _, conn := range s.connectors {
+			conn.SetMeterInterval(interval)
+		}
+	}
+}
+
+// sendStationStatus sends the status of the charge point itself (connector 0)
+func (s *Station) sendStationStatus() {
+	if _, err := s.cp.StatusNotification(0, core.NoError, core.ChargePointStatusAvailable, func(request *core.StatusNotificationRequest) {
+		if !s.quirks.NoTimestamps {
+			request.Timestamp = types.NewDateTime(time.Now())
+		}
+	}); err != nil {
+		s.log.Println("StatusNotification:", err)
+	}
+}
+
+// heartbeat sends a heartbeat message
+func (s *Station) heartbeat() {
+	if _, err := s.cp.Heartbeat(); err != nil {
+		s.log.Println("Heartbeat:", err)
+	}
+}
+
+// run sends heartbeats and answers trigger requests
+func (s *Station) run() {
+	heartbeat := time.NewTicker(time.Hour)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case interval := <-s.heartbeatC:
+			heartbeat.Reset(interval)
+
+		case <-heartbeat.C:
+			s.heartbeat()
+
+		case req := <-s.triggerC:
+			s.trigger(req)
+		}
+	}
+}
+
+// trigger sends the message requested by the central system
+func (s *Station) trigger(req *remotetrigger.TriggerMessageRequest) {
+	connectors := s.connectors
+	if req.ConnectorId != nil && *req.ConnectorId > 0 {
+		if conn := s.Connector(*req.ConnectorId); conn != nil {
+			connectors = []*Connector{conn}
+		}
+	}
+
+	switch req.RequestedMessage {
+	case core.BootNotificationFeatureName:
+		s.Boot()
+
+	case core.HeartbeatFeatureName:
+		s.heartbeat()
+
+	case core.StatusNotificationFeatureName:
+		if req.ConnectorId == nil || *req.ConnectorId == 0 {
+			s.sendStationStatus()
+		}
+		for _, conn := range connectors {
+			conn.Trigger(conn.sendStatus)
+		}
+
+	case core.MeterValuesFeatureName:
+		for _, conn := range connectors {
+			conn := conn
+			conn.Trigger(func() { conn.sendMeterValues(types.ReadingContextTrigger) })
+		}
+
+	default:
+		s.log.Println("unsupported trigger:", req.RequestedMessage)
+	}
+}
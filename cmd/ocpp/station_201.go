@@ -0,0 +1,338 @@
+package main
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/evcc-io/evcc/util/ocpp201"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	ocpp2 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/remotecontrol"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/transactions"
+	types201 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// evseConnector is the connector id of each simulated EVSE
+var evseConnector = 1
+
+// transaction201 is the OCPP 2.0.1 state of a transaction
+type transaction201 struct {
+	id    int
+	seqNo int
+	state transactions.ChargingState
+}
+
+// chargingStation201 implements the simulator's ChargePoint on top of an OCPP 2.0.1 charging station.
+// Each connector is modelled as EVSE with a single connector and transactions are reported as transaction events.
+type chargingStation201 struct {
+	cs ocpp2.ChargingStation
+
+	mu           sync.Mutex
+	txnCount     int
+	txns         map[int]*transaction201                               // active transactions by connector
+	remoteStarts map[int]*remotecontrol.RequestStartTransactionRequest // pending remote starts by connector
+}
+
+func newChargingStation201(cs ocpp2.ChargingStation) *chargingStation201 {
+	return &chargingStation201{
+		cs: cs,
+		// transaction ids are assigned by the station and must be unique across restarts
+		txnCount:     int(time.Now().Unix()),
+		txns:         make(map[int]*transaction201),
+		remoteStarts: make(map[int]*remotecontrol.RequestStartTransactionRequest),
+	}
+}
+
+func (s *chargingStation201) Start(centralSystemUrl string) error {
+	return s.cs.Start(centralSystemUrl)
+}
+
+func (s *chargingStation201) Stop() {
+	s.cs.Stop()
+}
+
+func (s *chargingStation201) Errors() <-chan error {
+	return s.cs.Errors()
+}
+
+// RemoteStart registers the remote start for the connector's next transaction
+func (s *chargingStation201) RemoteStart(connectorId int, request *remotecontrol.RequestStartTransactionRequest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remoteStarts[connectorId] = request
+}
+
+func (s *chargingStation201) BootNotification(chargePointModel string, chargePointVendor string, props ...func(request *core.BootNotificationRequest)) (*core.BootNotificationConfirmation, error) {
+	request := core.NewBootNotificationRequest(chargePointModel, chargePointVendor)
+	for _, fn := range props {
+		fn(request)
+	}
+
+	res, err := s.cs.BootNotification(provisioning.BootReasonPowerUp, chargePointModel, chargePointVendor, func(req *provisioning.BootNotificationRequest) {
+		req.ChargingStation.SerialNumber = request.ChargePointSerialNumber
+		req.ChargingStation.FirmwareVersion = request.FirmwareVersion
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &core.BootNotificationConfirmation{
+		CurrentTime: types.NewDateTime(res.CurrentTime.Time),
+		Interval:    res.Interval,
+		Status:      core.RegistrationStatus(res.Status),
+	}, nil
+}
+
+func (s *chargingStation201) Authorize(idTag string, props ...func(request *core.AuthorizeRequest)) (*core.AuthorizeConfirmation, error) {
+	res, err := s.cs.Authorize(idTag, types201.IdTokenTypeISO14443)
+	if err != nil {
+		return nil, err
+	}
+
+	return core.NewAuthorizationConfirmation(&types.IdTagInfo{
+		Status: types.AuthorizationStatus(res.IdTokenInfo.Status),
+	}), nil
+}
+
+func (s *chargingStation201) Heartbeat(props ...func(request *core.HeartbeatRequest)) (*core.HeartbeatConfirmation, error) {
+	res, err := s.cs.Heartbeat()
+	if err != nil {
+		return nil, err
+	}
+
+	return core.NewHeartbeatConfirmation(types.NewDateTime(res.CurrentTime.Time)), nil
+}
+
+// StatusNotification reports the connector status and, during transactions, charging state changes.
+// OCPP 2.0.1 has no status of the charging station itself, hence connector 0 is ignored.
+func (s *chargingStation201) StatusNotification(connectorId int, errorCode core.ChargePointErrorCode, status core.ChargePointStatus, props ...func(request *core.StatusNotificationRequest)) (*core.StatusNotificationConfirmation, error) {
+	if connectorId == 0 {
+		return core.NewStatusNotificationConfirmation(), nil
+	}
+
+	request := core.NewStatusNotificationRequest(connectorId, errorCode, status)
+	for _, fn := range props {
+		fn(request)
+	}
+
+	// timestamp is mandatory
+	timestamp := types201.NewDateTime(time.Now())
+	if request.Timestamp != nil {
+		timestamp = types201.NewDateTime(request.Timestamp.Time)
+	}
+
+	if _, err := s.cs.StatusNotification(timestamp, ocpp201.ConnectorStatus(status), connectorId, evseConnector); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	txn, ok := s.txns[connectorId]
+	state := ocpp201.ChargingState(status)
+	changed := ok && txn.state != state
+	if changed {
+		txn.state = state
+	}
+	s.mu.Unlock()
+
+	if changed {
+		if _, err := s.transactionEvent(transactions.TransactionEventUpdated, timestamp, transactions.TriggerReasonChargingStateChanged, connectorId, txn, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return core.NewStatusNotificationConfirmation(), nil
+}
+
+// MeterValues reports meter values as transaction event during transactions
+func (s *chargingStation201) MeterValues(connectorId int, meterValues []types.MeterValue, props ...func(request *core.MeterValuesRequest)) (*core.MeterValuesConfirmation, error) {
+	request := core.NewMeterValuesRequest(connectorId, meterValues)
+	for _, fn := range props {
+		fn(request)
+	}
+
+	values, err := ocpp201.MeterValues(meterValues)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.TransactionId == nil {
+		if _, err := s.cs.MeterValues(connectorId, values); err != nil {
+			return nil, err
+		}
+
+		return core.NewMeterValuesConfirmation(), nil
+	}
+
+	s.mu.Lock()
+	txn, ok := s.txns[connectorId]
+	if !ok || txn.id != *request.TransactionId {
+		// unknown transaction is reported as is
+		txn = &transaction201{id: *request.TransactionId}
+	}
+	s.mu.Unlock()
+
+	reason := transactions.TriggerReasonMeterValuePeriodic
+	if len(meterValues) > 0 && len(meterValues[0].SampledValue) > 0 {
+		switch meterValues[0].SampledValue[0].Context {
+		case types.ReadingContextTrigger:
+			reason = transactions.TriggerReasonTrigger
+		case types.ReadingContextSampleClock:
+			reason = transactions.TriggerReasonMeterValueClock
+		}
+	}
+
+	if _, err := s.transactionEvent(transactions.TransactionEventUpdated, types201.NewDateTime(time.Now()), reason, connectorId, txn, func(request *transactions.TransactionEventRequest) {
+		request.MeterValue = values
+	}); err != nil {
+		return nil, err
+	}
+
+	return core.NewMeterValuesConfirmation(), nil
+}
+
+// StartTransaction assigns the transaction id and reports the transaction start
+func (s *chargingStation201) StartTransaction(connectorId int, idTag string, meterStart int, timestamp *types.DateTime, props ...func(request *core.StartTransactionRequest)) (*core.StartTransactionConfirmation, error) {
+	s.mu.Lock()
+	s.txnCount++
+	txn := &transaction201{id: s.txnCount, state: transactions.ChargingStateEVConnected}
+	s.txns[connectorId] = txn
+
+	reason := transactions.TriggerReasonAuthorized
+	idToken := types201.IdToken{IdToken: idTag, Type: types201.IdTokenTypeISO14443}
+
+	remoteStart, remote := s.remoteStarts[connectorId]
+	if remote {
+		reason = transactions.TriggerReasonRemoteStart
+		idToken.Type = remoteStart.IDToken
+		delete(s.remoteStarts, connectorId)
+	}
+	s.mu.Unlock()
+
+	ts := types201.NewDateTime(timestamp.Time)
+
+	res, err := s.transactionEvent(transactions.TransactionEventStarted, ts, reason, connectorId, txn, func(request *transactions.TransactionEventRequest) {
+		request.IDToken = &idToken
+		request.MeterValue = energyMeterValue(ts, meterStart, types201.ReadingContextTransactionBegin)
+		if remote {
+			request.TransactionInfo.RemoteStartID = &remoteStart.RemoteStartID
+		}
+	})
+	if err != nil {
+		s.removeTransaction(connectorId, txn.id)
+		return nil, err
+	}
+
+	status := types.AuthorizationStatusAccepted
+	if res.IDTokenInfo != nil {
+		status = types.AuthorizationStatus(res.IDTokenInfo.Status)
+	}
+
+	// transaction has already started and is ended if not authorized
+	if status != types.AuthorizationStatusAccepted {
+		s.removeTransaction(connectorId, txn.id)
+
+		if _, err := s.transactionEvent(transactions.TransactionEventEnded, ts, transactions.TriggerReasonDeAuthorized, connectorId, txn, func(request *transactions.TransactionEventRequest) {
+			request.TransactionInfo.StoppedReason = transactions.ReasonDeAuthorized
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	return core.NewStartTransactionConfirmation(&types.IdTagInfo{Status: status}, txn.id), nil
+}
+
+// StopTransaction reports the transaction end
+func (s *chargingStation201) StopTransaction(meterStop int, timestamp *types.DateTime, transactionId int, props ...func(request *core.StopTransactionRequest)) (*core.StopTransactionConfirmation, error) {
+	request := core.NewStopTransactionRequest(meterStop, timestamp, transactionId)
+	for _, fn := range props {
+		fn(request)
+	}
+
+	// unknown transaction is reported as is
+	connectorId, txn := 0, &transaction201{id: transactionId}
+
+	s.mu.Lock()
+	for id, t := range s.txns {
+		if t.id == transactionId {
+			connectorId, txn = id, t
+			delete(s.txns, id)
+		}
+	}
+	s.mu.Unlock()
+
+	var reason transactions.TriggerReason
+	switch request.Reason {
+	case core.ReasonRemote:
+		reason = transactions.TriggerReasonRemoteStop
+	case core.ReasonEVDisconnected:
+		reason = transactions.TriggerReasonEVCommunicationLost
+	case core.ReasonUnlockCommand:
+		reason = transactions.TriggerReasonUnlockCommand
+	case core.ReasonHardReset, core.ReasonSoftReset:
+		reason = transactions.TriggerReasonResetCommand
+	case core.ReasonDeAuthorized:
+		reason = transactions.TriggerReasonDeAuthorized
+	case core.ReasonLocal, "":
+		reason = transactions.TriggerReasonStopAuthorized
+	default:
+		reason = transactions.TriggerReasonAbnormalCondition
+	}
+
+	ts := types201.NewDateTime(timestamp.Time)
+
+	if _, err := s.transactionEvent(transactions.TransactionEventEnded, ts, reason, connectorId, txn, func(req *transactions.TransactionEventRequest) {
+		req.TransactionInfo.StoppedReason = ocpp201.StoppedReason(request.Reason)
+		req.MeterValue = energyMeterValue(ts, meterStop, types201.ReadingContextTransactionEnd)
+	}); err != nil {
+		return nil, err
+	}
+
+	return core.NewStopTransactionConfirmation(), nil
+}
+
+// transactionEvent sends the transaction's next event. Connector 0 omits the EVSE.
+func (s *chargingStation201) transactionEvent(event transactions.TransactionEvent, timestamp *types201.DateTime, reason transactions.TriggerReason, connectorId int, txn *transaction201, props func(request *transactions.TransactionEventRequest)) (*transactions.TransactionEventResponse, error) {
+	s.mu.Lock()
+	seqNo := txn.seqNo
+	txn.seqNo++
+	info := transactions.Transaction{
+		TransactionID: strconv.Itoa(txn.id),
+		ChargingState: txn.state,
+	}
+	s.mu.Unlock()
+
+	return s.cs.TransactionEvent(event, timestamp, reason, seqNo, info, func(request *transactions.TransactionEventRequest) {
+		if connectorId > 0 {
+			request.Evse = &types201.EVSE{ID: connectorId, ConnectorID: &evseConnector}
+		}
+		if props != nil {
+			props(request)
+		}
+	})
+}
+
+// removeTransaction removes the connector's transaction if it has the given id
+func (s *chargingStation201) removeTransaction(connectorId, txnId int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if txn, ok := s.txns[connectorId]; ok && txn.id == txnId {
+		delete(s.txns, connectorId)
+	}
+}
+
+// energyMeterValue returns the energy register reading in Wh
+func energyMeterValue(timestamp *types201.DateTime, energy int, context types201.ReadingContext) []types201.MeterValue {
+	return []types201.MeterValue{{
+		Timestamp: *timestamp,
+		SampledValue: []types201.SampledValue{{
+			Value:         float64(energy),
+			Context:       context,
+			Measurand:     types201.MeasurandEnergyActiveImportRegister,
+			UnitOfMeasure: &types201.UnitOfMeasure{Unit: string(types.UnitOfMeasureWh)},
+		}},
+	}}
+}
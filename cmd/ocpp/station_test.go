@@ -0,0 +1,255 @@
+package main
+
+import (
+	"log"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/remotetrigger"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/reservation"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testChargePoint records the messages sent to the central system and accepts all transactions
+type testChargePoint struct {
+	mu       sync.Mutex
+	txnId    int
+	messages []string
+	stopped  []int // transaction ids of StopTransaction
+	metered  []int // transaction ids of MeterValues
+	values   []types.MeterValue
+	bootC    chan struct{} // blocks BootNotification until closed if set
+}
+
+func (cp *testChargePoint) add(message string) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	cp.messages = append(cp.messages, message)
+}
+
+func (cp *testChargePoint) Start(string) error   { return nil }
+func (cp *testChargePoint) Stop()                {}
+func (cp *testChargePoint) Errors() <-chan error { return nil }
+
+func (cp *testChargePoint) BootNotification(model, vendor string, props ...func(request *core.BootNotificationRequest)) (*core.BootNotificationConfirmation, error) {
+	if cp.bootC != nil {
+		<-cp.bootC
+	}
+	cp.add(core.BootNotificationFeatureName)
+	return core.NewBootNotificationConfirmation(types.NewDateTime(time.Now()), 0, core.RegistrationStatusAccepted), nil
+}
+
+func (cp *testChargePoint) Authorize(idTag string, props ...func(request *core.AuthorizeRequest)) (*core.AuthorizeConfirmation, error) {
+	cp.add(core.AuthorizeFeatureName)
+	return core.NewAuthorizationConfirmation(types.NewIdTagInfo(types.AuthorizationStatusAccepted)), nil
+}
+
+func (cp *testChargePoint) Heartbeat(props ...func(request *core.HeartbeatRequest)) (*core.HeartbeatConfirmation, error) {
+	cp.add(core.HeartbeatFeatureName)
+	return core.NewHeartbeatConfirmation(types.NewDateTime(time.Now())), nil
+}
+
+func (cp *testChargePoint) MeterValues(connectorId int, meterValues []types.MeterValue, props ...func(request *core.MeterValuesRequest)) (*core.MeterValuesConfirmation, error) {
+	request := core.NewMeterValuesRequest(connectorId, meterValues)
+	for _, fn := range props {
+		fn(request)
+	}
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	cp.messages = append(cp.messages, core.MeterValuesFeatureName)
+	cp.values = append(cp.values, meterValues...)
+	if request.TransactionId != nil {
+		cp.metered = append(cp.metered, *request.TransactionId)
+	}
+
+	return core.NewMeterValuesConfirmation(), nil
+}
+
+func (cp *testChargePoint) StartTransaction(connectorId int, idTag string, meterStart int, timestamp *types.DateTime, props ...func(request *core.StartTransactionRequest)) (*core.StartTransactionConfirmation, error) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	cp.messages = append(cp.messages, core.StartTransactionFeatureName)
+	cp.txnId++
+
+	return core.NewStartTransactionConfirmation(types.NewIdTagInfo(types.AuthorizationStatusAccepted), cp.txnId), nil
+}
+
+func (cp *testChargePoint) StopTransaction(meterStop int, timestamp *types.DateTime, transactionId int, props ...func(request *core.StopTransactionRequest)) (*core.StopTransactionConfirmation, error) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	cp.messages = append(cp.messages, core.StopTransactionFeatureName)
+	cp.stopped = append(cp.stopped, transactionId)
+
+	return core.NewStopTransactionConfirmation(), nil
+}
+
+func (cp *testChargePoint) StatusNotification(connectorId int, errorCode core.ChargePointErrorCode, status core.ChargePointStatus, props ...func(request *core.StatusNotificationRequest)) (*core.StatusNotificationConfirmation, error) {
+	cp.add(core.StatusNotificationFeatureName)
+	return core.NewStatusNotificationConfirmation(), nil
+}
+
+// newTestStation creates a station with given number of connectors that is not connected to a central system
+func newTestStation(t *testing.T, cp ChargePoint, connectors int, quirks Quirks) *Station {
+	t.Helper()
+
+	config, err := NewConfigStore(DefaultConfig(connectors, 0), "")
+	require.NoError(t, err)
+
+	s := NewStation("test", cp, nil, connectors, *NewEV(50, 20, 16, 3, 80), config, quirks)
+	s.log = log.New(os.Stderr, "test ", log.LstdFlags)
+
+	return s
+}
+
+// waitStatus waits until the connector reaches the status
+func waitStatus(t *testing.T, conn *Connector, status core.ChargePointStatus) {
+	t.Helper()
+	require.Eventually(t, func() bool { return conn.Status() == status }, time.Second, 10*time.Millisecond, "status %s", status)
+}
+
+func TestChangeAvailabilityScheduled(t *testing.T) {
+	s := newTestStation(t, new(testChargePoint), 2, Quirks{})
+	conn := s.Connector(1)
+
+	conn.Plug()
+	require.True(t, conn.Authorize("tag", nil))
+	waitStatus(t, conn, core.ChargePointStatusCharging)
+
+	// idle connector changes immediately, busy connector once the transaction stops
+	assert.Equal(t, core.AvailabilityStatusScheduled, s.ChangeAvailability(0, false))
+	waitStatus(t, s.Connector(2), core.ChargePointStatusUnavailable)
+	assert.Equal(t, core.ChargePointStatusCharging, conn.Status())
+
+	require.True(t, conn.Stop(core.ReasonLocal))
+	waitStatus(t, conn, core.ChargePointStatusUnavailable)
+
+	// unplugging and plugging keeps the connector inoperative
+	conn.Unplug()
+	conn.Plug()
+	require.Eventually(t, func() bool { return conn.Inoperative() }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, core.ChargePointStatusUnavailable, conn.Status())
+	assert.False(t, conn.Free("tag"))
+
+	assert.Equal(t, core.AvailabilityStatusAccepted, s.ChangeAvailability(1, true))
+	waitStatus(t, conn, core.ChargePointStatusPreparing)
+}
+
+func TestChangeAvailabilityBusy(t *testing.T) {
+	s := newTestStation(t, new(testChargePoint), 2, Quirks{})
+	conn1, conn2 := s.Connector(1), s.Connector(2)
+	waitStatus(t, conn1, core.ChargePointStatusAvailable)
+
+	// block the second connector's run loop and fill its action queue
+	block := make(chan struct{})
+	defer close(block)
+
+	require.True(t, conn2.do(func() { <-block }))
+	require.Eventually(t, func() bool { return len(conn2.actionC) == 0 }, time.Second, 10*time.Millisecond)
+	for i := 0; i < cap(conn2.actionC); i++ {
+		require.True(t, conn2.do(func() {}))
+	}
+
+	assert.Equal(t, core.AvailabilityStatusRejected, s.ChangeAvailability(0, false))
+
+	// wait for the first connector's queue to be processed
+	done := make(chan struct{})
+	require.True(t, conn1.do(func() { close(done) }))
+	<-done
+
+	assert.False(t, conn1.Inoperative())
+	assert.Equal(t, core.ChargePointStatusAvailable, conn1.Status())
+}
+
+func TestConnectorBusy(t *testing.T) {
+	cp := &testChargePoint{bootC: make(chan struct{})}
+	defer close(cp.bootC)
+
+	s := newTestStation(t, cp, 1, Quirks{})
+	conn := s.Connector(1)
+	handler := &ChargePointHandler{station: s}
+
+	// block the run loop and fill the action queue
+	block := make(chan struct{})
+	defer close(block)
+
+	require.True(t, conn.do(func() { <-block }))
+	require.Eventually(t, func() bool { return len(conn.actionC) == 0 }, time.Second, 10*time.Millisecond)
+	for i := 0; i < cap(conn.actionC); i++ {
+		require.True(t, conn.do(func() {}))
+	}
+
+	res, err := handler.OnRemoteStartTransaction(core.NewRemoteStartTransactionRequest("tag"))
+	require.NoError(t, err)
+	assert.Equal(t, types.RemoteStartStopStatusRejected, res.Status)
+
+	// block the station's run loop with a triggered boot and fill the trigger queue
+	trigger := remotetrigger.NewTriggerMessageRequest(core.BootNotificationFeatureName)
+	require.True(t, s.Trigger(trigger))
+	require.Eventually(t, func() bool { return len(s.triggerC) == 0 }, time.Second, 10*time.Millisecond)
+	require.True(t, s.Trigger(trigger))
+
+	tres, err := handler.OnTriggerMessage(trigger)
+	require.NoError(t, err)
+	assert.Equal(t, remotetrigger.TriggerMessageStatusRejected, tres.Status)
+}
+
+func TestReservationExpiryBusy(t *testing.T) {
+	s := newTestStation(t, new(testChargePoint), 1, Quirks{})
+	conn := s.Connector(1)
+
+	require.Equal(t, reservation.ReservationStatusAccepted, conn.Reserve(1, "tag", time.Now().Add(100*time.Millisecond)))
+	waitStatus(t, conn, core.ChargePointStatusReserved)
+
+	// block the run loop and fill the action queue until the reservation has expired
+	block := make(chan struct{})
+
+	require.True(t, conn.do(func() { <-block }))
+	require.Eventually(t, func() bool { return len(conn.actionC) == 0 }, time.Second, 10*time.Millisecond)
+	for i := 0; i < cap(conn.actionC); i++ {
+		require.True(t, conn.do(func() {}))
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	close(block)
+
+	require.Eventually(t, func() bool { return conn.Status() == core.ChargePointStatusAvailable }, 2*busyRetry, 10*time.Millisecond)
+}
+
+func TestMultiConnector(t *testing.T) {
+	s := newTestStation(t, new(testChargePoint), 2, Quirks{})
+	handler := &ChargePointHandler{station: s}
+
+	for _, conn := range s.connectors {
+		conn.Plug()
+	}
+
+	// remote starts without connector use the next free connector
+	for i := 1; i <= 2; i++ {
+		res, err := handler.OnRemoteStartTransaction(core.NewRemoteStartTransactionRequest("tag"))
+		require.NoError(t, err)
+		require.Equal(t, types.RemoteStartStopStatusAccepted, res.Status)
+		require.Eventually(t, func() bool { return s.Connector(i).TransactionID() == i }, time.Second, 10*time.Millisecond)
+	}
+
+	res, err := handler.OnRemoteStartTransaction(core.NewRemoteStartTransactionRequest("tag"))
+	require.NoError(t, err)
+	assert.Equal(t, types.RemoteStartStopStatusRejected, res.Status)
+
+	// remote stop addresses the transaction's connector only
+	stop, err := handler.OnRemoteStopTransaction(core.NewRemoteStopTransactionRequest(2))
+	require.NoError(t, err)
+	require.Equal(t, types.RemoteStartStopStatusAccepted, stop.Status)
+
+	waitStatus(t, s.Connector(2), core.ChargePointStatusFinishing)
+	assert.Equal(t, 1, s.Connector(1).TransactionID())
+	assert.Equal(t, core.ChargePointStatusCharging, s.Connector(1).Status())
+}
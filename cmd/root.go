@@ -12,6 +12,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/evcc-io/evcc/charger/ocpp"
 	"github.com/evcc-io/evcc/core"
 	"github.com/evcc-io/evcc/push"
 	"github.com/evcc-io/evcc/server"
@@ -246,6 +247,7 @@ func runRoot(cmd *cobra.Command, args []string) {
 		// set channels
 		site.DumpConfig()
 		site.Prepare(valueChan, pushChan)
+		ocpp.Prepare(valueChan, pushChan)
 
 		// show and check version
 		valueChan <- util.Param{Key: "version", Val: server.FormattedVersion()}
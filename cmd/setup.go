@@ -9,6 +9,7 @@ import (
 
 	paho "github.com/eclipse/paho.mqtt.golang"
 	"github.com/evcc-io/evcc/api"
+	"github.com/evcc-io/evcc/charger/ocpp"
 	"github.com/evcc-io/evcc/cmd/shutdown"
 	"github.com/evcc-io/evcc/core"
 	"github.com/evcc-io/evcc/core/loadpoint"
@@ -104,6 +105,11 @@ func configureEnvironment(cmd *cobra.Command, conf config) (err error) {
 		err = configureEEBus(conf.EEBus)
 	}
 
+	// setup OCPP central system
+	if err == nil {
+		err = configureOCPP(conf.OCPP, conf.Network)
+	}
+
 	return
 }
 
@@ -205,6 +211,20 @@ func configureEEBus(conf map[string]interface{}) error {
 	return nil
 }
 
+// setup ocpp
+func configureOCPP(conf ocpp.Config, network networkConfig) error {
+	// stations download firmware and upload diagnostics from evcc's http server
+	if conf.URL == "" {
+		conf.URL = network.URI()
+	}
+
+	if err := ocpp.Configure(conf); err != nil {
+		return fmt.Errorf("failed configuring ocpp: %w", err)
+	}
+
+	return nil
+}
+
 // setup messaging
 func configureMessengers(conf messagingConfig, cache *util.Cache) (chan push.Event, error) {
 	messageChan := make(chan push.Event, 1)
@@ -148,6 +148,10 @@ type Loadpoint struct {
 	planSlotEnd time.Time // current plan slot end time
 	planActive  bool      // plan is active
 
+	// reservation
+	reservationId     string    // identifier the charger is reserved for
+	reservationExpiry time.Time // reservation expiry
+
 	// cached state
 	status         api.ChargeStatus       // Charger status
 	remoteDemand   loadpoint.RemoteDemand // External status demand
@@ -1818,6 +1822,9 @@ func (lp *Loadpoint) Update(sitePower float64, batteryBuffered bool) {
 	lp.publish("charging", lp.charging())
 	lp.publish("enabled", lp.enabled)
 
+	// reservation ends when used or expired
+	lp.updateReservation()
+
 	// identify connected vehicle
 	if lp.connected() {
 		// read identity and run associated action
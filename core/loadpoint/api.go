@@ -53,6 +53,13 @@ type API interface {
 	// RemoteControl sets remote status demand
 	RemoteControl(string, RemoteDemand)
 
+	// GetReservation returns the reserved identifier and reservation expiry
+	GetReservation() (string, time.Time)
+	// SetReservation reserves the charger for the identifier until expiry
+	SetReservation(string, time.Time) error
+	// RemoveReservation cancels the charger's reservation
+	RemoveReservation() error
+
 	//
 	// power and energy
 	//
@@ -215,6 +215,82 @@ func (lp *Loadpoint) setTargetTime(finishAt time.Time) {
 	lp.publish(targetTime, finishAt)
 }
 
+// GetReservation returns the identifier the charger is reserved for and the reservation expiry
+func (lp *Loadpoint) GetReservation() (string, time.Time) {
+	lp.Lock()
+	defer lp.Unlock()
+	return lp.reservationId, lp.reservationExpiry
+}
+
+// SetReservation reserves the charger for the identifier until expiry
+func (lp *Loadpoint) SetReservation(id string, expiry time.Time) error {
+	reserver, ok := lp.charger.(api.Reserver)
+	if !ok {
+		return errors.New("charger does not support reservations")
+	}
+
+	if !expiry.After(lp.clock.Now()) {
+		return errors.New("timestamp is in the past")
+	}
+
+	lp.log.DEBUG.Printf("set reservation: %s @ %v", id, expiry)
+
+	// charger may take a while to respond
+	if err := reserver.Reserve(id, expiry); err != nil {
+		return err
+	}
+
+	lp.Lock()
+	defer lp.Unlock()
+	lp.setReservation(id, expiry)
+
+	return nil
+}
+
+// RemoveReservation cancels the charger's reservation
+func (lp *Loadpoint) RemoveReservation() error {
+	reserver, ok := lp.charger.(api.Reserver)
+	if !ok {
+		return errors.New("charger does not support reservations")
+	}
+
+	lp.log.DEBUG.Println("remove reservation")
+
+	if err := reserver.CancelReservation(); err != nil {
+		return err
+	}
+
+	lp.Lock()
+	defer lp.Unlock()
+	lp.setReservation("", time.Time{})
+
+	return nil
+}
+
+// setReservation sets the reservation
+func (lp *Loadpoint) setReservation(id string, expiry time.Time) {
+	lp.reservationId = id
+	lp.reservationExpiry = expiry
+	lp.publish("reservationIdentity", id)
+	lp.publish("reservationExpiry", expiry)
+}
+
+// updateReservation removes the reservation once charging has started or it has expired
+func (lp *Loadpoint) updateReservation() {
+	charging := lp.charging()
+
+	lp.Lock()
+	defer lp.Unlock()
+
+	if lp.reservationExpiry.IsZero() {
+		return
+	}
+
+	if charging || lp.clock.Now().After(lp.reservationExpiry) {
+		lp.setReservation("", time.Time{})
+	}
+}
+
 // RemoteControl sets remote status demand
 func (lp *Loadpoint) RemoteControl(source string, demand loadpoint.RemoteDemand) {
 	lp.Lock()
@@ -771,3 +771,62 @@ func TestMinSoc(t *testing.T) {
 		}
 	}
 }
+
+func TestReservation(t *testing.T) {
+	clock := clock.NewMock()
+	ctrl := gomock.NewController(t)
+	charger := mock.NewMockCharger(ctrl)
+	reserver := mock.NewMockReserver(ctrl)
+
+	lp := &Loadpoint{
+		log:   util.NewLogger("foo"),
+		clock: clock,
+		charger: struct {
+			*mock.MockCharger
+		}{
+			charger,
+		},
+	}
+
+	// charger without reservation support
+	if err := lp.SetReservation("card", clock.Now().Add(time.Hour)); err == nil {
+		t.Error("expected error")
+	}
+
+	lp.charger = struct {
+		*mock.MockCharger
+		*mock.MockReserver
+	}{
+		charger, reserver,
+	}
+
+	if err := lp.SetReservation("card", clock.Now().Add(-time.Minute)); err == nil {
+		t.Error("expected error for past expiry")
+	}
+
+	expiry := clock.Now().Add(time.Hour)
+	reserver.EXPECT().Reserve("card", expiry).Return(nil)
+
+	if err := lp.SetReservation("card", expiry); err != nil {
+		t.Error(err)
+	}
+
+	if id, exp := lp.GetReservation(); id != "card" || !exp.Equal(expiry) {
+		t.Errorf("reservation: %s %v", id, exp)
+	}
+
+	// reservation ends at expiry
+	lp.status = api.StatusA
+	clock.Add(2 * time.Hour)
+	lp.updateReservation()
+
+	if id, _ := lp.GetReservation(); id != "" {
+		t.Errorf("reservation not expired: %s", id)
+	}
+
+	reserver.EXPECT().CancelReservation().Return(nil)
+
+	if err := lp.RemoveReservation(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,488 @@
+package ocpp
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/evcc-io/evcc/api"
+	"github.com/evcc-io/evcc/core/loadpoint"
+	"github.com/evcc-io/evcc/util"
+	"github.com/evcc-io/evcc/util/chargingprofile"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+)
+
+// sender sends the connector's status to the central system
+type sender interface {
+	StatusNotification(connectorId int, errorCode core.ChargePointErrorCode, status core.ChargePointStatus, props ...func(request *core.StatusNotificationRequest)) (*core.StatusNotificationConfirmation, error)
+}
+
+// transaction is the connector's transaction. Its messages are queued until delivered to the central system.
+type transaction struct {
+	ref        int64 // queued start message
+	id         int   // assigned by the central system, 0 until the start message has been delivered
+	idTag      string
+	meterStart int
+	started    time.Time
+}
+
+// connector maps a loadpoint to an OCPP connector. Its state is updated by the charge point's loop,
+// remote commands only modify the state and never send messages themselves.
+type connector struct {
+	mu       sync.Mutex
+	log      *util.Logger
+	clock    clock.Clock
+	id       int
+	lp       loadpoint.API
+	profiles *chargingprofile.Store
+	queue    *queue
+	idTag    string // id tag of transactions not started remotely
+
+	sent        core.ChargePointStatus // last status sent
+	inoperative bool
+	scheduled   bool // inoperative once the transaction stops
+	txn         *transaction
+	remoteIdTag string    // id tag of the pending remote start
+	remoteStart time.Time // time of the pending remote start
+	remoteStop  bool
+	finished    bool // transaction finished while the vehicle is still connected
+
+	energy   float64 // Wh, integrated from charge power since start
+	measured time.Time
+	metered  time.Time // last meter values sent
+
+	demand     loadpoint.RemoteDemand // remote demand applied to the loadpoint
+	maxCurrent float64                // loadpoint's own max current, restored once no profile applies
+	applied    float64                // max current applied by charging profiles, 0 if not limited
+	suspended  bool                   // charging profile limit below min current
+}
+
+func newConnector(log *util.Logger, id int, lp loadpoint.API, profiles *chargingprofile.Store, queue *queue, idTag string) *connector {
+	conn := &connector{
+		log:      log,
+		clock:    clock.New(),
+		id:       id,
+		lp:       lp,
+		profiles: profiles,
+		queue:    queue,
+		idTag:    idTag,
+	}
+
+	conn.restore()
+
+	return conn
+}
+
+// restore applies the energy register and open transaction persisted before restart
+func (conn *connector) restore() {
+	state, err := conn.queue.state(conn.id)
+	if err != nil {
+		conn.log.ERROR.Printf("connector %d: restore: %v", conn.id, err)
+		return
+	}
+
+	conn.energy = state.Energy
+
+	if state.Ref != 0 {
+		conn.log.DEBUG.Printf("connector %d: restored transaction %d", conn.id, state.TxnId)
+
+		conn.txn = &transaction{
+			ref:        state.Ref,
+			id:         state.TxnId,
+			idTag:      state.IdTag,
+			meterStart: state.MeterStart,
+			started:    state.Started,
+		}
+	}
+}
+
+// persist saves the energy register and open transaction. Lock must be held.
+func (conn *connector) persist() {
+	state := State{
+		Connector: conn.id,
+		Energy:    conn.energy,
+	}
+
+	if conn.txn != nil {
+		state.Ref = conn.txn.ref
+		state.TxnId = conn.txn.id
+		state.IdTag = conn.txn.idTag
+		state.MeterStart = conn.txn.meterStart
+		state.Started = conn.txn.started
+	}
+
+	if err := conn.queue.save(state); err != nil {
+		conn.log.ERROR.Printf("connector %d: persist: %v", conn.id, err)
+	}
+}
+
+// measure integrates the charge power into the energy register. Lock must be held.
+func (conn *connector) measure() {
+	now := conn.clock.Now()
+
+	if !conn.measured.IsZero() {
+		conn.energy += conn.lp.GetChargePower() * now.Sub(conn.measured).Hours()
+	}
+
+	conn.measured = now
+}
+
+// transaction returns the connector's transaction for evaluating charging profiles or nil if idle
+func (conn *connector) transaction() *chargingprofile.Transaction {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	if conn.txn == nil {
+		return nil
+	}
+
+	return &chargingprofile.Transaction{ID: conn.txn.id, Start: conn.txn.started}
+}
+
+// update synchronizes the connector's transaction with the loadpoint and queues its messages
+func (conn *connector) update(sampleInterval, connectionTimeout time.Duration, sampledData []types.Measurand) {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	conn.measure()
+	now := conn.clock.Now()
+
+	status := conn.lp.GetStatus()
+	connected := status == api.StatusB || status == api.StatusC
+
+	// stop transaction if vehicle disconnected or stop requested
+	var stopped bool
+
+	if conn.txn != nil && (!connected || conn.remoteStop) {
+		reason := core.ReasonEVDisconnected
+		if connected {
+			reason = core.ReasonRemote
+		}
+
+		conn.stopTransaction(reason)
+		conn.txn = nil
+		conn.persist()
+		stopped = true
+
+		conn.profiles.ClearTransaction(conn.id)
+	}
+
+	conn.remoteStop = false
+	conn.finished = connected && (conn.finished || stopped)
+
+	// availability change scheduled during the transaction
+	if conn.txn == nil && conn.scheduled {
+		conn.log.DEBUG.Printf("connector %d: inoperative", conn.id)
+		conn.inoperative = true
+		conn.scheduled = false
+	}
+
+	// pending remote start expires if no vehicle connects
+	if conn.remoteIdTag != "" && !connected && now.Sub(conn.remoteStart) > connectionTimeout {
+		conn.log.DEBUG.Printf("connector %d: remote start expired", conn.id)
+		conn.remoteIdTag = ""
+		conn.profiles.ClearTransaction(conn.id)
+	}
+
+	// start transaction once vehicle connected
+	if conn.txn == nil && connected && !conn.finished && !conn.inoperative {
+		idTag := conn.idTag
+		if conn.remoteIdTag != "" {
+			idTag = conn.remoteIdTag
+		}
+
+		conn.startTransaction(idTag)
+	}
+
+	// sample interval 0 disables periodic meter values
+	if conn.txn != nil && (conn.metered.IsZero() || sampleInterval > 0 && now.Sub(conn.metered) >= sampleInterval) {
+		conn.meterValues(sampledData)
+	}
+
+	// charging loadpoints share the charge point's max limit
+	conn.profiles.SetCharging(conn.id, conn.txn != nil && status == api.StatusC)
+
+	conn.limit(now)
+	conn.control()
+}
+
+// limit applies the charging profiles' effective limit to the loadpoint's max current. Lock must be held.
+func (conn *connector) limit(now time.Time) {
+	var txn *chargingprofile.Transaction
+	if conn.txn != nil && conn.txn.id != 0 {
+		txn = &chargingprofile.Transaction{ID: conn.txn.id, Start: conn.txn.started}
+	}
+
+	current := conn.lp.GetMaxCurrent()
+
+	// max current not limited or changed by the user while limited
+	if conn.applied == 0 || current != conn.applied {
+		conn.maxCurrent = current
+	}
+
+	limit, ok := conn.profiles.Limit(conn.id, now, txn)
+	if !ok {
+		if conn.applied != 0 {
+			conn.log.DEBUG.Printf("connector %d: charging profile limit removed, max current: %.1fA", conn.id, conn.maxCurrent)
+			conn.lp.SetMaxCurrent(conn.maxCurrent)
+		}
+
+		conn.applied = 0
+		conn.suspended = false
+
+		return
+	}
+
+	target := math.Min(limit.Current, conn.maxCurrent)
+
+	// loadpoint doesn't charge below min current
+	min := conn.lp.GetMinCurrent()
+	conn.suspended = target < min
+	if conn.suspended {
+		target = min
+	}
+
+	if target != current {
+		conn.log.DEBUG.Printf("connector %d: charging profile limit: %.1fA", conn.id, limit.Current)
+		conn.lp.SetMaxCurrent(target)
+	}
+
+	conn.applied = target
+}
+
+// control applies the connector's availability, remote stop and suspension by charging profiles to the loadpoint. Lock must be held.
+func (conn *connector) control() {
+	demand := loadpoint.RemoteEnable
+	if conn.inoperative || conn.finished || conn.suspended {
+		demand = loadpoint.RemoteHardDisable
+	}
+
+	if demand != conn.demand {
+		conn.demand = demand
+		conn.lp.RemoteControl(controller, demand)
+	}
+}
+
+// startTransaction queues the transaction's start message. Lock must be held.
+func (conn *connector) startTransaction(idTag string) {
+	now := conn.clock.Now()
+	meterStart := int(conn.energy)
+
+	// retry with next update
+	ref, err := conn.queue.enqueue(conn.id, 0, 0, core.NewStartTransactionRequest(conn.id, idTag, meterStart, types.NewDateTime(now)))
+	if err != nil {
+		conn.log.ERROR.Printf("connector %d: start transaction: %v", conn.id, err)
+		return
+	}
+
+	conn.txn = &transaction{
+		ref:        ref,
+		idTag:      idTag,
+		meterStart: meterStart,
+		started:    now,
+	}
+
+	conn.remoteIdTag = ""
+	conn.metered = now
+
+	conn.persist()
+}
+
+// started assigns the central system's transaction id once the start message has been delivered
+func (conn *connector) started(ref int64, id int) {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	conn.log.DEBUG.Printf("connector %d: started transaction %d", conn.id, id)
+
+	if conn.txn != nil && conn.txn.ref == ref {
+		conn.txn.id = id
+		conn.persist()
+	}
+}
+
+// stopTransaction queues the transaction's stop message. Lock must be held.
+func (conn *connector) stopTransaction(reason core.Reason) {
+	request := core.NewStopTransactionRequest(int(conn.energy), types.NewDateTime(conn.clock.Now()), conn.txn.id)
+	request.IdTag = conn.txn.idTag
+	request.Reason = reason
+
+	if _, err := conn.queue.enqueue(conn.id, conn.txn.ref, conn.txn.id, request); err != nil {
+		conn.log.ERROR.Printf("connector %d: stop transaction: %v", conn.id, err)
+		return
+	}
+
+	conn.log.DEBUG.Printf("connector %d: stop transaction: %s", conn.id, reason)
+}
+
+// status returns the connector's OCPP status. Lock must be held.
+func (conn *connector) status() core.ChargePointStatus {
+	switch status := conn.lp.GetStatus(); {
+	case conn.inoperative:
+		return core.ChargePointStatusUnavailable
+	case status == api.StatusE || status == api.StatusF:
+		return core.ChargePointStatusFaulted
+	case status == api.StatusC:
+		return core.ChargePointStatusCharging
+	case status == api.StatusB && conn.txn != nil:
+		if conn.lp.GetMode() == api.ModeOff {
+			return core.ChargePointStatusSuspendedEVSE
+		}
+		return core.ChargePointStatusSuspendedEV
+	case status == api.StatusB && conn.finished:
+		return core.ChargePointStatusFinishing
+	case status == api.StatusB || conn.remoteIdTag != "":
+		return core.ChargePointStatusPreparing
+	default:
+		return core.ChargePointStatusAvailable
+	}
+}
+
+// sendStatus sends the connector status if changed
+func (conn *connector) sendStatus(cp sender) {
+	conn.mu.Lock()
+	status := conn.status()
+	changed := status != conn.sent
+	conn.mu.Unlock()
+
+	if !changed {
+		return
+	}
+
+	conn.log.DEBUG.Printf("send: connector %d status: %s", conn.id, status)
+
+	if _, err := cp.StatusNotification(conn.id, core.NoError, status, func(request *core.StatusNotificationRequest) {
+		request.Timestamp = types.NewDateTime(conn.clock.Now())
+	}); err != nil {
+		conn.log.ERROR.Printf("connector %d: %v", conn.id, err)
+		return
+	}
+
+	conn.mu.Lock()
+	conn.sent = status
+	conn.mu.Unlock()
+}
+
+// meterValues queues the transaction's sampled measurands. Lock must be held.
+func (conn *connector) meterValues(measurands []types.Measurand) {
+	now := conn.clock.Now()
+	conn.metered = now
+
+	var sampled []types.SampledValue
+
+	for _, m := range measurands {
+		value := types.SampledValue{
+			Context:   types.ReadingContextSamplePeriodic,
+			Measurand: m,
+		}
+
+		switch m {
+		case types.MeasurandEnergyActiveImportRegister:
+			value.Value = strconv.Itoa(int(conn.energy))
+			value.Unit = types.UnitOfMeasureWh
+		case types.MeasurandPowerActiveImport:
+			value.Value = strconv.FormatFloat(conn.lp.GetChargePower(), 'f', 0, 64)
+			value.Unit = types.UnitOfMeasureW
+		default:
+			continue
+		}
+
+		sampled = append(sampled, value)
+	}
+
+	values := []types.MeterValue{{
+		Timestamp:    types.NewDateTime(now),
+		SampledValue: sampled,
+	}}
+
+	if _, err := conn.queue.enqueue(conn.id, conn.txn.ref, conn.txn.id, core.NewMeterValuesRequest(conn.id, values)); err != nil {
+		conn.log.ERROR.Printf("connector %d: meter values: %v", conn.id, err)
+	}
+
+	// energy register doesn't restart at zero after restart
+	conn.persist()
+}
+
+// prepare accepts a remote start. Returns false if the connector is busy.
+func (conn *connector) prepare(idTag string) bool {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	if conn.inoperative || conn.txn != nil {
+		return false
+	}
+
+	conn.remoteIdTag = idTag
+	conn.remoteStart = conn.clock.Now()
+	conn.finished = false
+
+	return true
+}
+
+// cancel reverts an accepted remote start before its transaction has started
+func (conn *connector) cancel() {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	conn.remoteIdTag = ""
+}
+
+// free returns true if the connector accepts a remote start. Vehicles already connected are preferred.
+func (conn *connector) free(connected bool) bool {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	status := conn.lp.GetStatus()
+
+	return !conn.inoperative && conn.txn == nil && conn.remoteIdTag == "" &&
+		(!connected || status == api.StatusB || status == api.StatusC)
+}
+
+// stop requests stopping the transaction. Returns false if the transaction is not active.
+func (conn *connector) stop(txnId int) bool {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	if conn.txn == nil || conn.txn.id != txnId {
+		return false
+	}
+
+	conn.remoteStop = true
+
+	return true
+}
+
+// setAvailability changes the connector's availability. Returns true if the change is scheduled until the active transaction stops.
+func (conn *connector) setAvailability(operative bool) bool {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	if !operative && conn.txn != nil {
+		conn.scheduled = true
+		return true
+	}
+
+	conn.inoperative = !operative
+	conn.scheduled = false
+
+	return false
+}
+
+// resendStatus forces sending the status with next update
+func (conn *connector) resendStatus() {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	conn.sent = ""
+}
+
+// resendMeterValues forces sending meter values of an active transaction with next update
+func (conn *connector) resendMeterValues() {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	conn.metered = time.Time{}
+}
@@ -0,0 +1,421 @@
+package ocpp
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/evcc-io/evcc/api"
+	"github.com/evcc-io/evcc/core/loadpoint"
+	"github.com/evcc-io/evcc/hems/ocpp/profile"
+	"github.com/evcc-io/evcc/util"
+	"github.com/evcc-io/evcc/util/chargingprofile"
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sampledData are the default sampled measurands
+var sampledData = []types.Measurand{types.MeasurandEnergyActiveImportRegister, types.MeasurandPowerActiveImport}
+
+// testLoadpoint implements the loadpoint api used by connectors
+type testLoadpoint struct {
+	loadpoint.API
+	mu     sync.Mutex
+	status api.ChargeStatus
+	mode   api.ChargeMode
+	power  float64
+	demand loadpoint.RemoteDemand
+	minCur float64
+	maxCur float64
+}
+
+func (lp *testLoadpoint) GetStatus() api.ChargeStatus {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	return lp.status
+}
+
+func (lp *testLoadpoint) GetMode() api.ChargeMode {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	return lp.mode
+}
+
+func (lp *testLoadpoint) SetMode(mode api.ChargeMode) {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	lp.mode = mode
+}
+
+func (lp *testLoadpoint) GetChargePower() float64 {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	return lp.power
+}
+
+func (lp *testLoadpoint) GetMinCurrent() float64 {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	return lp.minCur
+}
+
+func (lp *testLoadpoint) GetMaxCurrent() float64 {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	return lp.maxCur
+}
+
+func (lp *testLoadpoint) SetMaxCurrent(current float64) {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	lp.maxCur = current
+}
+
+func (lp *testLoadpoint) RemoteControl(_ string, demand loadpoint.RemoteDemand) {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	lp.demand = demand
+}
+
+func (lp *testLoadpoint) set(status api.ChargeStatus, power float64) {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	lp.status = status
+	lp.power = power
+}
+
+// testSender records the messages sent to the central system
+type testSender struct {
+	err      error // simulates failed delivery
+	txnId    int
+	status   []core.ChargePointStatus
+	starts   []*core.StartTransactionRequest
+	stops    []*core.StopTransactionRequest
+	meterVal []*core.MeterValuesRequest
+}
+
+func (s *testSender) StatusNotification(connectorId int, errorCode core.ChargePointErrorCode, status core.ChargePointStatus, props ...func(request *core.StatusNotificationRequest)) (*core.StatusNotificationConfirmation, error) {
+	s.status = append(s.status, status)
+	return core.NewStatusNotificationConfirmation(), nil
+}
+
+func (s *testSender) SendRequest(request ocpp.Request) (ocpp.Response, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	switch req := request.(type) {
+	case *core.StartTransactionRequest:
+		s.txnId++
+		s.starts = append(s.starts, req)
+		return core.NewStartTransactionConfirmation(types.NewIdTagInfo(types.AuthorizationStatusAccepted), s.txnId), nil
+	case *core.StopTransactionRequest:
+		s.stops = append(s.stops, req)
+		return core.NewStopTransactionConfirmation(), nil
+	case *core.MeterValuesRequest:
+		s.meterVal = append(s.meterVal, req)
+		return core.NewMeterValuesConfirmation(), nil
+	default:
+		return nil, fmt.Errorf("unexpected request: %s", request.GetFeatureName())
+	}
+}
+
+func newTestOCPP(lps ...*testLoadpoint) (*OCPP, *clock.Mock) {
+	clock := clock.NewMock()
+
+	s := &OCPP{
+		log:      util.NewLogger("foo"),
+		config:   profile.GetDefaultConfig(len(lps)),
+		profiles: chargingprofile.NewStore(),
+		updateC:  make(chan struct{}, 1),
+	}
+
+	queue, err := newQueue(s.log, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	queue.clock = clock
+	s.queue = queue
+
+	for id, lp := range lps {
+		conn := newConnector(s.log, id+1, lp, s.profiles, queue, defaultIdTag)
+		conn.clock = clock
+		s.connectors = append(s.connectors, conn)
+	}
+
+	return s, clock
+}
+
+// updateConnector synchronizes the connector and delivers its messages
+func updateConnector(s *OCPP, conn *connector, cp *testSender) {
+	conn.update(time.Minute, time.Minute, sampledData)
+	conn.sendStatus(cp)
+	s.flush(cp)
+}
+
+func TestConnectorTransaction(t *testing.T) {
+	lp := &testLoadpoint{status: api.StatusA, mode: api.ModePV}
+	s, clock := newTestOCPP(lp)
+	conn := s.connectors[0]
+	cp := new(testSender)
+
+	update := func() {
+		updateConnector(s, conn, cp)
+	}
+
+	update()
+	assert.Equal(t, []core.ChargePointStatus{core.ChargePointStatusAvailable}, cp.status)
+
+	// vehicle connected
+	lp.set(api.StatusB, 0)
+	update()
+	require.Len(t, cp.starts, 1)
+	assert.Equal(t, defaultIdTag, cp.starts[0].IdTag)
+	assert.Equal(t, core.ChargePointStatusSuspendedEV, cp.status[1])
+
+	// charging for an hour
+	lp.set(api.StatusC, 11e3)
+	update()
+	clock.Add(time.Hour)
+	update()
+	assert.Equal(t, core.ChargePointStatusCharging, cp.status[2])
+
+	require.Len(t, cp.meterVal, 1)
+	assert.Equal(t, 1, *cp.meterVal[0].TransactionId)
+	assert.Equal(t, "11000", cp.meterVal[0].MeterValue[0].SampledValue[0].Value)
+
+	// vehicle disconnected
+	lp.set(api.StatusA, 0)
+	update()
+	require.Len(t, cp.stops, 1)
+	assert.Equal(t, 1, cp.stops[0].TransactionId)
+	assert.Equal(t, 11000, cp.stops[0].MeterStop)
+	assert.Equal(t, core.ReasonEVDisconnected, cp.stops[0].Reason)
+	assert.Equal(t, core.ChargePointStatusAvailable, cp.status[3])
+}
+
+func TestConnectorRemoteStartStop(t *testing.T) {
+	lp := &testLoadpoint{status: api.StatusA, mode: api.ModeOff}
+	s, _ := newTestOCPP(lp, &testLoadpoint{status: api.StatusA})
+	conn := s.connectors[0]
+	cp := new(testSender)
+
+	update := func() {
+		updateConnector(s, conn, cp)
+	}
+
+	// unknown connector
+	id := 3
+	assert.False(t, s.RemoteStart(&id, "card", nil))
+
+	// remote start with tx profile enables charging
+	profile := &types.ChargingProfile{
+		ChargingProfileId:      1,
+		ChargingProfilePurpose: types.ChargingProfilePurposeTxProfile,
+		ChargingProfileKind:    types.ChargingProfileKindRelative,
+		ChargingSchedule:       types.NewChargingSchedule(types.ChargingRateUnitAmperes, types.NewChargingSchedulePeriod(0, 10)),
+	}
+
+	id = 1
+	require.True(t, s.RemoteStart(&id, "card", profile))
+	assert.Equal(t, api.ModeNow, lp.GetMode())
+	assert.Len(t, s.profiles.Profiles(1), 1)
+
+	update()
+	assert.Equal(t, []core.ChargePointStatus{core.ChargePointStatusPreparing}, cp.status)
+
+	// connector busy
+	assert.False(t, s.RemoteStart(&id, "other", nil))
+
+	lp.set(api.StatusC, 0)
+	update()
+	require.Len(t, cp.starts, 1)
+	assert.Equal(t, "card", cp.starts[0].IdTag)
+
+	// remote stop disables charging while vehicle remains connected, keeping the user's mode
+	assert.False(t, s.RemoteStop(2))
+	require.True(t, s.RemoteStop(1))
+
+	lp.set(api.StatusB, 0)
+	update()
+	require.Len(t, cp.stops, 1)
+	assert.Equal(t, core.ReasonRemote, cp.stops[0].Reason)
+	assert.Equal(t, core.ChargePointStatusFinishing, cp.status[len(cp.status)-1])
+	assert.Empty(t, s.profiles.Profiles(1))
+	assert.Equal(t, api.ModeNow, lp.GetMode())
+	assert.Equal(t, loadpoint.RemoteHardDisable, lp.demand)
+
+	// no new transaction until vehicle disconnected
+	update()
+	assert.Len(t, cp.starts, 1)
+
+	lp.set(api.StatusA, 0)
+	update()
+	assert.Equal(t, loadpoint.RemoteEnable, lp.demand)
+
+	lp.set(api.StatusB, 0)
+	update()
+	assert.Len(t, cp.starts, 2)
+}
+
+func TestConnectorRemoteStartExpiry(t *testing.T) {
+	s, clock := newTestOCPP(&testLoadpoint{status: api.StatusA, mode: api.ModePV})
+	conn := s.connectors[0]
+	cp := new(testSender)
+
+	// any connector
+	require.True(t, s.RemoteStart(nil, "card", nil))
+	updateConnector(s, conn, cp)
+
+	clock.Add(2 * time.Minute)
+	updateConnector(s, conn, cp)
+
+	assert.Equal(t, []core.ChargePointStatus{core.ChargePointStatusPreparing, core.ChargePointStatusAvailable}, cp.status)
+	assert.Empty(t, cp.starts)
+}
+
+func TestChangeAvailability(t *testing.T) {
+	lp1, lp2 := &testLoadpoint{status: api.StatusA}, &testLoadpoint{status: api.StatusA}
+	s, _ := newTestOCPP(lp1, lp2)
+	cp := new(testSender)
+
+	assert.Equal(t, core.AvailabilityStatusRejected, s.ChangeAvailability(3, false))
+
+	require.Equal(t, core.AvailabilityStatusAccepted, s.ChangeAvailability(0, false))
+	assert.False(t, s.RemoteStart(nil, "card", nil))
+
+	// no transaction while inoperative
+	lp1.set(api.StatusB, 0)
+	for _, conn := range s.connectors {
+		updateConnector(s, conn, cp)
+	}
+	assert.Equal(t, loadpoint.RemoteHardDisable, lp1.demand)
+	assert.Equal(t, loadpoint.RemoteHardDisable, lp2.demand)
+	assert.Empty(t, cp.starts)
+	assert.Equal(t, core.ChargePointStatusUnavailable, cp.status[0])
+
+	require.Equal(t, core.AvailabilityStatusAccepted, s.ChangeAvailability(1, true))
+	for _, conn := range s.connectors {
+		updateConnector(s, conn, cp)
+	}
+	assert.Equal(t, loadpoint.RemoteEnable, lp1.demand)
+	assert.Equal(t, loadpoint.RemoteHardDisable, lp2.demand)
+	assert.Len(t, cp.starts, 1)
+
+	// active transaction continues until the vehicle disconnects
+	lp1.set(api.StatusC, 11e3)
+	require.Equal(t, core.AvailabilityStatusScheduled, s.ChangeAvailability(0, false))
+	updateConnector(s, s.connectors[0], cp)
+	assert.Equal(t, loadpoint.RemoteEnable, lp1.demand)
+	assert.Equal(t, core.ChargePointStatusCharging, cp.status[len(cp.status)-1])
+
+	lp1.set(api.StatusA, 0)
+	updateConnector(s, s.connectors[0], cp)
+	require.Len(t, cp.stops, 1)
+	assert.Equal(t, loadpoint.RemoteHardDisable, lp1.demand)
+	assert.Equal(t, core.ChargePointStatusUnavailable, cp.status[len(cp.status)-1])
+}
+
+func TestConnectorLimit(t *testing.T) {
+	lp := &testLoadpoint{status: api.StatusC, mode: api.ModeNow, minCur: 6, maxCur: 16}
+	s, clock := newTestOCPP(lp)
+	conn := s.connectors[0]
+	cp := new(testSender)
+
+	update := func() {
+		updateConnector(s, conn, cp)
+	}
+
+	update()
+	assert.Equal(t, 16.0, lp.GetMaxCurrent())
+
+	// charge point max profile limits all connectors: 10A for 1h, then 4A for 1h
+	start := types.NewDateTime(clock.Now())
+	schedule := types.NewChargingSchedule(types.ChargingRateUnitAmperes,
+		types.NewChargingSchedulePeriod(0, 10),
+		types.NewChargingSchedulePeriod(3600, 4),
+	)
+	duration := 7200
+	schedule.StartSchedule = start
+	schedule.Duration = &duration
+
+	require.NoError(t, s.profiles.Set(0, &types.ChargingProfile{
+		ChargingProfileId:      1,
+		ChargingProfilePurpose: types.ChargingProfilePurposeChargePointMaxProfile,
+		ChargingProfileKind:    types.ChargingProfileKindAbsolute,
+		ChargingSchedule:       schedule,
+	}))
+
+	update()
+	assert.Equal(t, 10.0, lp.GetMaxCurrent())
+	assert.Equal(t, loadpoint.RemoteEnable, lp.demand)
+
+	// max current changed by user while limited
+	lp.SetMaxCurrent(8)
+	update()
+	assert.Equal(t, 8.0, lp.GetMaxCurrent())
+
+	// below min current charging is suspended
+	clock.Add(time.Hour)
+	update()
+	assert.Equal(t, 6.0, lp.GetMaxCurrent())
+	assert.Equal(t, loadpoint.RemoteHardDisable, lp.demand)
+
+	// profile expired
+	clock.Add(time.Hour)
+	update()
+	assert.Equal(t, 8.0, lp.GetMaxCurrent())
+	assert.Equal(t, loadpoint.RemoteEnable, lp.demand)
+}
+
+func TestConnectorSampledData(t *testing.T) {
+	lp := &testLoadpoint{status: api.StatusC, power: 11e3, mode: api.ModeNow}
+	s, clock := newTestOCPP(lp)
+	conn := s.connectors[0]
+	cp := new(testSender)
+
+	conn.update(time.Minute, time.Minute, sampledData)
+	clock.Add(time.Minute)
+
+	// only configured measurands are sampled
+	conn.update(time.Minute, time.Minute, []types.Measurand{types.MeasurandPowerActiveImport})
+	s.flush(cp)
+
+	require.Len(t, cp.meterVal, 1)
+	require.Len(t, cp.meterVal[0].MeterValue[0].SampledValue, 1)
+	assert.Equal(t, types.MeasurandPowerActiveImport, cp.meterVal[0].MeterValue[0].SampledValue[0].Measurand)
+	assert.Equal(t, "11000", cp.meterVal[0].MeterValue[0].SampledValue[0].Value)
+}
+
+func TestConnectorSharedMaxLimit(t *testing.T) {
+	lp1 := &testLoadpoint{status: api.StatusC, mode: api.ModeNow, minCur: 6, maxCur: 32}
+	lp2 := &testLoadpoint{status: api.StatusC, mode: api.ModeNow, minCur: 6, maxCur: 32}
+	s, clock := newTestOCPP(lp1, lp2)
+	cp := new(testSender)
+
+	schedule := types.NewChargingSchedule(types.ChargingRateUnitAmperes, types.NewChargingSchedulePeriod(0, 32))
+	schedule.StartSchedule = types.NewDateTime(clock.Now())
+
+	require.NoError(t, s.profiles.Set(0, &types.ChargingProfile{
+		ChargingProfileId:      1,
+		ChargingProfilePurpose: types.ChargingProfilePurposeChargePointMaxProfile,
+		ChargingProfileKind:    types.ChargingProfileKindAbsolute,
+		ChargingSchedule:       schedule,
+	}))
+
+	// both loadpoints together stay within the charge point's limit
+	for i := 0; i < 2; i++ {
+		for _, conn := range s.connectors {
+			updateConnector(s, conn, cp)
+		}
+	}
+
+	assert.Equal(t, 16.0, lp1.GetMaxCurrent())
+	assert.Equal(t, 16.0, lp2.GetMaxCurrent())
+}
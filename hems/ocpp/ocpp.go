@@ -3,34 +3,73 @@ package ocpp
 import (
 	"fmt"
 	"math/rand"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/evcc-io/evcc/api"
 	"github.com/evcc-io/evcc/core/site"
 	"github.com/evcc-io/evcc/hems/ocpp/profile"
+	"github.com/evcc-io/evcc/server"
+	serverdb "github.com/evcc-io/evcc/server/db"
 	"github.com/evcc-io/evcc/util"
+	"github.com/evcc-io/evcc/util/chargingprofile"
 	"github.com/evcc-io/evcc/util/machine"
 
 	ocpp16 "github.com/lorenzodonini/ocpp-go/ocpp1.6"
 	ocppcore "github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/firmware"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/localauth"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/remotetrigger"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/reservation"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/smartcharging"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
 	"github.com/lorenzodonini/ocpp-go/ws"
 )
 
 // OCPP is an OCPP client
 type OCPP struct {
-	log  *util.Logger
-	site site.API
-	cp   ocpp16.ChargePoint
+	log        *util.Logger
+	site       site.API
+	uri        string
+	cp         ocpp16.ChargePoint
+	config     *profile.ConfigMap
+	profiles   *chargingprofile.Store
+	queue      *queue
+	connectors []*connector
+
+	mu        sync.Mutex
+	connected bool
+
+	updateC    chan struct{}
+	triggerC   chan remotetrigger.MessageTrigger
+	heartbeat  *time.Ticker
+	heartbeatC <-chan time.Time
+	interval   time.Duration // heartbeat interval
 }
 
-const retryTimeout = 5 * time.Second
+const (
+	retryTimeout   = 5 * time.Second
+	maxBackoff     = 2 * time.Minute
+	updateInterval = time.Second
+
+	defaultIdTag = "evcc"
+	controller   = "ocpp"
+
+	chargePointVendor = "evcc.io"
+	chargePointModel  = "evcc"
+)
 
 // New generates OCPP chargepoint client
 func New(conf map[string]interface{}, site site.API) (*OCPP, error) {
-	var cc struct {
+	cc := struct {
 		URI       string
 		StationID string
+		IdTag     string
+	}{
+		IdTag: defaultIdTag,
 	}
 
 	if err := util.DecodeOther(conf, &cc); err != nil {
@@ -49,25 +88,58 @@ func New(conf map[string]interface{}, site site.API) (*OCPP, error) {
 		log.DEBUG.Println("station id:", cc.StationID)
 	}
 
+	queue, err := newQueue(log, serverdb.Instance)
+	if err != nil {
+		return nil, err
+	}
+
+	// configuration changed by the central system
+	config := profile.GetDefaultConfig(len(site.Loadpoints()))
+	config.Restore()
+
+	// pong timeout must exceed the ping interval
+	timeouts := ws.NewClientTimeoutConfig()
+	if ping := config.Duration(profile.WebSocketPingInterval); ping > 0 {
+		timeouts.PingPeriod = ping
+		timeouts.PongWait = ping * 10 / 9
+	}
+
 	ws := ws.NewClient()
-	cp := ocpp16.NewChargePoint(cc.StationID, nil, ws)
+	ws.SetTimeoutConfig(timeouts)
+
+	// own endpoint for observing the connection, the websocket client reconnects with backoff
+	dispatcher := ocppj.NewDefaultClientDispatcher(ocppj.NewFIFOClientQueue(0))
+	endpoint := ocppj.NewClient(cc.StationID, ws, dispatcher, nil, ocppcore.Profile, localauth.Profile, firmware.Profile, reservation.Profile, remotetrigger.Profile, smartcharging.Profile)
+	cp := ocpp16.NewChargePoint(cc.StationID, endpoint, ws)
 
 	s := &OCPP{
-		log:  log,
-		site: site,
-		cp:   cp,
+		log:      log,
+		site:     site,
+		uri:      cc.URI,
+		cp:       cp,
+		config:   config,
+		profiles: chargingprofile.NewStore(),
+		queue:    queue,
+		updateC:  make(chan struct{}, 1),
+		triggerC: make(chan remotetrigger.MessageTrigger, 1),
 	}
 
-	err := cp.Start(cc.URI)
-	if err == nil {
-		cp.SetCoreHandler(profile.NewCore(log, profile.GetDefaultConfig()))
-		cp.SetSmartChargingHandler(profile.NewSmartCharging(log))
-
-		go s.errorHandler(ws.Errors())
-		go s.errorHandler(cp.Errors())
+	// each loadpoint is a connector
+	for id, lp := range site.Loadpoints() {
+		s.connectors = append(s.connectors, newConnector(log, id+1, lp, s.profiles, queue, cc.IdTag))
 	}
 
-	return s, err
+	endpoint.SetOnDisconnectedHandler(s.disconnected)
+	endpoint.SetOnReconnectedHandler(s.reconnected)
+
+	cp.SetCoreHandler(profile.NewCore(log, s.config, s))
+	cp.SetSmartChargingHandler(profile.NewSmartCharging(log, s.profiles, len(s.connectors), s.requestUpdate))
+	cp.SetRemoteTriggerHandler(profile.NewRemoteTrigger(log, s))
+
+	go s.errorHandler(ws.Errors())
+	go s.errorHandler(cp.Errors())
+
+	return s, nil
 }
 
 // errorHandler logs error channel
@@ -79,21 +151,327 @@ func (s *OCPP) errorHandler(errC <-chan error) {
 
 // Run executes the OCPP chargepoint client
 func (s *OCPP) Run() {
+	s.connect()
+	s.boot()
+
+	ticker := time.NewTicker(updateInterval)
+	defer ticker.Stop()
+
 	for {
-		for id, lp := range s.site.Loadpoints() {
-			connector := id + 1
+		s.update()
+		s.updateHeartbeat()
 
-			status := ocppcore.ChargePointStatusAvailable
-			if lp.GetStatus() == api.StatusC {
-				status = ocppcore.ChargePointStatusCharging
-			}
+		select {
+		case <-ticker.C:
+		case <-s.updateC:
+		case <-s.heartbeatC:
+			s.sendHeartbeat()
+		case msg := <-s.triggerC:
+			s.trigger(msg)
+		}
+	}
+}
+
+// connect establishes the initial connection to the central system with backoff
+func (s *OCPP) connect() {
+	for backoff := retryTimeout; ; {
+		err := s.cp.Start(s.uri)
+		if err == nil {
+			break
+		}
+
+		s.log.ERROR.Printf("connect: %v, retry in %v", err, backoff)
+		time.Sleep(backoff)
+
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+
+	s.mu.Lock()
+	s.connected = true
+	s.mu.Unlock()
+}
+
+// disconnected is called when the connection to the central system is lost
+func (s *OCPP) disconnected(err error) {
+	s.log.WARN.Printf("disconnected: %v", err)
+
+	s.mu.Lock()
+	s.connected = false
+	s.mu.Unlock()
+}
+
+// reconnected is called when the connection to the central system has been restored
+func (s *OCPP) reconnected() {
+	s.log.DEBUG.Println("reconnected")
+
+	s.mu.Lock()
+	s.connected = true
+	s.mu.Unlock()
+
+	// status may have changed while offline
+	for _, conn := range s.connectors {
+		conn.resendStatus()
+	}
+
+	s.queue.resume()
+	s.requestUpdate()
+}
+
+// online returns true if connected to the central system
+func (s *OCPP) online() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.connected
+}
+
+// update synchronizes the connectors and delivers their messages while online
+func (s *OCPP) update() {
+	for _, conn := range s.connectors {
+		conn.update(s.config.Duration(profile.MeterValueSampleInterval), s.config.Duration(profile.ConnectionTimeOut), s.config.Measurands(profile.MeterValuesSampledData))
+	}
+
+	if !s.online() {
+		return
+	}
+
+	for _, conn := range s.connectors {
+		conn.sendStatus(s.cp)
+	}
+
+	s.flush(s.cp)
+}
+
+// flush delivers the queued transaction messages
+func (s *OCPP) flush(cp requester) {
+	s.queue.flush(cp, s.config.Int(profile.TransactionMessageAttempts), s.config.Duration(profile.TransactionMessageRetryInterval), s.started)
+}
+
+// started assigns the transaction id to the connector's transaction
+func (s *OCPP) started(id int, ref int64, txnId int) {
+	if conn := s.connector(id); conn != nil {
+		conn.started(ref, txnId)
+	}
+}
+
+// boot registers the charge point with the central system and applies the heartbeat interval
+func (s *OCPP) boot() {
+	for {
+		res, err := s.cp.BootNotification(chargePointModel, chargePointVendor, func(request *ocppcore.BootNotificationRequest) {
+			request.FirmwareVersion = server.Version
+		})
+
+		interval := retryTimeout
+		if err == nil && res.Interval > 0 {
+			interval = time.Duration(res.Interval) * time.Second
+		}
+
+		switch {
+		case err != nil:
+			s.log.ERROR.Printf("boot: %v", err)
+		case res.Status == ocppcore.RegistrationStatusAccepted:
+			s.log.DEBUG.Printf("boot accepted, heartbeat interval: %v", interval)
+			s.config.Update(profile.HeartbeatInterval, strconv.Itoa(int(interval.Seconds())))
+			return
+		default:
+			s.log.WARN.Printf("boot: %s", res.Status)
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+// updateHeartbeat restarts the heartbeat once its interval has been changed by the central system
+func (s *OCPP) updateHeartbeat() {
+	interval := s.config.Duration(profile.HeartbeatInterval)
+	if interval == s.interval {
+		return
+	}
+
+	s.interval = interval
 
-			s.log.DEBUG.Printf("send: lp-%d status: %+v", connector, status)
-			if _, err := s.cp.StatusNotification(connector, ocppcore.NoError, status); err != nil {
-				s.log.ERROR.Printf("lp-%d: %v", connector, err)
+	if s.heartbeat != nil {
+		s.heartbeat.Stop()
+		s.heartbeat, s.heartbeatC = nil, nil
+	}
+
+	if interval > 0 {
+		s.log.DEBUG.Printf("heartbeat interval: %v", interval)
+		s.heartbeat = time.NewTicker(interval)
+		s.heartbeatC = s.heartbeat.C
+	}
+}
+
+// sendHeartbeat sends the heartbeat
+func (s *OCPP) sendHeartbeat() {
+	if !s.online() {
+		return
+	}
+
+	if _, err := s.cp.Heartbeat(); err != nil {
+		s.log.ERROR.Printf("heartbeat: %v", err)
+	}
+}
+
+// trigger sends the charge point's messages requested by the central system
+func (s *OCPP) trigger(msg remotetrigger.MessageTrigger) {
+	switch msg {
+	case ocppcore.BootNotificationFeatureName:
+		if _, err := s.cp.BootNotification(chargePointModel, chargePointVendor, func(request *ocppcore.BootNotificationRequest) {
+			request.FirmwareVersion = server.Version
+		}); err != nil {
+			s.log.ERROR.Printf("boot: %v", err)
+		}
+	case ocppcore.HeartbeatFeatureName:
+		s.sendHeartbeat()
+	}
+}
+
+// connector returns the connector or nil if the id is invalid
+func (s *OCPP) connector(id int) *connector {
+	if id < 1 || id > len(s.connectors) {
+		return nil
+	}
+
+	return s.connectors[id-1]
+}
+
+// requestUpdate wakes the loop to apply remote commands immediately
+func (s *OCPP) requestUpdate() {
+	select {
+	case s.updateC <- struct{}{}:
+	default:
+	}
+}
+
+var _ profile.ChargePoint = (*OCPP)(nil)
+
+// RemoteStart implements the profile.ChargePoint interface
+func (s *OCPP) RemoteStart(id *int, idTag string, chargingProfile *types.ChargingProfile) bool {
+	var conn *connector
+
+	if id != nil {
+		if conn = s.connector(*id); conn == nil || !conn.free(false) {
+			return false
+		}
+	} else {
+		// prefer connectors with vehicle connected
+		for _, connected := range []bool{true, false} {
+			for _, c := range s.connectors {
+				if conn == nil && c.free(connected) {
+					conn = c
+				}
 			}
 		}
+	}
 
-		time.Sleep(retryTimeout)
+	if conn == nil {
+		return false
 	}
+
+	if chargingProfile != nil && chargingProfile.ChargingProfilePurpose != types.ChargingProfilePurposeTxProfile {
+		return false
+	}
+
+	if !conn.prepare(idTag) {
+		return false
+	}
+
+	if chargingProfile != nil {
+		if err := s.profiles.Set(conn.id, chargingProfile); err != nil {
+			s.log.ERROR.Printf("connector %d: remote start: %v", conn.id, err)
+			conn.cancel()
+			return false
+		}
+	}
+
+	s.log.DEBUG.Printf("connector %d: remote start: %s", conn.id, idTag)
+
+	// loadpoint doesn't charge while off
+	if conn.lp.GetMode() == api.ModeOff {
+		conn.lp.SetMode(api.ModeNow)
+	}
+
+	s.requestUpdate()
+
+	return true
+}
+
+// RemoteStop implements the profile.ChargePoint interface
+func (s *OCPP) RemoteStop(txnId int) bool {
+	for _, conn := range s.connectors {
+		if conn.stop(txnId) {
+			s.log.DEBUG.Printf("connector %d: remote stop: %d", conn.id, txnId)
+			s.requestUpdate()
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// ChangeAvailability implements the profile.ChargePoint interface
+func (s *OCPP) ChangeAvailability(id int, operative bool) ocppcore.AvailabilityStatus {
+	conns := s.connectors
+	if id != 0 {
+		conn := s.connector(id)
+		if conn == nil {
+			return ocppcore.AvailabilityStatusRejected
+		}
+
+		conns = []*connector{conn}
+	}
+
+	// connectors with active transaction change once the transaction stops
+	status := ocppcore.AvailabilityStatusAccepted
+	for _, conn := range conns {
+		if conn.setAvailability(operative) {
+			status = ocppcore.AvailabilityStatusScheduled
+		}
+	}
+
+	s.requestUpdate()
+
+	return status
+}
+
+// Trigger implements the profile.ChargePoint interface
+func (s *OCPP) Trigger(msg remotetrigger.MessageTrigger, id *int) remotetrigger.TriggerMessageStatus {
+	conns := s.connectors
+	if id != nil && *id != 0 {
+		conn := s.connector(*id)
+		if conn == nil {
+			return remotetrigger.TriggerMessageStatusRejected
+		}
+
+		conns = []*connector{conn}
+	}
+
+	switch msg {
+	case ocppcore.StatusNotificationFeatureName:
+		for _, conn := range conns {
+			conn.resendStatus()
+		}
+		s.requestUpdate()
+
+	case ocppcore.MeterValuesFeatureName:
+		for _, conn := range conns {
+			conn.resendMeterValues()
+		}
+		s.requestUpdate()
+
+	case ocppcore.BootNotificationFeatureName, ocppcore.HeartbeatFeatureName:
+		select {
+		case s.triggerC <- msg:
+		default:
+		}
+
+	default:
+		return remotetrigger.TriggerMessageStatusNotImplemented
+	}
+
+	return remotetrigger.TriggerMessageStatusAccepted
 }
@@ -2,9 +2,17 @@ package profile
 
 import (
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/evcc-io/evcc/server/db/settings"
 	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/remotetrigger"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/smartcharging"
 	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
 )
 
 // modified from https://github.com/lorenzodonini/ocpp-go/tree/master/example/1.6/cp
@@ -42,94 +50,218 @@ const (
 	MaxChargingProfilesInstalled            string = "MaxChargingProfilesInstalled"
 )
 
+// kind is the configuration value's type
+type kind int
+
+const (
+	kindString kind = iota
+	kindBool
+	kindInt
+	kindMeasurands // comma separated list of measurands
+)
+
+// settingsPrefix is the settings key prefix of persisted configuration values
+const settingsPrefix = "ocpp.config."
+
+// measurands are the measurands supported in meter values
+var measurands = []types.Measurand{types.MeasurandEnergyActiveImportRegister, types.MeasurandPowerActiveImport}
+
+// rebootRequired are the configuration keys applied at startup only
+var rebootRequired = []string{WebSocketPingInterval}
+
+// option is a configuration key with its value type
+type option struct {
+	core.ConfigurationKey
+	kind kind
+}
+
 // ConfigMap defines the active configuration settings
-type ConfigMap map[string]core.ConfigurationKey
-
-// func (c ConfigMap) updateInt(key string, i int64) {
-// 	configKey, ok := c[key]
-// 	if ok {
-// 		configKey.Value = strconv.FormatInt(i, 10)
-// 	}
-// 	c[key] = configKey
-// }
-
-// func (c ConfigMap) updateBool(key string, b bool) {
-// 	configKey, ok := c[key]
-// 	if ok {
-// 		configKey.Value = strconv.FormatBool(b)
-// 	}
-// 	c[key] = configKey
-// }
-
-// func (c ConfigMap) getInt(key string) (int, bool) {
-// 	configKey, ok := c[key]
-// 	if !ok {
-// 		return 0, ok
-// 	}
-// 	result, err := strconv.ParseInt(configKey.Value, 10, 32)
-// 	if err != nil {
-// 		return 0, false
-// 	}
-// 	return int(result), true
-// }
-
-// func (c ConfigMap) getBool(key string) (bool, bool) {
-// 	configKey, ok := c[key]
-// 	if !ok {
-// 		return false, ok
-// 	}
-// 	result, err := strconv.ParseBool(configKey.Value)
-// 	if err != nil {
-// 		return false, false
-// 	}
-// 	return result, true
-// }
-
-func (c ConfigMap) set(key string, readonly bool, value string) {
-	c[key] = core.ConfigurationKey{
-		Key:      key,
-		Readonly: readonly,
-		Value:    &value,
+type ConfigMap struct {
+	mu      sync.Mutex
+	options map[string]option
+}
+
+func (c *ConfigMap) set(key string, readonly bool, kind kind, value string) {
+	c.options[key] = option{
+		ConfigurationKey: core.ConfigurationKey{
+			Key:      key,
+			Readonly: readonly,
+			Value:    &value,
+		},
+		kind: kind,
+	}
+}
+
+// valid returns true if the value matches the option's type
+func (o option) valid(value string) bool {
+	switch o.kind {
+	case kindBool:
+		_, err := strconv.ParseBool(value)
+		return err == nil
+	case kindInt:
+		i, err := strconv.Atoi(value)
+		return err == nil && i >= 0
+	case kindMeasurands:
+		for _, m := range strings.Split(value, ",") {
+			if !slices.Contains(measurands, types.Measurand(strings.TrimSpace(m))) {
+				return false
+			}
+		}
+		return true
+	default:
+		return true
 	}
 }
 
-func GetDefaultConfig() ConfigMap {
+// Get returns the requested configuration keys and the unknown keys. Without keys, all configuration keys are returned.
+func (c *ConfigMap) Get(keys []string) ([]core.ConfigurationKey, []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(keys) == 0 {
+		keys = maps.Keys(c.options)
+		slices.Sort(keys)
+	}
+
+	var res []core.ConfigurationKey
+	var unknown []string
+
+	for _, key := range keys {
+		if o, ok := c.options[key]; ok {
+			res = append(res, o.ConfigurationKey)
+		} else {
+			unknown = append(unknown, key)
+		}
+	}
+
+	return res, unknown
+}
+
+// Set validates and persists the configuration value
+func (c *ConfigMap) Set(key, value string) core.ConfigurationStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	o, ok := c.options[key]
+	switch {
+	case !ok:
+		return core.ConfigurationStatusNotSupported
+	case o.Readonly || !o.valid(value):
+		return core.ConfigurationStatusRejected
+	}
+
+	c.set(key, false, o.kind, value)
+	settings.SetString(settingsPrefix+key, value)
+
+	if slices.Contains(rebootRequired, key) {
+		return core.ConfigurationStatusRebootRequired
+	}
+
+	return core.ConfigurationStatusAccepted
+}
+
+// Update changes the configuration value as requested by the central system outside of configuration messages.
+// Writable values are persisted like configuration changes.
+func (c *ConfigMap) Update(key, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if o, ok := c.options[key]; ok {
+		c.set(key, o.Readonly, o.kind, value)
+
+		if !o.Readonly {
+			settings.SetString(settingsPrefix+key, value)
+		}
+	}
+}
+
+// Restore applies the persisted configuration values
+func (c *ConfigMap) Restore() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, o := range c.options {
+		if value, err := settings.String(settingsPrefix + key); err == nil && !o.Readonly && o.valid(value) {
+			c.set(key, false, o.kind, value)
+		}
+	}
+}
+
+// Int returns the configuration key's integer value
+func (c *ConfigMap) Int(key string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if o, ok := c.options[key]; ok && o.Value != nil {
+		if val, err := strconv.Atoi(*o.Value); err == nil {
+			return val
+		}
+	}
+
+	return 0
+}
+
+// Duration returns the configuration key's value in seconds
+func (c *ConfigMap) Duration(key string) time.Duration {
+	return time.Duration(c.Int(key)) * time.Second
+}
+
+// Measurands returns the configuration key's measurands
+func (c *ConfigMap) Measurands(key string) []types.Measurand {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	o, ok := c.options[key]
+	if !ok || o.kind != kindMeasurands || o.Value == nil {
+		return nil
+	}
+
+	var res []types.Measurand
+	for _, m := range strings.Split(*o.Value, ",") {
+		res = append(res, types.Measurand(strings.TrimSpace(m)))
+	}
+
+	return res
+}
+
+// GetDefaultConfig returns the default configuration of a charge point with given number of connectors
+func GetDefaultConfig(connectors int) *ConfigMap {
 	intBase := 10
 
-	var cfg ConfigMap = make(map[string]core.ConfigurationKey)
+	cfg := &ConfigMap{options: make(map[string]option)}
 
 	// readonly
-	cfg.set(SupportedFeatureProfiles, true, core.ProfileName)
-	cfg.set(AuthorizeRemoteTxRequests, true, strconv.FormatBool(false))
-	cfg.set(GetConfigurationMaxKeys, true, strconv.FormatInt(50, intBase))
-	cfg.set(NumberOfConnectors, true, strconv.FormatInt(1, intBase))
-	cfg.set(LocalAuthListMaxLength, true, strconv.FormatInt(100, intBase))
-	cfg.set(SendLocalListMaxLength, true, strconv.FormatInt(20, intBase))
-	cfg.set(ChargeProfileMaxStackLevel, true, strconv.FormatInt(10, intBase))
-	cfg.set(ChargingScheduleAllowedChargingRateUnit, true, "Power")
-	cfg.set(ChargingScheduleMaxPeriods, true, strconv.FormatInt(5, intBase))
-	cfg.set(MaxChargingProfilesInstalled, true, strconv.FormatInt(10, intBase))
+	cfg.set(SupportedFeatureProfiles, true, kindString, strings.Join([]string{core.ProfileName, smartcharging.ProfileName, remotetrigger.ProfileName}, ","))
+	cfg.set(AuthorizeRemoteTxRequests, true, kindBool, strconv.FormatBool(false))
+	cfg.set(GetConfigurationMaxKeys, true, kindInt, strconv.FormatInt(50, intBase))
+	cfg.set(NumberOfConnectors, true, kindInt, strconv.Itoa(connectors))
+	cfg.set(LocalAuthListMaxLength, true, kindInt, strconv.FormatInt(100, intBase))
+	cfg.set(SendLocalListMaxLength, true, kindInt, strconv.FormatInt(20, intBase))
+	cfg.set(ChargeProfileMaxStackLevel, true, kindInt, strconv.FormatInt(10, intBase))
+	cfg.set(ChargingScheduleAllowedChargingRateUnit, true, kindString, "Power")
+	cfg.set(ChargingScheduleMaxPeriods, true, kindInt, strconv.FormatInt(5, intBase))
+	cfg.set(MaxChargingProfilesInstalled, true, kindInt, strconv.FormatInt(10, intBase))
 
 	// read/write
-	cfg.set(ClockAlignedDataInterval, false, strconv.FormatInt(0, intBase))
-	cfg.set(ConnectionTimeOut, false, strconv.FormatInt(60, intBase))
-	cfg.set(ConnectorPhaseRotation, false, "Unknown")
-	cfg.set(HeartbeatInterval, false, strconv.FormatInt(86400, intBase))
-	cfg.set(LocalAuthorizeOffline, false, strconv.FormatBool(true))
-	cfg.set(LocalAuthListEnabled, false, strconv.FormatBool(true))
-	cfg.set(LocalPreAuthorize, false, strconv.FormatBool(false))
-	cfg.set(MeterValuesAlignedData, false, string(types.MeasurandEnergyActiveExportRegister))
-	cfg.set(MeterValuesSampledData, false, string(types.MeasurandEnergyActiveExportRegister))
-	cfg.set(MeterValueSampleInterval, false, strconv.FormatInt(5, intBase))
-	cfg.set(ResetRetries, false, strconv.FormatInt(10, intBase))
-	cfg.set(StopTransactionOnEVSideDisconnect, false, strconv.FormatBool(true))
-	cfg.set(StopTransactionOnInvalidID, false, strconv.FormatBool(true))
-	cfg.set(StopTxnAlignedData, false, strconv.FormatBool(true))
-	cfg.set(StopTxnSampledData, false, string(types.MeasurandEnergyActiveExportRegister))
-	cfg.set(TransactionMessageAttempts, false, strconv.FormatInt(5, intBase))
-	cfg.set(TransactionMessageRetryInterval, false, strconv.FormatInt(60, intBase))
-	cfg.set(UnlockConnectorOnEVSideDisconnect, false, strconv.FormatBool(true))
-	cfg.set(WebSocketPingInterval, false, strconv.FormatInt(54, intBase))
+	cfg.set(ClockAlignedDataInterval, false, kindInt, strconv.FormatInt(0, intBase))
+	cfg.set(ConnectionTimeOut, false, kindInt, strconv.FormatInt(60, intBase))
+	cfg.set(ConnectorPhaseRotation, false, kindString, "Unknown")
+	cfg.set(HeartbeatInterval, false, kindInt, strconv.FormatInt(86400, intBase))
+	cfg.set(LocalAuthorizeOffline, false, kindBool, strconv.FormatBool(true))
+	cfg.set(LocalAuthListEnabled, false, kindBool, strconv.FormatBool(true))
+	cfg.set(LocalPreAuthorize, false, kindBool, strconv.FormatBool(false))
+	cfg.set(MeterValuesAlignedData, false, kindMeasurands, string(types.MeasurandEnergyActiveImportRegister))
+	cfg.set(MeterValuesSampledData, false, kindMeasurands, strings.Join([]string{string(types.MeasurandEnergyActiveImportRegister), string(types.MeasurandPowerActiveImport)}, ","))
+	cfg.set(MeterValueSampleInterval, false, kindInt, strconv.FormatInt(5, intBase))
+	cfg.set(ResetRetries, false, kindInt, strconv.FormatInt(10, intBase))
+	cfg.set(StopTransactionOnEVSideDisconnect, false, kindBool, strconv.FormatBool(true))
+	cfg.set(StopTransactionOnInvalidID, false, kindBool, strconv.FormatBool(true))
+	cfg.set(StopTxnAlignedData, false, kindMeasurands, string(types.MeasurandEnergyActiveImportRegister))
+	cfg.set(StopTxnSampledData, false, kindMeasurands, string(types.MeasurandEnergyActiveImportRegister))
+	cfg.set(TransactionMessageAttempts, false, kindInt, strconv.FormatInt(5, intBase))
+	cfg.set(TransactionMessageRetryInterval, false, kindInt, strconv.FormatInt(60, intBase))
+	cfg.set(UnlockConnectorOnEVSideDisconnect, false, kindBool, strconv.FormatBool(true))
+	cfg.set(WebSocketPingInterval, false, kindInt, strconv.FormatInt(54, intBase))
 
 	return cfg
 }
@@ -0,0 +1,73 @@
+package profile
+
+import (
+	"testing"
+
+	"github.com/evcc-io/evcc/server/db/settings"
+	"github.com/evcc-io/evcc/util"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigMapSet(t *testing.T) {
+	cfg := GetDefaultConfig(1)
+
+	for _, tc := range []struct {
+		key, value string
+		status     core.ConfigurationStatus
+	}{
+		{"Foo", "1", core.ConfigurationStatusNotSupported},
+		{NumberOfConnectors, "2", core.ConfigurationStatusRejected},
+		{HeartbeatInterval, "foo", core.ConfigurationStatusRejected},
+		{HeartbeatInterval, "-1", core.ConfigurationStatusRejected},
+		{LocalPreAuthorize, "yes", core.ConfigurationStatusRejected},
+		{MeterValuesSampledData, "Energy.Active.Import.Register,Voltage", core.ConfigurationStatusRejected},
+		{MeterValuesSampledData, "Power.Active.Import", core.ConfigurationStatusAccepted},
+		{HeartbeatInterval, "300", core.ConfigurationStatusAccepted},
+		{WebSocketPingInterval, "30", core.ConfigurationStatusRebootRequired},
+	} {
+		assert.Equal(t, tc.status, cfg.Set(tc.key, tc.value), tc.key+"="+tc.value)
+	}
+
+	assert.Equal(t, 300, cfg.Int(HeartbeatInterval))
+	assert.Equal(t, []types.Measurand{types.MeasurandPowerActiveImport}, cfg.Measurands(MeterValuesSampledData))
+
+	// persisted changes restored
+	value, err := settings.String(settingsPrefix + HeartbeatInterval)
+	require.NoError(t, err)
+	assert.Equal(t, "300", value)
+
+	cfg = GetDefaultConfig(1)
+	cfg.Restore()
+	assert.Equal(t, 300, cfg.Int(HeartbeatInterval))
+}
+
+func TestConfigMapUpdate(t *testing.T) {
+	cfg := GetDefaultConfig(1)
+
+	// boot notification interval persisted
+	cfg.Update(HeartbeatInterval, "600")
+	assert.Equal(t, 600, cfg.Int(HeartbeatInterval))
+
+	cfg = GetDefaultConfig(1)
+	cfg.Restore()
+	assert.Equal(t, 600, cfg.Int(HeartbeatInterval))
+}
+
+func TestGetConfiguration(t *testing.T) {
+	cs := NewCore(util.NewLogger("foo"), GetDefaultConfig(2), nil)
+
+	res, err := cs.OnGetConfiguration(core.NewGetConfigurationRequest([]string{NumberOfConnectors, "Foo"}))
+	require.NoError(t, err)
+
+	require.Len(t, res.ConfigurationKey, 1)
+	assert.Equal(t, "2", *res.ConfigurationKey[0].Value)
+	assert.Equal(t, []string{"Foo"}, res.UnknownKey)
+
+	res, err = cs.OnGetConfiguration(core.NewGetConfigurationRequest(nil))
+	require.NoError(t, err)
+	assert.Len(t, res.ConfigurationKey, len(GetDefaultConfig(2).options))
+	assert.Empty(t, res.UnknownKey)
+}
@@ -3,25 +3,43 @@ package profile
 import (
 	"github.com/evcc-io/evcc/util"
 	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/remotetrigger"
 	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
 )
 
+// ChargePoint executes the central system's remote commands on the charge point's connectors
+type ChargePoint interface {
+	// RemoteStart prepares a transaction for the id tag. Connector nil selects a free connector.
+	RemoteStart(connector *int, idTag string, profile *types.ChargingProfile) bool
+	// RemoteStop stops the transaction
+	RemoteStop(transactionId int) bool
+	// ChangeAvailability changes the connector's availability. Connector 0 addresses all connectors.
+	ChangeAvailability(connector int, operative bool) core.AvailabilityStatus
+	// Trigger requests sending the message. Connector nil addresses all connectors.
+	Trigger(message remotetrigger.MessageTrigger, connector *int) remotetrigger.TriggerMessageStatus
+}
+
 type Core struct {
 	log           *util.Logger
-	configuration ConfigMap
+	configuration *ConfigMap
+	cp            ChargePoint
 }
 
-func NewCore(log *util.Logger, config ConfigMap) *Core {
+func NewCore(log *util.Logger, config *ConfigMap, cp ChargePoint) *Core {
 	return &Core{
 		log:           log,
 		configuration: config,
+		cp:            cp,
 	}
 }
 
 // OnChangeAvailability handles the CS message
 func (s *Core) OnChangeAvailability(request *core.ChangeAvailabilityRequest) (confirmation *core.ChangeAvailabilityConfirmation, err error) {
 	s.log.TRACE.Printf("recv: %s %+v", request.GetFeatureName(), request)
-	return core.NewChangeAvailabilityConfirmation(core.AvailabilityStatusRejected), nil
+
+	status := s.cp.ChangeAvailability(request.ConnectorId, request.Type == core.AvailabilityTypeOperative)
+
+	return core.NewChangeAvailabilityConfirmation(status), nil
 }
 
 // OnUnlockConnector handles the CS message
@@ -33,11 +51,23 @@ func (s *Core) OnUnlockConnector(request *core.UnlockConnectorRequest) (confirma
 // OnRemoteStartTransaction handles the CS message
 func (s *Core) OnRemoteStartTransaction(request *core.RemoteStartTransactionRequest) (confirmation *core.RemoteStartTransactionConfirmation, err error) {
 	s.log.TRACE.Printf("recv: %s %+v", request.GetFeatureName(), request)
-	return core.NewRemoteStartTransactionConfirmation(types.RemoteStartStopStatusRejected), nil
+
+	status := types.RemoteStartStopStatusRejected
+	if s.cp.RemoteStart(request.ConnectorId, request.IdTag, request.ChargingProfile) {
+		status = types.RemoteStartStopStatusAccepted
+	}
+
+	return core.NewRemoteStartTransactionConfirmation(status), nil
 }
 
 // OnRemoteStopTransaction handles the CS message
 func (s *Core) OnRemoteStopTransaction(request *core.RemoteStopTransactionRequest) (confirmation *core.RemoteStopTransactionConfirmation, err error) {
 	s.log.TRACE.Printf("recv: %s %+v", request.GetFeatureName(), request)
-	return core.NewRemoteStopTransactionConfirmation(types.RemoteStartStopStatusRejected), nil
+
+	status := types.RemoteStartStopStatusRejected
+	if s.cp.RemoteStop(request.TransactionId) {
+		status = types.RemoteStartStopStatusAccepted
+	}
+
+	return core.NewRemoteStopTransactionConfirmation(status), nil
 }
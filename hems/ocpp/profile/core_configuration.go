@@ -2,29 +2,13 @@ package profile
 
 import (
 	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
-	"golang.org/x/exp/maps"
 )
 
 // OnGetConfiguration handles the CS message
 func (s *Core) OnGetConfiguration(request *core.GetConfigurationRequest) (confirmation *core.GetConfigurationConfirmation, err error) {
 	s.log.TRACE.Printf("recv: %s %+v", request.GetFeatureName(), request)
 
-	var resultKeys []core.ConfigurationKey
-	var unknownKeys []string
-
-	for _, key := range request.Key {
-		configKey, ok := s.configuration[key]
-		if !ok {
-			unknownKeys = append(unknownKeys, *configKey.Value)
-		} else {
-			resultKeys = append(resultKeys, configKey)
-		}
-	}
-
-	// return config for all keys
-	if len(request.Key) == 0 {
-		resultKeys = maps.Values(s.configuration)
-	}
+	resultKeys, unknownKeys := s.configuration.Get(request.Key)
 
 	s.log.TRACE.Printf("%s: configuration for requested keys: %v", request.GetFeatureName(), request.Key)
 
@@ -37,5 +21,11 @@ func (s *Core) OnGetConfiguration(request *core.GetConfigurationRequest) (confir
 // OnChangeConfiguration handles the CS message
 func (s *Core) OnChangeConfiguration(request *core.ChangeConfigurationRequest) (confirmation *core.ChangeConfigurationConfirmation, err error) {
 	s.log.TRACE.Printf("recv: %s %+v", request.GetFeatureName(), request)
-	return core.NewChangeConfigurationConfirmation(core.ConfigurationStatusAccepted), nil
+
+	status := s.configuration.Set(request.Key, request.Value)
+	if status != core.ConfigurationStatusAccepted {
+		s.log.WARN.Printf("%s: %s=%s: %s", request.GetFeatureName(), request.Key, request.Value, status)
+	}
+
+	return core.NewChangeConfigurationConfirmation(status), nil
 }
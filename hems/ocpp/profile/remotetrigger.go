@@ -0,0 +1,27 @@
+package profile
+
+import (
+	"github.com/evcc-io/evcc/util"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/remotetrigger"
+)
+
+type RemoteTrigger struct {
+	log *util.Logger
+	cp  ChargePoint
+}
+
+func NewRemoteTrigger(log *util.Logger, cp ChargePoint) *RemoteTrigger {
+	return &RemoteTrigger{
+		log: log,
+		cp:  cp,
+	}
+}
+
+// OnTriggerMessage handles the CS message
+func (s *RemoteTrigger) OnTriggerMessage(request *remotetrigger.TriggerMessageRequest) (confirmation *remotetrigger.TriggerMessageConfirmation, err error) {
+	s.log.TRACE.Printf("recv: %s %+v", request.GetFeatureName(), request)
+
+	status := s.cp.Trigger(request.RequestedMessage, request.ConnectorId)
+
+	return remotetrigger.NewTriggerMessageConfirmation(status), nil
+}
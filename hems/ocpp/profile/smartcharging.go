@@ -1,34 +1,83 @@
 package profile
 
 import (
+	"time"
+
 	"github.com/evcc-io/evcc/util"
+	"github.com/evcc-io/evcc/util/chargingprofile"
 	sc "github.com/lorenzodonini/ocpp-go/ocpp1.6/smartcharging"
 )
 
+// maxCurrent is reported for periods without charging profile
+const maxCurrent = 32
+
 type SmartCharging struct {
-	log *util.Logger
+	log        *util.Logger
+	profiles   *chargingprofile.Store
+	connectors int
+	updated    func() // applies changed profiles
 }
 
-func NewSmartCharging(log *util.Logger) *SmartCharging {
+func NewSmartCharging(log *util.Logger, profiles *chargingprofile.Store, connectors int, updated func()) *SmartCharging {
 	return &SmartCharging{
-		log: log,
+		log:        log,
+		profiles:   profiles,
+		connectors: connectors,
+		updated:    updated,
 	}
 }
 
 // OnSetChargingProfile handles the CS message
 func (s *SmartCharging) OnSetChargingProfile(request *sc.SetChargingProfileRequest) (confirmation *sc.SetChargingProfileConfirmation, err error) {
 	s.log.TRACE.Printf("recv: %s %+v", request.GetFeatureName(), request)
-	return sc.NewSetChargingProfileConfirmation(sc.ChargingProfileStatusRejected), nil
+
+	if request.ConnectorId > s.connectors {
+		return sc.NewSetChargingProfileConfirmation(sc.ChargingProfileStatusRejected), nil
+	}
+
+	if err := s.profiles.Set(request.ConnectorId, request.ChargingProfile); err != nil {
+		s.log.ERROR.Printf("%s: %v", request.GetFeatureName(), err)
+		return sc.NewSetChargingProfileConfirmation(sc.ChargingProfileStatusRejected), nil
+	}
+
+	s.updated()
+
+	return sc.NewSetChargingProfileConfirmation(sc.ChargingProfileStatusAccepted), nil
 }
 
 // OnClearChargingProfile handles the CS message
 func (s *SmartCharging) OnClearChargingProfile(request *sc.ClearChargingProfileRequest) (confirmation *sc.ClearChargingProfileConfirmation, err error) {
 	s.log.TRACE.Printf("recv: %s %+v", request.GetFeatureName(), request)
-	return sc.NewClearChargingProfileConfirmation(sc.ClearChargingProfileStatusUnknown), nil
+
+	status := sc.ClearChargingProfileStatusUnknown
+	if s.profiles.Clear(request) {
+		status = sc.ClearChargingProfileStatusAccepted
+		s.updated()
+	}
+
+	return sc.NewClearChargingProfileConfirmation(status), nil
 }
 
 // OnGetCompositeSchedule handles the CS message
 func (s *SmartCharging) OnGetCompositeSchedule(request *sc.GetCompositeScheduleRequest) (confirmation *sc.GetCompositeScheduleConfirmation, err error) {
 	s.log.TRACE.Printf("recv: %s %+v", request.GetFeatureName(), request)
-	return sc.NewGetCompositeScheduleConfirmation(sc.GetCompositeScheduleStatusRejected), nil
+
+	res := sc.NewGetCompositeScheduleConfirmation(sc.GetCompositeScheduleStatusRejected)
+	if request.ConnectorId > s.connectors {
+		return res, nil
+	}
+
+	schedule := s.profiles.CompositeSchedule(request.ConnectorId, time.Now(), request.Duration, request.ChargingRateUnit, maxCurrent, nil)
+	if schedule == nil {
+		return res, nil
+	}
+
+	res.Status = sc.GetCompositeScheduleStatusAccepted
+	res.ScheduleStart = schedule.StartSchedule
+	res.ChargingSchedule = schedule
+	if request.ConnectorId > 0 {
+		res.ConnectorId = &request.ConnectorId
+	}
+
+	return res, nil
 }
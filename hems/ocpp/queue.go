@@ -0,0 +1,266 @@
+package ocpp
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/evcc-io/evcc/util"
+	"github.com/glebarez/sqlite"
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Message is a persisted transaction related message awaiting delivery to the central system
+type Message struct {
+	ID            int64  `gorm:"primarykey"`
+	Connector     int    `gorm:"index:idx_ocpp_message_ref"`
+	Ref           int64  `gorm:"index:idx_ocpp_message_ref"` // id of the transaction's start message
+	TransactionId int    // assigned by the central system once the start message has been delivered
+	Action        string // message feature name
+	Payload       string // json encoded request
+	Attempts      int
+	Created       time.Time
+}
+
+// TableName avoids conflicts with the central system's tables
+func (Message) TableName() string {
+	return "ocpp_client_messages"
+}
+
+// State is the persisted connector state restored after restart
+type State struct {
+	Connector  int     `gorm:"primarykey;autoIncrement:false"`
+	Energy     float64 // Wh, energy register
+	Ref        int64   // start message of the open transaction, 0 if idle
+	TxnId      int     // transaction id assigned by the central system
+	IdTag      string
+	MeterStart int
+	Started    time.Time
+}
+
+// TableName avoids conflicts with the central system's tables
+func (State) TableName() string {
+	return "ocpp_client_connectors"
+}
+
+// requester sends requests to the central system
+type requester interface {
+	SendRequest(request ocpp.Request) (ocpp.Response, error)
+}
+
+// queue persists transaction related messages until they have been delivered in order
+type queue struct {
+	mu    sync.Mutex
+	log   *util.Logger
+	db    *gorm.DB
+	clock clock.Clock
+	retry time.Time // earliest time of the next attempt after a failed delivery
+}
+
+// newQueue creates the message queue. Without database, messages are kept in memory.
+func newQueue(log *util.Logger, db *gorm.DB) (*queue, error) {
+	if db == nil {
+		var err error
+		if db, err = gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{
+			Logger: logger.Default.LogMode(logger.Silent),
+		}); err != nil {
+			return nil, err
+		}
+
+		// each connection opens a separate in-memory database
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		sqlDB.SetMaxOpenConns(1)
+	}
+
+	if err := db.AutoMigrate(new(Message), new(State)); err != nil {
+		return nil, err
+	}
+
+	return &queue{
+		log:   log,
+		db:    db,
+		clock: clock.New(),
+	}, nil
+}
+
+// enqueue persists the message. Ref identifies the transaction's start message, 0 for start messages themselves.
+// Returns the message id.
+func (q *queue) enqueue(connector int, ref int64, txnId int, request ocpp.Request) (int64, error) {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return 0, err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	msg := Message{
+		Connector:     connector,
+		Ref:           ref,
+		TransactionId: txnId,
+		Action:        request.GetFeatureName(),
+		Payload:       string(payload),
+		Created:       q.clock.Now(),
+	}
+
+	err = q.db.Create(&msg).Error
+
+	return msg.ID, err
+}
+
+// save persists the connector state. It doesn't lock the queue as it's called while flushing.
+func (q *queue) save(state State) error {
+	return q.db.Save(&state).Error
+}
+
+// state returns the connector's persisted state, empty if not found
+func (q *queue) state(connector int) (State, error) {
+	var res State
+	err := q.db.Where(&State{Connector: connector}).Limit(1).Find(&res).Error
+
+	return res, err
+}
+
+// len returns the number of queued messages
+func (q *queue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var count int64
+	if err := q.db.Model(new(Message)).Count(&count).Error; err != nil {
+		q.log.ERROR.Printf("queue: %v", err)
+	}
+
+	return int(count)
+}
+
+// decode restores the message's request and applies the transaction id assigned by the central system
+func (msg *Message) decode() (ocpp.Request, error) {
+	var request ocpp.Request
+
+	switch msg.Action {
+	case core.StartTransactionFeatureName:
+		request = new(core.StartTransactionRequest)
+	case core.StopTransactionFeatureName:
+		request = new(core.StopTransactionRequest)
+	case core.MeterValuesFeatureName:
+		request = new(core.MeterValuesRequest)
+	default:
+		return nil, fmt.Errorf("invalid action: %s", msg.Action)
+	}
+
+	if err := json.Unmarshal([]byte(msg.Payload), request); err != nil {
+		return nil, err
+	}
+
+	switch req := request.(type) {
+	case *core.StopTransactionRequest:
+		req.TransactionId = msg.TransactionId
+	case *core.MeterValuesRequest:
+		if msg.Ref != 0 {
+			id := msg.TransactionId
+			req.TransactionId = &id
+		}
+	}
+
+	return request, nil
+}
+
+// flush delivers the queued messages in order. Delivery stops at the first failure and is retried after
+// interval multiplied by the number of attempts. Messages are dropped once attempts are exhausted.
+// Started is called with the transaction id assigned to a delivered start message.
+func (q *queue) flush(cp requester, attempts int, interval time.Duration, started func(connector int, ref int64, id int)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.clock.Now().Before(q.retry) {
+		return
+	}
+
+	for {
+		var msg Message
+		res := q.db.Order("id").Limit(1).Find(&msg)
+		if res.Error != nil {
+			q.log.ERROR.Printf("queue: %v", res.Error)
+			return
+		}
+
+		if res.RowsAffected == 0 {
+			return
+		}
+
+		request, err := msg.decode()
+
+		// transaction has not been registered with the central system
+		if err == nil && msg.Ref != 0 && msg.TransactionId == 0 {
+			err = fmt.Errorf("transaction not started")
+		}
+
+		if err != nil {
+			q.log.ERROR.Printf("connector %d: drop %s: %v", msg.Connector, msg.Action, err)
+			q.delete(msg.ID)
+			continue
+		}
+
+		q.log.DEBUG.Printf("send: connector %d: %s", msg.Connector, msg.Action)
+
+		conf, err := cp.SendRequest(request)
+		if err != nil {
+			q.failed(msg, attempts, interval, err)
+			return
+		}
+
+		q.delete(msg.ID)
+
+		if res, ok := conf.(*core.StartTransactionConfirmation); ok {
+			// queued messages of the transaction refer to the assigned id
+			if err := q.db.Model(new(Message)).Where(&Message{Ref: msg.ID}).Update("transaction_id", res.TransactionId).Error; err != nil {
+				q.log.ERROR.Printf("queue: %v", err)
+			}
+
+			started(msg.Connector, msg.ID, res.TransactionId)
+		}
+	}
+}
+
+// failed schedules the next attempt or drops the message once attempts are exhausted. Lock must be held.
+func (q *queue) failed(msg Message, attempts int, interval time.Duration, err error) {
+	msg.Attempts++
+
+	if msg.Attempts < attempts {
+		q.log.ERROR.Printf("connector %d: %s: %v (attempt %d/%d)", msg.Connector, msg.Action, err, msg.Attempts, attempts)
+		q.retry = q.clock.Now().Add(time.Duration(msg.Attempts) * interval)
+
+		if err := q.db.Model(&msg).Update("attempts", msg.Attempts).Error; err != nil {
+			q.log.ERROR.Printf("queue: %v", err)
+		}
+
+		return
+	}
+
+	q.log.ERROR.Printf("connector %d: drop %s after %d attempts: %v", msg.Connector, msg.Action, msg.Attempts, err)
+	q.delete(msg.ID)
+}
+
+// delete removes the message. Lock must be held.
+func (q *queue) delete(id int64) {
+	if err := q.db.Delete(new(Message), id).Error; err != nil {
+		q.log.ERROR.Printf("queue: %v", err)
+	}
+}
+
+// resume allows immediate delivery after the connection has been restored
+func (q *queue) resume() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.retry = time.Time{}
+}
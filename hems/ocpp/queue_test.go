@@ -0,0 +1,186 @@
+package ocpp
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/evcc-io/evcc/api"
+	"github.com/evcc-io/evcc/util"
+	"github.com/evcc-io/evcc/util/chargingprofile"
+	"github.com/glebarez/sqlite"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestQueueOffline(t *testing.T) {
+	lp := &testLoadpoint{status: api.StatusA, mode: api.ModePV}
+	s, clock := newTestOCPP(lp)
+	conn := s.connectors[0]
+	cp := new(testSender)
+
+	// transaction while offline
+	lp.set(api.StatusC, 11e3)
+	conn.update(time.Minute, time.Minute, sampledData)
+	clock.Add(time.Hour)
+	conn.update(time.Minute, time.Minute, sampledData)
+	lp.set(api.StatusA, 0)
+	conn.update(time.Minute, time.Minute, sampledData)
+
+	assert.Equal(t, 3, s.queue.len())
+
+	// delivered in order once online
+	cp.txnId = 41
+	s.flush(cp)
+
+	assert.Equal(t, 0, s.queue.len())
+	require.Len(t, cp.starts, 1)
+	require.Len(t, cp.meterVal, 1)
+	require.Len(t, cp.stops, 1)
+
+	assert.Equal(t, 42, *cp.meterVal[0].TransactionId)
+	assert.Equal(t, 42, cp.stops[0].TransactionId)
+	assert.Equal(t, 11000, cp.stops[0].MeterStop)
+}
+
+func TestQueueRetry(t *testing.T) {
+	lp := &testLoadpoint{status: api.StatusB, mode: api.ModePV}
+	s, clock := newTestOCPP(lp)
+	conn := s.connectors[0]
+	cp := &testSender{err: errors.New("timeout")}
+
+	conn.update(time.Minute, time.Minute, sampledData)
+	s.flush(cp)
+
+	// retried after interval multiplied by attempts
+	cp.err = nil
+	s.flush(cp)
+	assert.Empty(t, cp.starts)
+
+	clock.Add(time.Minute)
+	s.flush(cp)
+	require.Len(t, cp.starts, 1)
+	assert.Equal(t, 1, conn.transaction().ID)
+
+	// stop dropped with its transaction once attempts are exhausted
+	lp.set(api.StatusA, 0)
+	conn.update(time.Minute, time.Minute, sampledData)
+
+	cp.err = errors.New("timeout")
+	for i := 1; i <= 5; i++ {
+		s.flush(cp)
+		clock.Add(time.Duration(i) * time.Minute)
+	}
+
+	assert.Equal(t, 0, s.queue.len())
+	assert.Empty(t, cp.stops)
+}
+
+func TestQueuePersistence(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), new(gorm.Config))
+	require.NoError(t, err)
+
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+
+	log := util.NewLogger("foo")
+
+	q, err := newQueue(log, db)
+	require.NoError(t, err)
+
+	ref, err := q.enqueue(1, 0, 0, core.NewStartTransactionRequest(1, defaultIdTag, 0, types.NewDateTime(time.Now())))
+	require.NoError(t, err)
+
+	_, err = q.enqueue(1, ref, 0, core.NewStopTransactionRequest(100, types.NewDateTime(time.Now()), 0))
+	require.NoError(t, err)
+
+	// queue restored after restart
+	q, err = newQueue(log, db)
+	require.NoError(t, err)
+
+	cp := new(testSender)
+	q.flush(cp, 5, time.Minute, func(int, int64, int) {})
+
+	require.Len(t, cp.starts, 1)
+	require.Len(t, cp.stops, 1)
+	assert.Equal(t, 1, cp.stops[0].TransactionId)
+	assert.Equal(t, 100, cp.stops[0].MeterStop)
+}
+
+func TestConnectorRestore(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), new(gorm.Config))
+	require.NoError(t, err)
+
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+
+	log := util.NewLogger("foo")
+	lp := &testLoadpoint{status: api.StatusC, power: 11e3, mode: api.ModePV}
+	clock := clock.NewMock()
+
+	restart := func() (*queue, *connector) {
+		q, err := newQueue(log, db)
+		require.NoError(t, err)
+		q.clock = clock
+
+		conn := newConnector(log, 1, lp, chargingprofile.NewStore(), q, defaultIdTag)
+		conn.clock = clock
+
+		return q, conn
+	}
+
+	flush := func(q *queue, conn *connector, cp *testSender) {
+		q.flush(cp, 5, time.Minute, func(_ int, ref int64, id int) { conn.started(ref, id) })
+	}
+
+	// transaction started and charging for an hour
+	q, conn := restart()
+	cp := new(testSender)
+
+	conn.update(time.Minute, time.Minute, sampledData)
+	flush(q, conn, cp)
+	clock.Add(time.Hour)
+	conn.update(time.Minute, time.Minute, sampledData)
+	flush(q, conn, cp)
+
+	require.Len(t, cp.starts, 1)
+	require.Len(t, cp.meterVal, 1)
+
+	// transaction and energy register restored after restart
+	q, conn = restart()
+	require.NotNil(t, conn.transaction())
+	assert.Equal(t, 1, conn.transaction().ID)
+
+	lp.set(api.StatusA, 0)
+	conn.update(time.Minute, time.Minute, sampledData)
+	flush(q, conn, cp)
+
+	require.Len(t, cp.starts, 1)
+	require.Len(t, cp.stops, 1)
+	assert.Equal(t, 1, cp.stops[0].TransactionId)
+	assert.Equal(t, 11000, cp.stops[0].MeterStop)
+
+	// transaction started offline is assigned its id after restart
+	lp.set(api.StatusB, 0)
+	conn.update(time.Minute, time.Minute, sampledData)
+
+	q, conn = restart()
+	flush(q, conn, cp)
+	require.Len(t, cp.starts, 2)
+	assert.Equal(t, 11000, cp.starts[1].MeterStart)
+	assert.Equal(t, 2, conn.transaction().ID)
+
+	lp.set(api.StatusA, 0)
+	conn.update(time.Minute, time.Minute, sampledData)
+	flush(q, conn, cp)
+
+	require.Len(t, cp.stops, 2)
+	assert.Equal(t, 2, cp.stops[1].TransactionId)
+	assert.Nil(t, conn.transaction())
+}
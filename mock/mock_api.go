@@ -1,11 +1,12 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/evcc-io/evcc/api (interfaces: Charger,ChargeState,PhaseSwitcher,Identifier,Meter,MeterEnergy,Vehicle,ChargeRater,Battery,Tariff)
+// Source: github.com/evcc-io/evcc/api (interfaces: Charger,ChargeState,PhaseSwitcher,Identifier,Meter,MeterEnergy,Vehicle,ChargeRater,Battery,Tariff,Reserver)
 
 // Package mock is a generated GoMock package.
 package mock
 
 import (
 	reflect "reflect"
+	time "time"
 
 	api "github.com/evcc-io/evcc/api"
 	gomock "github.com/golang/mock/gomock"
@@ -528,3 +529,54 @@ func (mr *MockTariffMockRecorder) Rates() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Rates", reflect.TypeOf((*MockTariff)(nil).Rates))
 }
+
+// MockReserver is a mock of Reserver interface.
+type MockReserver struct {
+	ctrl     *gomock.Controller
+	recorder *MockReserverMockRecorder
+}
+
+// MockReserverMockRecorder is the mock recorder for MockReserver.
+type MockReserverMockRecorder struct {
+	mock *MockReserver
+}
+
+// NewMockReserver creates a new mock instance.
+func NewMockReserver(ctrl *gomock.Controller) *MockReserver {
+	mock := &MockReserver{ctrl: ctrl}
+	mock.recorder = &MockReserverMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockReserver) EXPECT() *MockReserverMockRecorder {
+	return m.recorder
+}
+
+// CancelReservation mocks base method.
+func (m *MockReserver) CancelReservation() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelReservation")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CancelReservation indicates an expected call of CancelReservation.
+func (mr *MockReserverMockRecorder) CancelReservation() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelReservation", reflect.TypeOf((*MockReserver)(nil).CancelReservation))
+}
+
+// Reserve mocks base method.
+func (m *MockReserver) Reserve(arg0 string, arg1 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reserve", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Reserve indicates an expected call of Reserve.
+func (mr *MockReserverMockRecorder) Reserve(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reserve", reflect.TypeOf((*MockReserver)(nil).Reserve), arg0, arg1)
+}
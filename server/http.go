@@ -124,6 +124,8 @@ func (s *HTTPd) RegisterSiteHandlers(site site.API, cache *util.Cache) {
 			"phases":        {[]string{"POST", "OPTIONS"}, "/phases/{value:[0-9]+}", phasesHandler(lp)},
 			"targetcharge":  {[]string{"POST", "OPTIONS"}, "/targetcharge/{soc:[0-9]+}/{time:[0-9TZ:.-]+}", targetChargeHandler(lp)},
 			"targetcharge2": {[]string{"DELETE", "OPTIONS"}, "/targetcharge", targetChargeRemoveHandler(lp)},
+			"reservation":   {[]string{"POST", "OPTIONS"}, "/reservation/{id}/{time:[0-9TZ:.-]+}", reservationHandler(lp)},
+			"reservation2":  {[]string{"DELETE", "OPTIONS"}, "/reservation", reservationRemoveHandler(lp)},
 			"vehicle":       {[]string{"POST", "OPTIONS"}, "/vehicle/{vehicle:[1-9][0-9]*}", vehicleHandler(site, lp)},
 			"vehicle2":      {[]string{"DELETE", "OPTIONS"}, "/vehicle", vehicleRemoveHandler(lp)},
 			"vehicleDetect": {[]string{"PATCH", "OPTIONS"}, "/vehicle", vehicleDetectHandler(lp)},
@@ -135,6 +137,41 @@ func (s *HTTPd) RegisterSiteHandlers(site site.API, cache *util.Cache) {
 		}
 	}
 
+	// ocpp api
+	ocpp := api.PathPrefix("/ocpp").Subrouter()
+
+	routes = map[string]route{
+		"stations":       {[]string{"GET"}, "/stations", ocppStationsHandler},
+		"station":        {[]string{"GET"}, "/stations/{id}", ocppStationHandler},
+		"reset":          {[]string{"POST", "OPTIONS"}, "/stations/{id}/reset/{type:soft|hard}", ocppCommandHandler(ocppReset)},
+		"unlock":         {[]string{"POST", "OPTIONS"}, "/stations/{id}/unlock/{connector:[0-9]+}", ocppCommandHandler(ocppUnlockConnector)},
+		"availability":   {[]string{"POST", "OPTIONS"}, "/stations/{id}/availability/{connector:[0-9]+}/{type:operative|inoperative}", ocppCommandHandler(ocppChangeAvailability)},
+		"trigger":        {[]string{"POST", "OPTIONS"}, "/stations/{id}/trigger/{message:[a-zA-Z]+}", ocppCommandHandler(ocppTriggerMessage)},
+		"configuration":  {[]string{"GET"}, "/stations/{id}/configuration", ocppConfigurationHandler},
+		"configuration2": {[]string{"POST", "OPTIONS"}, "/stations/{id}/configuration/{key}/{value}", ocppCommandHandler(ocppChangeConfiguration)},
+		"clearcache":     {[]string{"POST", "OPTIONS"}, "/stations/{id}/clearcache", ocppCommandHandler(ocppClearCache)},
+		"firmware":       {[]string{"POST", "OPTIONS"}, "/stations/{id}/firmware/{file}", ocppFirmwareHandler},
+		"diagnostics":    {[]string{"POST", "OPTIONS"}, "/stations/{id}/diagnostics", ocppDiagnosticsHandler},
+		"operations":     {[]string{"GET"}, "/stations/{id}/operations", ocppOperationsHandler},
+	}
+
+	for _, r := range routes {
+		ocpp.Methods(r.Methods...).Path(r.Pattern).Handler(r.HandlerFunc)
+	}
+
+	// ocpp firmware downloads and diagnostics uploads by stations
+	files := router.PathPrefix("/ocpp").Subrouter()
+
+	routes = map[string]route{
+		"firmware":     {[]string{"GET", "HEAD"}, "/firmware/{file}", ocppFirmwareDownloadHandler},
+		"diagnostics":  {[]string{"POST"}, "/diagnostics/{id}", ocppDiagnosticsUploadHandler},
+		"diagnostics2": {[]string{"PUT"}, "/diagnostics/{id}/{file}", ocppDiagnosticsUploadHandler},
+		"diagnostics3": {[]string{"GET"}, "/diagnostics/{id}/{file}", ocppDiagnosticsDownloadHandler},
+	}
+
+	for _, r := range routes {
+		files.Methods(r.Methods...).Path(r.Pattern).Handler(r.HandlerFunc)
+	}
 }
 
 // RegisterShutdownHandler connects the http handlers to the site
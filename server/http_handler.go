@@ -5,14 +5,17 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"math"
 	"net/http"
+	"path/filepath"
 	"strconv"
 	"text/template"
 	"time"
 
 	"github.com/evcc-io/evcc/api"
+	"github.com/evcc-io/evcc/charger/ocpp"
 	"github.com/evcc-io/evcc/core/db"
 	"github.com/evcc-io/evcc/core/loadpoint"
 	"github.com/evcc-io/evcc/core/site"
@@ -359,6 +362,47 @@ func targetChargeRemoveHandler(loadpoint loadpoint.API) http.HandlerFunc {
 	}
 }
 
+// reservationHandler reserves the loadpoint's charger
+func reservationHandler(lp loadpoint.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		timeV, err := time.Parse(time.RFC3339, vars["time"])
+		if err != nil {
+			jsonError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := lp.SetReservation(vars["id"], timeV); err != nil {
+			jsonError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		res := struct {
+			ID     string    `json:"id"`
+			Expiry time.Time `json:"expiry"`
+		}{
+			ID:     vars["id"],
+			Expiry: timeV,
+		}
+
+		jsonResult(w, res)
+	}
+}
+
+// reservationRemoveHandler cancels the loadpoint's charger reservation
+func reservationRemoveHandler(lp loadpoint.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := lp.RemoveReservation(); err != nil {
+			jsonError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		res := struct{}{}
+		jsonResult(w, res)
+	}
+}
+
 // vehicleHandler sets active vehicle
 func vehicleHandler(site site.API, loadpoint loadpoint.API) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -416,3 +460,275 @@ type targetCharger interface {
 	// SetTargetCharge sets the charge targetSoc
 	SetTargetCharge(time.Time, int) error
 }
+
+// ocppAdmin returns the OCPP central system's admin interface
+func ocppAdmin(w http.ResponseWriter) (*ocpp.Admin, bool) {
+	cs := ocpp.Running()
+	if cs == nil {
+		jsonError(w, http.StatusNotFound, errors.New("ocpp not running"))
+		return nil, false
+	}
+
+	return cs.Admin(), true
+}
+
+// ocppError writes the OCPP admin error with matching status code
+func ocppError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+
+	switch {
+	case errors.Is(err, ocpp.ErrUnknownStation):
+		status = http.StatusNotFound
+	case errors.Is(err, ocpp.ErrStationOffline):
+		status = http.StatusServiceUnavailable
+	case errors.Is(err, api.ErrTimeout):
+		status = http.StatusGatewayTimeout
+	case errors.Is(err, fs.ErrNotExist):
+		status = http.StatusNotFound
+	}
+
+	jsonError(w, status, err)
+}
+
+// ocppStationsHandler returns all OCPP stations
+func ocppStationsHandler(w http.ResponseWriter, r *http.Request) {
+	admin, ok := ocppAdmin(w)
+	if !ok {
+		return
+	}
+
+	jsonResult(w, admin.Stations())
+}
+
+// ocppStationHandler returns the OCPP station
+func ocppStationHandler(w http.ResponseWriter, r *http.Request) {
+	admin, ok := ocppAdmin(w)
+	if !ok {
+		return
+	}
+
+	res, err := admin.Station(mux.Vars(r)["id"])
+	if err != nil {
+		ocppError(w, err)
+		return
+	}
+
+	jsonResult(w, res)
+}
+
+// ocppConfigurationHandler returns the OCPP station's configuration keys given by key query parameters or all keys
+func ocppConfigurationHandler(w http.ResponseWriter, r *http.Request) {
+	admin, ok := ocppAdmin(w)
+	if !ok {
+		return
+	}
+
+	res, err := admin.GetConfiguration(mux.Vars(r)["id"], r.URL.Query()["key"])
+	if err != nil {
+		ocppError(w, err)
+		return
+	}
+
+	jsonResult(w, res)
+}
+
+// ocppCommandHandler sends the command to the OCPP station and returns the station's response status
+func ocppCommandHandler(command func(admin *ocpp.Admin, vars map[string]string) (string, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		admin, ok := ocppAdmin(w)
+		if !ok {
+			return
+		}
+
+		res, err := command(admin, mux.Vars(r))
+		if err != nil {
+			ocppError(w, err)
+			return
+		}
+
+		jsonResult(w, res)
+	}
+}
+
+func ocppReset(admin *ocpp.Admin, vars map[string]string) (string, error) {
+	return admin.Reset(vars["id"], vars["type"] == "hard")
+}
+
+func ocppUnlockConnector(admin *ocpp.Admin, vars map[string]string) (string, error) {
+	connector, err := strconv.Atoi(vars["connector"])
+	if err != nil {
+		return "", err
+	}
+
+	return admin.UnlockConnector(vars["id"], connector)
+}
+
+func ocppChangeAvailability(admin *ocpp.Admin, vars map[string]string) (string, error) {
+	connector, err := strconv.Atoi(vars["connector"])
+	if err != nil {
+		return "", err
+	}
+
+	return admin.ChangeAvailability(vars["id"], connector, vars["type"] == "operative")
+}
+
+func ocppTriggerMessage(admin *ocpp.Admin, vars map[string]string) (string, error) {
+	return admin.TriggerMessage(vars["id"], vars["message"])
+}
+
+func ocppChangeConfiguration(admin *ocpp.Admin, vars map[string]string) (string, error) {
+	return admin.ChangeConfiguration(vars["id"], vars["key"], vars["value"])
+}
+
+func ocppClearCache(admin *ocpp.Admin, vars map[string]string) (string, error) {
+	return admin.ClearCache(vars["id"])
+}
+
+// ocppRetry returns the optional retries and retryInterval query parameters
+func ocppRetry(r *http.Request) (ocpp.Retry, error) {
+	var res ocpp.Retry
+
+	for key, ptr := range map[string]**int{
+		"retries":       &res.Retries,
+		"retryInterval": &res.RetryInterval,
+	} {
+		if s := r.URL.Query().Get(key); s != "" {
+			val, err := strconv.Atoi(s)
+			if err != nil {
+				return res, fmt.Errorf("invalid %s: %w", key, err)
+			}
+
+			*ptr = &val
+		}
+	}
+
+	return res, nil
+}
+
+// ocppFirmwareHandler requests the OCPP station to update its firmware
+func ocppFirmwareHandler(w http.ResponseWriter, r *http.Request) {
+	admin, ok := ocppAdmin(w)
+	if !ok {
+		return
+	}
+
+	retry, err := ocppRetry(r)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	vars := mux.Vars(r)
+
+	res, err := admin.UpdateFirmware(vars["id"], vars["file"], retry)
+	if err != nil {
+		ocppError(w, err)
+		return
+	}
+
+	jsonResult(w, res)
+}
+
+// ocppDiagnosticsHandler requests the OCPP station to upload its diagnostics
+func ocppDiagnosticsHandler(w http.ResponseWriter, r *http.Request) {
+	admin, ok := ocppAdmin(w)
+	if !ok {
+		return
+	}
+
+	retry, err := ocppRetry(r)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	res, err := admin.GetDiagnostics(mux.Vars(r)["id"], retry)
+	if err != nil {
+		ocppError(w, err)
+		return
+	}
+
+	jsonResult(w, res)
+}
+
+// ocppOperationsHandler returns the OCPP station's firmware updates and diagnostics uploads
+func ocppOperationsHandler(w http.ResponseWriter, r *http.Request) {
+	admin, ok := ocppAdmin(w)
+	if !ok {
+		return
+	}
+
+	res, err := admin.Operations(mux.Vars(r)["id"])
+	if err != nil {
+		ocppError(w, err)
+		return
+	}
+
+	jsonResult(w, res)
+}
+
+// ocppFileError writes the file error with matching status code
+func ocppFileError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	if errors.Is(err, fs.ErrNotExist) {
+		status = http.StatusNotFound
+	}
+
+	http.Error(w, err.Error(), status)
+}
+
+// ocppFirmwareDownloadHandler serves firmware images to OCPP stations
+func ocppFirmwareDownloadHandler(w http.ResponseWriter, r *http.Request) {
+	path, err := ocpp.FirmwareFile(mux.Vars(r)["file"])
+	if err != nil {
+		ocppFileError(w, err)
+		return
+	}
+
+	http.ServeFile(w, r, path)
+}
+
+// ocppDiagnosticsDownloadHandler serves diagnostics uploaded by OCPP stations
+func ocppDiagnosticsDownloadHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	path, err := ocpp.DiagnosticsFile(vars["id"], vars["file"])
+	if err != nil {
+		ocppFileError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filepath.Base(path)))
+	http.ServeFile(w, r, path)
+}
+
+// ocppDiagnosticsUploadHandler accepts diagnostics uploads by OCPP stations as multipart form or request body
+func ocppDiagnosticsUploadHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name, body := vars["file"], io.Reader(r.Body)
+
+	if mr, err := r.MultipartReader(); err == nil {
+		for {
+			part, err := mr.NextPart()
+			if err != nil {
+				http.Error(w, "missing file", http.StatusBadRequest)
+				return
+			}
+
+			if part.FileName() != "" {
+				name, body = part.FileName(), part
+				break
+			}
+		}
+	}
+
+	op, err := ocpp.SaveDiagnostics(vars["id"], name, body)
+	if err != nil {
+		ocppFileError(w, err)
+		return
+	}
+
+	log.DEBUG.Printf("ocpp diagnostics uploaded: %s", op.File)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,474 @@
+package chargingprofile
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/smartcharging"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+)
+
+const (
+	// NominalVoltage is used for converting power into current limits
+	NominalVoltage = 230
+
+	// DefaultPhases is assumed for power limits not specifying the number of phases
+	DefaultPhases = 3
+)
+
+// Transaction describes the active transaction of a connector
+type Transaction struct {
+	ID    int
+	Start time.Time
+}
+
+// Limit is the effective charging limit of a connector
+type Limit struct {
+	Current float64 // A per phase
+	Phases  int     // 0 if not restricted
+}
+
+// Power returns the limit in W
+func (l Limit) Power() float64 {
+	phases := l.Phases
+	if phases == 0 {
+		phases = DefaultPhases
+	}
+	return l.Current * float64(phases) * NominalVoltage
+}
+
+type entry struct {
+	connector int
+	profile   *types.ChargingProfile
+	installed time.Time
+}
+
+// Store holds the charging profiles of a charge point and evaluates the
+// effective limits according to OCPP 1.6 smart charging rules
+type Store struct {
+	mu       sync.Mutex
+	clock    clock.Clock
+	entries  []entry
+	charging map[int]bool // connectors drawing power
+}
+
+// NewStore creates an empty charging profile store
+func NewStore() *Store {
+	return &Store{
+		clock:    clock.New(),
+		charging: make(map[int]bool),
+	}
+}
+
+// SetCharging marks the connector as drawing power. The charge point max limit applies to the whole
+// charge point and is shared equally by the charging connectors.
+func (s *Store) SetCharging(connector int, charging bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if charging {
+		s.charging[connector] = true
+	} else {
+		delete(s.charging, connector)
+	}
+}
+
+// sharing returns the number of connectors sharing the charge point max limit with given connector
+func (s *Store) sharing(connector int) int {
+	res := 1
+	for id := range s.charging {
+		if id != connector {
+			res++
+		}
+	}
+	return res
+}
+
+// Set installs a charging profile for given connector. Connector 0 addresses the charge point.
+// An existing profile with the same id or the same connector, purpose and stack level is replaced.
+func (s *Store) Set(connector int, profile *types.ChargingProfile) error {
+	if profile == nil || profile.ChargingSchedule == nil || len(profile.ChargingSchedule.ChargingSchedulePeriod) == 0 {
+		return errors.New("missing charging schedule")
+	}
+
+	switch profile.ChargingProfilePurpose {
+	case types.ChargingProfilePurposeChargePointMaxProfile:
+		if connector != 0 {
+			return errors.New("charge point max profile requires connector 0")
+		}
+	case types.ChargingProfilePurposeTxProfile:
+		if connector == 0 {
+			return errors.New("tx profile requires connector")
+		}
+	}
+
+	if profile.ChargingProfileKind == types.ChargingProfileKindRecurring && profile.ChargingSchedule.StartSchedule == nil {
+		return errors.New("recurring profile requires start schedule")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := s.entries[:0]
+	for _, e := range s.entries {
+		if e.profile.ChargingProfileId == profile.ChargingProfileId ||
+			e.connector == connector && e.profile.ChargingProfilePurpose == profile.ChargingProfilePurpose && e.profile.StackLevel == profile.StackLevel {
+			continue
+		}
+		entries = append(entries, e)
+	}
+
+	s.entries = append(entries, entry{
+		connector: connector,
+		profile:   profile,
+		installed: s.clock.Now(),
+	})
+
+	return nil
+}
+
+// Clear removes the profiles matching the request. If an id is given, all other criteria are ignored.
+// Returns false if no profile was removed.
+func (s *Store) Clear(request *smartcharging.ClearChargingProfileRequest) bool {
+	return s.remove(func(e entry) bool {
+		if request.Id != nil {
+			return e.profile.ChargingProfileId == *request.Id
+		}
+
+		return (request.ConnectorId == nil || *request.ConnectorId == e.connector) &&
+			(request.ChargingProfilePurpose == "" || request.ChargingProfilePurpose == e.profile.ChargingProfilePurpose) &&
+			(request.StackLevel == nil || *request.StackLevel == e.profile.StackLevel)
+	})
+}
+
+// ClearTransaction removes the connector's tx profiles after the transaction has ended
+func (s *Store) ClearTransaction(connector int) bool {
+	return s.remove(func(e entry) bool {
+		return e.connector == connector && e.profile.ChargingProfilePurpose == types.ChargingProfilePurposeTxProfile
+	})
+}
+
+func (s *Store) remove(match func(entry) bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var res bool
+
+	entries := s.entries[:0]
+	for _, e := range s.entries {
+		if match(e) {
+			res = true
+			continue
+		}
+		entries = append(entries, e)
+	}
+	s.entries = entries
+
+	return res
+}
+
+// Profiles returns the profiles installed for given connector
+func (s *Store) Profiles(connector int) []*types.ChargingProfile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var res []*types.ChargingProfile
+	for _, e := range s.entries {
+		if e.connector == connector {
+			res = append(res, e.profile)
+		}
+	}
+
+	return res
+}
+
+// Limit returns the connector's effective limit at given time. It is the minimum of the connector's share
+// of the charge point max profile and the tx profile or, if there is none, the tx default profile. Connector 0
+// returns the charge point max limit. The transaction is nil if the connector is idle. Returns false if no profile applies.
+func (s *Store) Limit(connector int, ts time.Time, txn *Transaction) (Limit, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.limit(connector, ts, txn)
+}
+
+func (s *Store) limit(connector int, ts time.Time, txn *Transaction) (Limit, bool) {
+	var (
+		res = Limit{Current: math.Inf(1)}
+		ok  bool
+	)
+
+	apply := func(l Limit) {
+		if l.Current < res.Current {
+			res.Current = l.Current
+		}
+		if l.Phases > 0 && (res.Phases == 0 || l.Phases < res.Phases) {
+			res.Phases = l.Phases
+		}
+		ok = true
+	}
+
+	if l, found := s.active(0, types.ChargingProfilePurposeChargePointMaxProfile, ts, txn); found {
+		if connector > 0 {
+			l.Current /= float64(s.sharing(connector))
+		}
+		apply(l)
+	}
+
+	if connector > 0 && txn != nil {
+		if l, found := s.active(connector, types.ChargingProfilePurposeTxProfile, ts, txn); found {
+			apply(l)
+			return res, ok
+		}
+	}
+
+	// connector specific tx default profiles take precedence over the charge point's ones
+	if connector > 0 {
+		for _, id := range []int{connector, 0} {
+			if l, found := s.active(id, types.ChargingProfilePurposeTxDefaultProfile, ts, txn); found {
+				apply(l)
+				break
+			}
+		}
+	}
+
+	if !ok {
+		return Limit{}, false
+	}
+
+	return res, true
+}
+
+// active returns the limit of the profile with highest stack level having an active period at given time
+func (s *Store) active(connector int, purpose types.ChargingProfilePurposeType, ts time.Time, txn *Transaction) (Limit, bool) {
+	var (
+		res   Limit
+		level = -1
+	)
+
+	for _, e := range s.entries {
+		if e.connector != connector || e.profile.ChargingProfilePurpose != purpose || e.profile.StackLevel <= level {
+			continue
+		}
+
+		if purpose == types.ChargingProfilePurposeTxProfile && e.profile.TransactionId != 0 && (txn == nil || e.profile.TransactionId != txn.ID) {
+			continue
+		}
+
+		if l, ok := e.limit(ts, txn); ok {
+			res, level = l, e.profile.StackLevel
+		}
+	}
+
+	return res, level >= 0
+}
+
+// scheduleStart returns the start of the schedule instance that is effective at given time
+func (e entry) scheduleStart(ts time.Time, txn *Transaction) time.Time {
+	schedule := e.profile.ChargingSchedule
+
+	switch e.profile.ChargingProfileKind {
+	case types.ChargingProfileKindRelative:
+		if txn != nil && !txn.Start.IsZero() {
+			return txn.Start
+		}
+		// without transaction the schedule starts when installed
+		return e.installed
+
+	case types.ChargingProfileKindRecurring:
+		start := schedule.StartSchedule.Time
+		days := e.recurrenceDays()
+
+		if ts.After(start) {
+			// estimate elapsed instances, then correct for days not lasting 24h
+			start = start.AddDate(0, 0, int(ts.Sub(start)/(time.Duration(days)*24*time.Hour))*days)
+			for start.After(ts) {
+				start = start.AddDate(0, 0, -days)
+			}
+			for next := start.AddDate(0, 0, days); !next.After(ts); next = start.AddDate(0, 0, days) {
+				start = next
+			}
+		}
+		return start
+
+	default:
+		if schedule.StartSchedule != nil {
+			return schedule.StartSchedule.Time
+		}
+		if txn != nil && !txn.Start.IsZero() && e.profile.ChargingProfilePurpose == types.ChargingProfilePurposeTxProfile {
+			return txn.Start
+		}
+		return e.installed
+	}
+}
+
+// recurrenceDays returns the repetition interval of recurring profiles in calendar days of the start schedule's location
+func (e entry) recurrenceDays() int {
+	if e.profile.RecurrencyKind == types.RecurrencyKindWeekly {
+		return 7
+	}
+	return 1
+}
+
+// limit returns the profile's limit at given time
+func (e entry) limit(ts time.Time, txn *Transaction) (Limit, bool) {
+	profile := e.profile
+
+	if profile.ValidFrom != nil && ts.Before(profile.ValidFrom.Time) ||
+		profile.ValidTo != nil && !ts.Before(profile.ValidTo.Time) {
+		return Limit{}, false
+	}
+
+	schedule := profile.ChargingSchedule
+	start := e.scheduleStart(ts, txn)
+
+	offset := int(ts.Sub(start) / time.Second)
+	if offset < 0 || schedule.Duration != nil && offset >= *schedule.Duration {
+		return Limit{}, false
+	}
+
+	var period *types.ChargingSchedulePeriod
+	for i := range schedule.ChargingSchedulePeriod {
+		if p := &schedule.ChargingSchedulePeriod[i]; p.StartPeriod <= offset && (period == nil || p.StartPeriod >= period.StartPeriod) {
+			period = p
+		}
+	}
+
+	if period == nil {
+		return Limit{}, false
+	}
+
+	var res Limit
+	if period.NumberPhases != nil {
+		res.Phases = *period.NumberPhases
+	}
+
+	res.Current = period.Limit
+	if schedule.ChargingRateUnit == types.ChargingRateUnitWatts {
+		phases := res.Phases
+		if phases == 0 {
+			phases = DefaultPhases
+		}
+		res.Current /= float64(phases) * NominalVoltage
+	}
+
+	return res, true
+}
+
+// boundaries returns the times within [from, to) at which the profile's limit may change
+func (e entry) boundaries(from, to time.Time, txn *Transaction) []time.Time {
+	var res []time.Time
+
+	add := func(ts time.Time) {
+		if ts.After(from) && ts.Before(to) {
+			res = append(res, ts)
+		}
+	}
+
+	if e.profile.ValidFrom != nil {
+		add(e.profile.ValidFrom.Time)
+	}
+	if e.profile.ValidTo != nil {
+		add(e.profile.ValidTo.Time)
+	}
+
+	schedule := e.profile.ChargingSchedule
+	start := e.scheduleStart(from, txn)
+
+	// recurring schedules repeat until the end of the window
+	for {
+		for _, p := range schedule.ChargingSchedulePeriod {
+			add(start.Add(time.Duration(p.StartPeriod) * time.Second))
+		}
+		if schedule.Duration != nil {
+			add(start.Add(time.Duration(*schedule.Duration) * time.Second))
+		}
+
+		if e.profile.ChargingProfileKind != types.ChargingProfileKindRecurring {
+			break
+		}
+
+		if start = start.AddDate(0, 0, e.recurrenceDays()); !start.Before(to) {
+			break
+		}
+	}
+
+	return res
+}
+
+// CompositeSchedule returns the connector's effective schedule for the given duration in seconds
+// starting at given time. Periods without applicable profile are reported using the max current.
+// Unit defaults to A. Returns nil if no profile applies.
+func (s *Store) CompositeSchedule(connector int, from time.Time, duration int, unit types.ChargingRateUnitType, max float64, txn *Transaction) *types.ChargingSchedule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if unit == "" {
+		unit = types.ChargingRateUnitAmperes
+	}
+
+	to := from.Add(time.Duration(duration) * time.Second)
+
+	points := []time.Time{from}
+	for _, e := range s.entries {
+		if e.connector == connector || e.connector == 0 {
+			points = append(points, e.boundaries(from, to, txn)...)
+		}
+	}
+
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].Before(points[j])
+	})
+
+	var (
+		periods []types.ChargingSchedulePeriod
+		last    *Limit
+		found   bool
+	)
+
+	for _, ts := range points {
+		l, ok := s.limit(connector, ts, txn)
+		if ok {
+			found = true
+		}
+
+		if !ok || l.Current > max {
+			l.Current = max
+		}
+
+		if last != nil && *last == l {
+			continue
+		}
+		last = &l
+
+		period := types.ChargingSchedulePeriod{
+			StartPeriod: int(ts.Sub(from) / time.Second),
+			Limit:       l.Current,
+		}
+
+		if unit == types.ChargingRateUnitWatts {
+			period.Limit = l.Power()
+		}
+
+		if l.Phases > 0 {
+			phases := l.Phases
+			period.NumberPhases = &phases
+		}
+
+		periods = append(periods, period)
+	}
+
+	if !found {
+		return nil
+	}
+
+	schedule := types.NewChargingSchedule(unit, periods...)
+	schedule.Duration = &duration
+	schedule.StartSchedule = types.NewDateTime(from)
+
+	return schedule
+}
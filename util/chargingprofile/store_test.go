@@ -0,0 +1,281 @@
+package chargingprofile
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/smartcharging"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func profile(id, level int, purpose types.ChargingProfilePurposeType, kind types.ChargingProfileKindType, unit types.ChargingRateUnitType, periods ...types.ChargingSchedulePeriod) *types.ChargingProfile {
+	return types.NewChargingProfile(id, level, purpose, kind, types.NewChargingSchedule(unit, periods...))
+}
+
+func period(start int, limit float64) types.ChargingSchedulePeriod {
+	return types.NewChargingSchedulePeriod(start, limit)
+}
+
+func TestLimitPrecedence(t *testing.T) {
+	s := NewStore()
+	now := time.Now()
+
+	_, ok := s.Limit(1, now, nil)
+	assert.False(t, ok)
+
+	require.NoError(t, s.Set(0, profile(1, 0, types.ChargingProfilePurposeTxDefaultProfile, types.ChargingProfileKindAbsolute, types.ChargingRateUnitAmperes, period(0, 16))))
+	l, ok := s.Limit(1, now, nil)
+	require.True(t, ok)
+	assert.Equal(t, 16.0, l.Current)
+
+	// connector default overrides charge point default
+	require.NoError(t, s.Set(1, profile(2, 0, types.ChargingProfilePurposeTxDefaultProfile, types.ChargingProfileKindAbsolute, types.ChargingRateUnitAmperes, period(0, 10))))
+	l, _ = s.Limit(1, now, nil)
+	assert.Equal(t, 10.0, l.Current)
+	l, _ = s.Limit(2, now, nil)
+	assert.Equal(t, 16.0, l.Current)
+
+	// tx profile overrides tx default during transaction
+	txn := &Transaction{ID: 7, Start: now}
+	tx := profile(3, 0, types.ChargingProfilePurposeTxProfile, types.ChargingProfileKindRelative, types.ChargingRateUnitAmperes, period(0, 20))
+	tx.TransactionId = 7
+	require.NoError(t, s.Set(1, tx))
+	l, _ = s.Limit(1, now, txn)
+	assert.Equal(t, 20.0, l.Current)
+	l, _ = s.Limit(1, now, &Transaction{ID: 8, Start: now})
+	assert.Equal(t, 10.0, l.Current)
+
+	// charge point max caps everything, power converted to current
+	require.NoError(t, s.Set(0, profile(4, 0, types.ChargingProfilePurposeChargePointMaxProfile, types.ChargingProfileKindAbsolute, types.ChargingRateUnitWatts, period(0, 4140))))
+	l, _ = s.Limit(1, now, txn)
+	assert.Equal(t, 6.0, l.Current)
+
+	// invalid purposes
+	assert.Error(t, s.Set(1, profile(5, 0, types.ChargingProfilePurposeChargePointMaxProfile, types.ChargingProfileKindAbsolute, types.ChargingRateUnitAmperes, period(0, 16))))
+	assert.Error(t, s.Set(0, profile(5, 0, types.ChargingProfilePurposeTxProfile, types.ChargingProfileKindAbsolute, types.ChargingRateUnitAmperes, period(0, 16))))
+
+	// tx profiles end with the transaction
+	assert.True(t, s.ClearTransaction(1))
+	l, _ = s.Limit(1, now, txn)
+	assert.Equal(t, 6.0, l.Current)
+}
+
+func TestLimitStackLevel(t *testing.T) {
+	s := NewStore()
+	now := time.Now()
+
+	duration := 60
+	high := profile(2, 5, types.ChargingProfilePurposeTxDefaultProfile, types.ChargingProfileKindAbsolute, types.ChargingRateUnitAmperes, period(0, 8))
+	high.ChargingSchedule.StartSchedule = types.NewDateTime(now)
+	high.ChargingSchedule.Duration = &duration
+
+	require.NoError(t, s.Set(1, profile(1, 0, types.ChargingProfilePurposeTxDefaultProfile, types.ChargingProfileKindAbsolute, types.ChargingRateUnitAmperes, period(0, 16))))
+	require.NoError(t, s.Set(1, high))
+
+	l, _ := s.Limit(1, now, nil)
+	assert.Equal(t, 8.0, l.Current)
+
+	// lower stack level applies after higher one has expired
+	l, _ = s.Limit(1, now.Add(time.Minute), nil)
+	assert.Equal(t, 16.0, l.Current)
+
+	// same purpose and stack level replaces profile
+	require.NoError(t, s.Set(1, profile(3, 5, types.ChargingProfilePurposeTxDefaultProfile, types.ChargingProfileKindAbsolute, types.ChargingRateUnitAmperes, period(0, 12))))
+	assert.Len(t, s.Profiles(1), 2)
+	l, _ = s.Limit(1, now.Add(time.Minute), nil)
+	assert.Equal(t, 12.0, l.Current)
+}
+
+func TestLimitValidity(t *testing.T) {
+	s := NewStore()
+	now := time.Now()
+
+	p := profile(1, 0, types.ChargingProfilePurposeTxDefaultProfile, types.ChargingProfileKindAbsolute, types.ChargingRateUnitAmperes, period(0, 10))
+	p.ValidFrom = types.NewDateTime(now.Add(time.Hour))
+	p.ValidTo = types.NewDateTime(now.Add(2 * time.Hour))
+	require.NoError(t, s.Set(0, p))
+
+	_, ok := s.Limit(1, now, nil)
+	assert.False(t, ok)
+	_, ok = s.Limit(1, now.Add(time.Hour), nil)
+	assert.True(t, ok)
+	_, ok = s.Limit(1, now.Add(2*time.Hour), nil)
+	assert.False(t, ok)
+}
+
+func TestLimitRelative(t *testing.T) {
+	s := NewStore()
+	now := time.Now()
+
+	p := profile(1, 0, types.ChargingProfilePurposeTxProfile, types.ChargingProfileKindRelative, types.ChargingRateUnitAmperes, period(0, 6), period(600, 16))
+	require.NoError(t, s.Set(1, p))
+
+	txn := &Transaction{ID: 1, Start: now}
+
+	l, _ := s.Limit(1, now.Add(time.Minute), txn)
+	assert.Equal(t, 6.0, l.Current)
+	l, _ = s.Limit(1, now.Add(10*time.Minute), txn)
+	assert.Equal(t, 16.0, l.Current)
+}
+
+func TestLimitRelativeIdle(t *testing.T) {
+	clck := clock.NewMock()
+	s := NewStore()
+	s.clock = clck
+
+	installed := clck.Now()
+
+	// without transaction relative schedules start when installed
+	p := profile(1, 0, types.ChargingProfilePurposeTxDefaultProfile, types.ChargingProfileKindRelative, types.ChargingRateUnitAmperes, period(0, 16), period(600, 6))
+	require.NoError(t, s.Set(1, p))
+
+	l, _ := s.Limit(1, installed.Add(time.Minute), nil)
+	assert.Equal(t, 16.0, l.Current)
+	l, _ = s.Limit(1, installed.Add(15*time.Minute), nil)
+	assert.Equal(t, 6.0, l.Current)
+
+	// composite schedule agrees with limit
+	from := installed.Add(5 * time.Minute)
+	schedule := s.CompositeSchedule(1, from, 3600, "", 32, nil)
+	require.NotNil(t, schedule)
+	assert.Equal(t, []types.ChargingSchedulePeriod{
+		period(0, 16),
+		period(300, 6),
+	}, schedule.ChargingSchedulePeriod)
+}
+
+func TestLimitRecurring(t *testing.T) {
+	s := NewStore()
+
+	midnight := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	p := profile(1, 0, types.ChargingProfilePurposeTxDefaultProfile, types.ChargingProfileKindRecurring, types.ChargingRateUnitAmperes, period(0, 6), period(6*3600, 16))
+	p.RecurrencyKind = types.RecurrencyKindDaily
+	p.ChargingSchedule.StartSchedule = types.NewDateTime(midnight)
+	require.NoError(t, s.Set(0, p))
+
+	l, _ := s.Limit(1, midnight.Add(10*24*time.Hour+time.Hour), nil)
+	assert.Equal(t, 6.0, l.Current)
+	l, _ = s.Limit(1, midnight.Add(10*24*time.Hour+7*time.Hour), nil)
+	assert.Equal(t, 16.0, l.Current)
+
+	// recurring profiles require start schedule
+	p = profile(2, 1, types.ChargingProfilePurposeTxDefaultProfile, types.ChargingProfileKindRecurring, types.ChargingRateUnitAmperes, period(0, 6))
+	assert.Error(t, s.Set(0, p))
+}
+
+func TestLimitRecurringDST(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	s := NewStore()
+
+	// daily schedule starting at local midnight before the change to summer time
+	midnight := time.Date(2024, 3, 30, 0, 0, 0, 0, loc)
+	p := profile(1, 0, types.ChargingProfilePurposeTxDefaultProfile, types.ChargingProfileKindRecurring, types.ChargingRateUnitAmperes, period(0, 6), period(6*3600, 16))
+	p.RecurrencyKind = types.RecurrencyKindDaily
+	p.ChargingSchedule.StartSchedule = types.NewDateTime(midnight)
+	require.NoError(t, s.Set(0, p))
+
+	// periods keep their local time after the change
+	l, _ := s.Limit(1, time.Date(2024, 4, 1, 5, 30, 0, 0, loc), nil)
+	assert.Equal(t, 6.0, l.Current)
+	l, _ = s.Limit(1, time.Date(2024, 4, 1, 6, 30, 0, 0, loc), nil)
+	assert.Equal(t, 16.0, l.Current)
+
+	from := time.Date(2024, 3, 31, 12, 0, 0, 0, loc)
+	schedule := s.CompositeSchedule(1, from, 24*3600, "", 32, nil)
+	require.NotNil(t, schedule)
+	assert.Equal(t, []types.ChargingSchedulePeriod{
+		period(0, 16),
+		period(12*3600, 6),
+		period(18*3600, 16),
+	}, schedule.ChargingSchedulePeriod)
+}
+
+func TestLimitSharedMax(t *testing.T) {
+	s := NewStore()
+	now := time.Now()
+
+	require.NoError(t, s.Set(0, profile(1, 0, types.ChargingProfilePurposeChargePointMaxProfile, types.ChargingProfileKindAbsolute, types.ChargingRateUnitAmperes, period(0, 32))))
+	require.NoError(t, s.Set(0, profile(2, 0, types.ChargingProfilePurposeTxDefaultProfile, types.ChargingProfileKindAbsolute, types.ChargingRateUnitAmperes, period(0, 20))))
+
+	// single charging connector gets the whole max limit
+	s.SetCharging(1, true)
+	l, _ := s.Limit(1, now, nil)
+	assert.Equal(t, 20.0, l.Current)
+
+	// two charging connectors share the max limit
+	s.SetCharging(2, true)
+	for _, id := range []int{1, 2} {
+		l, _ = s.Limit(id, now, nil)
+		assert.Equal(t, 16.0, l.Current, id)
+	}
+
+	// charge point limit is not shared
+	l, _ = s.Limit(0, now, nil)
+	assert.Equal(t, 32.0, l.Current)
+
+	s.SetCharging(2, false)
+	l, _ = s.Limit(1, now, nil)
+	assert.Equal(t, 20.0, l.Current)
+}
+
+func TestClear(t *testing.T) {
+	s := NewStore()
+
+	require.NoError(t, s.Set(0, profile(1, 0, types.ChargingProfilePurposeChargePointMaxProfile, types.ChargingProfileKindAbsolute, types.ChargingRateUnitAmperes, period(0, 32))))
+	require.NoError(t, s.Set(1, profile(2, 0, types.ChargingProfilePurposeTxDefaultProfile, types.ChargingProfileKindAbsolute, types.ChargingRateUnitAmperes, period(0, 16))))
+	require.NoError(t, s.Set(2, profile(3, 1, types.ChargingProfilePurposeTxDefaultProfile, types.ChargingProfileKindAbsolute, types.ChargingRateUnitAmperes, period(0, 16))))
+
+	id := 4
+	assert.False(t, s.Clear(&smartcharging.ClearChargingProfileRequest{Id: &id}))
+
+	level := 1
+	assert.True(t, s.Clear(&smartcharging.ClearChargingProfileRequest{StackLevel: &level}))
+	assert.Empty(t, s.Profiles(2))
+
+	connector := 1
+	assert.True(t, s.Clear(&smartcharging.ClearChargingProfileRequest{ConnectorId: &connector, ChargingProfilePurpose: types.ChargingProfilePurposeTxDefaultProfile}))
+	assert.Empty(t, s.Profiles(1))
+
+	assert.True(t, s.Clear(&smartcharging.ClearChargingProfileRequest{}))
+	assert.Empty(t, s.Profiles(0))
+}
+
+func TestCompositeSchedule(t *testing.T) {
+	clck := clock.NewMock()
+	s := NewStore()
+	s.clock = clck
+
+	now := clck.Now()
+
+	assert.Nil(t, s.CompositeSchedule(1, now, 3600, "", 32, nil))
+
+	p := profile(1, 0, types.ChargingProfilePurposeTxDefaultProfile, types.ChargingProfileKindAbsolute, types.ChargingRateUnitAmperes, period(0, 6), period(600, 16), period(1200, 16), period(1800, 40))
+	require.NoError(t, s.Set(1, p))
+
+	max := profile(2, 0, types.ChargingProfilePurposeChargePointMaxProfile, types.ChargingProfileKindAbsolute, types.ChargingRateUnitAmperes, period(0, 10))
+	duration := 900
+	max.ChargingSchedule.StartSchedule = types.NewDateTime(now)
+	max.ChargingSchedule.Duration = &duration
+	require.NoError(t, s.Set(0, max))
+
+	schedule := s.CompositeSchedule(1, now, 3600, "", 32, nil)
+	require.NotNil(t, schedule)
+	assert.Equal(t, types.ChargingRateUnitAmperes, schedule.ChargingRateUnit)
+	assert.Equal(t, []types.ChargingSchedulePeriod{
+		period(0, 6),
+		period(600, 10),
+		period(900, 16),
+		period(1800, 32),
+	}, schedule.ChargingSchedulePeriod)
+
+	schedule = s.CompositeSchedule(1, now, 600, types.ChargingRateUnitWatts, 32, nil)
+	require.NotNil(t, schedule)
+	assert.Equal(t, []types.ChargingSchedulePeriod{
+		period(0, 6*3*NominalVoltage),
+	}, schedule.ChargingSchedulePeriod)
+}
@@ -0,0 +1,322 @@
+// Package ocpp201 bridges OCPP 2.0.1 and the OCPP 1.6 types used throughout evcc.
+// Functions returning OCPP 1.6 types carry a 16 suffix.
+package ocpp201
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/availability"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/transactions"
+	types201 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// Status16 maps the connector status and transaction charging state onto an OCPP 1.6 status
+func Status16(status availability.ConnectorStatus, state transactions.ChargingState) core.ChargePointStatus {
+	switch status {
+	case availability.ConnectorStatusAvailable:
+		return core.ChargePointStatusAvailable
+	case availability.ConnectorStatusReserved:
+		return core.ChargePointStatusReserved
+	case availability.ConnectorStatusUnavailable:
+		return core.ChargePointStatusUnavailable
+	case availability.ConnectorStatusFaulted:
+		return core.ChargePointStatusFaulted
+	}
+
+	// occupied, refined by the transaction's charging state
+	switch state {
+	case transactions.ChargingStateCharging:
+		return core.ChargePointStatusCharging
+	case transactions.ChargingStateSuspendedEV:
+		return core.ChargePointStatusSuspendedEV
+	case transactions.ChargingStateSuspendedEVSE:
+		return core.ChargePointStatusSuspendedEVSE
+	default:
+		return core.ChargePointStatusPreparing
+	}
+}
+
+// ConnectorStatus maps an OCPP 1.6 status onto the connector status
+func ConnectorStatus(status core.ChargePointStatus) availability.ConnectorStatus {
+	switch status {
+	case core.ChargePointStatusAvailable:
+		return availability.ConnectorStatusAvailable
+	case core.ChargePointStatusReserved:
+		return availability.ConnectorStatusReserved
+	case core.ChargePointStatusUnavailable:
+		return availability.ConnectorStatusUnavailable
+	case core.ChargePointStatusFaulted:
+		return availability.ConnectorStatusFaulted
+	default:
+		return availability.ConnectorStatusOccupied
+	}
+}
+
+// ChargingState maps an OCPP 1.6 status onto the transaction charging state
+func ChargingState(status core.ChargePointStatus) transactions.ChargingState {
+	switch status {
+	case core.ChargePointStatusCharging:
+		return transactions.ChargingStateCharging
+	case core.ChargePointStatusSuspendedEV:
+		return transactions.ChargingStateSuspendedEV
+	case core.ChargePointStatusSuspendedEVSE:
+		return transactions.ChargingStateSuspendedEVSE
+	case core.ChargePointStatusPreparing, core.ChargePointStatusFinishing:
+		return transactions.ChargingStateEVConnected
+	default:
+		return transactions.ChargingStateIdle
+	}
+}
+
+// StoppedReason maps an OCPP 1.6 stop reason onto the transaction's stopped reason
+func StoppedReason(reason core.Reason) transactions.Reason {
+	switch reason {
+	case core.ReasonHardReset, core.ReasonSoftReset:
+		return transactions.ReasonImmediateReset
+	case core.ReasonUnlockCommand:
+		return transactions.ReasonOther
+	case "":
+		return transactions.ReasonLocal
+	default:
+		// remaining reasons share their names
+		return transactions.Reason(reason)
+	}
+}
+
+// IdTokenInfo converts an OCPP 1.6 id tag info. The parent id tag becomes a central group id token.
+func IdTokenInfo(info *types.IdTagInfo) *types201.IdTokenInfo {
+	res := types201.NewIdTokenInfo(types201.AuthorizationStatus(info.Status))
+
+	if info.ExpiryDate != nil {
+		res.CacheExpiryDateTime = types201.NewDateTime(info.ExpiryDate.Time)
+	}
+
+	if info.ParentIdTag != "" {
+		res.GroupIdToken = &types201.GroupIdToken{
+			IdToken: info.ParentIdTag,
+			Type:    types201.IdTokenTypeCentral,
+		}
+	}
+
+	return res
+}
+
+// SampledValue16 converts a sampled value, applying the unit's multiplier
+func SampledValue16(sample types201.SampledValue) types.SampledValue {
+	res := types.SampledValue{
+		Context:   types.ReadingContext(sample.Context),
+		Measurand: types.Measurand(sample.Measurand),
+		Phase:     types.Phase(sample.Phase),
+		Location:  types.Location(sample.Location),
+	}
+
+	// measurand defaults to energy
+	if res.Measurand == "" {
+		res.Measurand = types.MeasurandEnergyActiveImportRegister
+	}
+
+	value := sample.Value
+	if uom := sample.UnitOfMeasure; uom != nil {
+		res.Unit = types.UnitOfMeasure(uom.Unit)
+		if uom.Multiplier != nil {
+			value *= math.Pow10(*uom.Multiplier)
+		}
+	}
+
+	res.Value = strconv.FormatFloat(value, 'f', -1, 64)
+
+	return res
+}
+
+// MeterValues16 converts meter values
+func MeterValues16(meterValues []types201.MeterValue) []types.MeterValue {
+	res := make([]types.MeterValue, 0, len(meterValues))
+
+	for _, mv := range meterValues {
+		meterValue := types.MeterValue{
+			Timestamp: types.NewDateTime(mv.Timestamp.Time),
+		}
+
+		for _, sample := range mv.SampledValue {
+			meterValue.SampledValue = append(meterValue.SampledValue, SampledValue16(sample))
+		}
+
+		res = append(res, meterValue)
+	}
+
+	return res
+}
+
+// SampledValue converts an OCPP 1.6 sampled value
+func SampledValue(sample types.SampledValue) (types201.SampledValue, error) {
+	value, err := strconv.ParseFloat(sample.Value, 64)
+	if err != nil {
+		return types201.SampledValue{}, fmt.Errorf("invalid value: %s", sample.Value)
+	}
+
+	res := types201.SampledValue{
+		Value:     value,
+		Context:   types201.ReadingContext(sample.Context),
+		Measurand: types201.Measurand(sample.Measurand),
+		Phase:     types201.Phase(sample.Phase),
+		Location:  types201.Location(sample.Location),
+	}
+
+	if sample.Unit != "" {
+		res.UnitOfMeasure = &types201.UnitOfMeasure{Unit: string(sample.Unit)}
+	}
+
+	return res, nil
+}
+
+// MeterValues converts OCPP 1.6 meter values
+func MeterValues(meterValues []types.MeterValue) ([]types201.MeterValue, error) {
+	res := make([]types201.MeterValue, 0, len(meterValues))
+
+	for _, mv := range meterValues {
+		var meterValue types201.MeterValue
+		if mv.Timestamp != nil {
+			meterValue.Timestamp = *types201.NewDateTime(mv.Timestamp.Time)
+		}
+
+		for _, sample := range mv.SampledValue {
+			sv, err := SampledValue(sample)
+			if err != nil {
+				return nil, err
+			}
+
+			meterValue.SampledValue = append(meterValue.SampledValue, sv)
+		}
+
+		res = append(res, meterValue)
+	}
+
+	return res, nil
+}
+
+// ChargingProfile16 converts a charging profile using its first charging schedule.
+// Transaction ids must be numeric.
+func ChargingProfile16(profile *types201.ChargingProfile) (*types.ChargingProfile, error) {
+	if profile == nil {
+		return nil, nil
+	}
+
+	if len(profile.ChargingSchedule) == 0 {
+		return nil, fmt.Errorf("missing charging schedule")
+	}
+
+	res := &types.ChargingProfile{
+		ChargingProfileId:   profile.ID,
+		StackLevel:          profile.StackLevel,
+		ChargingProfileKind: types.ChargingProfileKindType(profile.ChargingProfileKind),
+		RecurrencyKind:      types.RecurrencyKindType(profile.RecurrencyKind),
+		ChargingSchedule:    ChargingSchedule16(profile.ChargingSchedule[0]),
+	}
+
+	switch profile.ChargingProfilePurpose {
+	case types201.ChargingProfilePurposeChargingStationMaxProfile:
+		res.ChargingProfilePurpose = types.ChargingProfilePurposeChargePointMaxProfile
+	case types201.ChargingProfilePurposeTxDefaultProfile, types201.ChargingProfilePurposeTxProfile:
+		res.ChargingProfilePurpose = types.ChargingProfilePurposeType(profile.ChargingProfilePurpose)
+	default:
+		return nil, fmt.Errorf("unsupported charging profile purpose: %s", profile.ChargingProfilePurpose)
+	}
+
+	if profile.ValidFrom != nil {
+		res.ValidFrom = types.NewDateTime(profile.ValidFrom.Time)
+	}
+	if profile.ValidTo != nil {
+		res.ValidTo = types.NewDateTime(profile.ValidTo.Time)
+	}
+
+	if profile.TransactionID != "" {
+		id, err := strconv.Atoi(profile.TransactionID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid transaction id: %s", profile.TransactionID)
+		}
+		res.TransactionId = id
+	}
+
+	return res, nil
+}
+
+// ChargingProfile converts an OCPP 1.6 charging profile
+func ChargingProfile(profile *types.ChargingProfile) *types201.ChargingProfile {
+	res := &types201.ChargingProfile{
+		ID:                  profile.ChargingProfileId,
+		StackLevel:          profile.StackLevel,
+		ChargingProfileKind: types201.ChargingProfileKindType(profile.ChargingProfileKind),
+		RecurrencyKind:      types201.RecurrencyKindType(profile.RecurrencyKind),
+		ChargingSchedule:    []types201.ChargingSchedule{*ChargingSchedule(1, profile.ChargingSchedule)},
+	}
+
+	if profile.ChargingProfilePurpose == types.ChargingProfilePurposeChargePointMaxProfile {
+		res.ChargingProfilePurpose = types201.ChargingProfilePurposeChargingStationMaxProfile
+	} else {
+		res.ChargingProfilePurpose = types201.ChargingProfilePurposeType(profile.ChargingProfilePurpose)
+	}
+
+	if profile.ValidFrom != nil {
+		res.ValidFrom = types201.NewDateTime(profile.ValidFrom.Time)
+	}
+	if profile.ValidTo != nil {
+		res.ValidTo = types201.NewDateTime(profile.ValidTo.Time)
+	}
+
+	if profile.TransactionId != 0 {
+		res.TransactionID = strconv.Itoa(profile.TransactionId)
+	}
+
+	return res
+}
+
+// ChargingSchedule16 converts a charging schedule
+func ChargingSchedule16(schedule types201.ChargingSchedule) *types.ChargingSchedule {
+	res := &types.ChargingSchedule{
+		Duration:         schedule.Duration,
+		ChargingRateUnit: types.ChargingRateUnitType(schedule.ChargingRateUnit),
+		MinChargingRate:  schedule.MinChargingRate,
+	}
+
+	if schedule.StartSchedule != nil {
+		res.StartSchedule = types.NewDateTime(schedule.StartSchedule.Time)
+	}
+
+	for _, p := range schedule.ChargingSchedulePeriod {
+		res.ChargingSchedulePeriod = append(res.ChargingSchedulePeriod, types.ChargingSchedulePeriod{
+			StartPeriod:  p.StartPeriod,
+			Limit:        p.Limit,
+			NumberPhases: p.NumberPhases,
+		})
+	}
+
+	return res
+}
+
+// ChargingSchedule converts an OCPP 1.6 charging schedule
+func ChargingSchedule(id int, schedule *types.ChargingSchedule) *types201.ChargingSchedule {
+	res := &types201.ChargingSchedule{
+		ID:               id,
+		Duration:         schedule.Duration,
+		ChargingRateUnit: types201.ChargingRateUnitType(schedule.ChargingRateUnit),
+		MinChargingRate:  schedule.MinChargingRate,
+	}
+
+	if schedule.StartSchedule != nil {
+		res.StartSchedule = types201.NewDateTime(schedule.StartSchedule.Time)
+	}
+
+	for _, p := range schedule.ChargingSchedulePeriod {
+		res.ChargingSchedulePeriod = append(res.ChargingSchedulePeriod, types201.ChargingSchedulePeriod{
+			StartPeriod:  p.StartPeriod,
+			Limit:        p.Limit,
+			NumberPhases: p.NumberPhases,
+		})
+	}
+
+	return res
+}
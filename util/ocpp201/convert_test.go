@@ -0,0 +1,118 @@
+package ocpp201
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/availability"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/meter"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/transactions"
+	types201 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatus(t *testing.T) {
+	for _, status := range []core.ChargePointStatus{
+		core.ChargePointStatusAvailable,
+		core.ChargePointStatusPreparing,
+		core.ChargePointStatusCharging,
+		core.ChargePointStatusSuspendedEV,
+		core.ChargePointStatusSuspendedEVSE,
+		core.ChargePointStatusReserved,
+		core.ChargePointStatusUnavailable,
+		core.ChargePointStatusFaulted,
+	} {
+		assert.Equal(t, status, Status16(ConnectorStatus(status), ChargingState(status)), status)
+	}
+
+	assert.Equal(t, availability.ConnectorStatusOccupied, ConnectorStatus(core.ChargePointStatusFinishing))
+	assert.Equal(t, core.ChargePointStatusPreparing, Status16(availability.ConnectorStatusOccupied, transactions.ChargingStateEVConnected))
+}
+
+func TestSampledValue(t *testing.T) {
+	multiplier := 3
+	sample := SampledValue16(types201.SampledValue{
+		Value:         1.5,
+		UnitOfMeasure: &types201.UnitOfMeasure{Unit: "Wh", Multiplier: &multiplier},
+	})
+
+	assert.Equal(t, types.MeasurandEnergyActiveImportRegister, sample.Measurand)
+	assert.Equal(t, "1500", sample.Value)
+	assert.Equal(t, types.UnitOfMeasureWh, sample.Unit)
+
+	res, err := SampledValue(types.SampledValue{
+		Value:     "16.5",
+		Measurand: types.MeasurandCurrentImport,
+		Phase:     types.PhaseL2,
+		Unit:      types.UnitOfMeasureA,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 16.5, res.Value)
+	assert.Equal(t, types201.MeasurandCurrentImport, res.Measurand)
+	assert.Equal(t, types201.PhaseL2, res.Phase)
+	assert.Equal(t, "A", res.UnitOfMeasure.Unit)
+
+	_, err = SampledValue(types.SampledValue{Value: "foo"})
+	assert.Error(t, err)
+}
+
+func TestZeroSampledValue(t *testing.T) {
+	meterValues, err := MeterValues([]types.MeterValue{{
+		Timestamp:    types.NewDateTime(time.Now()),
+		SampledValue: []types.SampledValue{{Value: "0", Measurand: types.MeasurandPowerActiveImport}},
+	}})
+	require.NoError(t, err)
+
+	assert.NoError(t, ocppj.Validate.Struct(meter.NewMeterValuesRequest(1, meterValues)))
+}
+
+func TestResetValidation(t *testing.T) {
+	assert.NoError(t, ocppj.Validate.Struct(core.NewResetRequest(core.ResetTypeSoft)))
+	assert.NoError(t, ocppj.Validate.Struct(provisioning.NewResetRequest(provisioning.ResetTypeOnIdle)))
+}
+
+func TestChargingProfile(t *testing.T) {
+	phases := 1
+	period := types201.NewChargingSchedulePeriod(0, 10)
+	period.NumberPhases = &phases
+
+	profile := types201.NewChargingProfile(1, 2, types201.ChargingProfilePurposeChargingStationMaxProfile, types201.ChargingProfileKindAbsolute, []types201.ChargingSchedule{
+		*types201.NewChargingSchedule(1, types201.ChargingRateUnitAmperes, period),
+	})
+
+	res, err := ChargingProfile16(profile)
+	require.NoError(t, err)
+	assert.Equal(t, types.ChargingProfilePurposeChargePointMaxProfile, res.ChargingProfilePurpose)
+	assert.Equal(t, 2, res.StackLevel)
+	assert.Equal(t, types.ChargingRateUnitAmperes, res.ChargingSchedule.ChargingRateUnit)
+	assert.Equal(t, []types.ChargingSchedulePeriod{{StartPeriod: 0, Limit: 10, NumberPhases: &phases}}, res.ChargingSchedule.ChargingSchedulePeriod)
+
+	profile.ChargingProfilePurpose = types201.ChargingProfilePurposeTxProfile
+	profile.TransactionID = "42"
+	res, err = ChargingProfile16(profile)
+	require.NoError(t, err)
+	assert.Equal(t, 42, res.TransactionId)
+
+	profile.TransactionID = "tx-42"
+	_, err = ChargingProfile16(profile)
+	assert.Error(t, err)
+
+	profile.TransactionID = "42"
+	res, err = ChargingProfile16(profile)
+	require.NoError(t, err)
+	assert.Equal(t, profile, ChargingProfile(res))
+}
+
+func TestKey(t *testing.T) {
+	key, ok := Key(types201.Component{Name: "sampleddatactrlr"}, types201.Variable{Name: "TxUpdatedInterval"})
+	assert.True(t, ok)
+	assert.Equal(t, "MeterValueSampleInterval", key)
+
+	_, ok = Key(types201.Component{Name: "SampledDataCtrlr"}, types201.Variable{Name: "Unknown"})
+	assert.False(t, ok)
+}
@@ -0,0 +1,125 @@
+package ocpp201
+
+import (
+	"reflect"
+
+	core16 "github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	firmware16 "github.com/lorenzodonini/ocpp-go/ocpp1.6/firmware"
+	types16 "github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/remotecontrol"
+	types201 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+	"github.com/samber/lo"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+// OCPP 1.6 and 2.0.1 register their enumerations with the same tags on the shared ocppj validator.
+// Whichever package is initialized last wins, rejecting valid messages of the other protocol.
+// Register validations accepting the values of both protocols instead.
+func init() {
+	for tag, values := range map[string][]string{
+		"authorizationStatus": {
+			string(types201.AuthorizationStatusAccepted),
+			string(types201.AuthorizationStatusBlocked),
+			string(types201.AuthorizationStatusExpired),
+			string(types201.AuthorizationStatusInvalid),
+			string(types201.AuthorizationStatusConcurrentTx),
+			string(types201.AuthorizationStatusNoCredit),
+			string(types201.AuthorizationStatusNotAllowedTypeEVSE),
+			string(types201.AuthorizationStatusNotAtThisLocation),
+			string(types201.AuthorizationStatusNotAtThisTime),
+			string(types201.AuthorizationStatusUnknown),
+		},
+		"chargingProfilePurpose": {
+			string(types16.ChargingProfilePurposeChargePointMaxProfile),
+			string(types201.ChargingProfilePurposeChargingStationExternalConstraints),
+			string(types201.ChargingProfilePurposeChargingStationMaxProfile),
+			string(types201.ChargingProfilePurposeTxDefaultProfile),
+			string(types201.ChargingProfilePurposeTxProfile),
+		},
+		"measurand": {
+			string(types201.MeasueandSoC),
+			string(types201.MeasurandCurrentExport),
+			string(types201.MeasurandCurrentImport),
+			string(types201.MeasurandCurrentOffered),
+			string(types201.MeasurandEnergyActiveExportInterval),
+			string(types201.MeasurandEnergyActiveExportRegister),
+			string(types201.MeasurandEnergyReactiveExportInterval),
+			string(types201.MeasurandEnergyReactiveExportRegister),
+			string(types201.MeasurandEnergyReactiveImportRegister),
+			string(types201.MeasurandEnergyReactiveImportInterval),
+			string(types201.MeasurandEnergyActiveImportInterval),
+			string(types201.MeasurandEnergyActiveImportRegister),
+			string(types201.MeasurandFrequency),
+			string(types201.MeasurandPowerActiveExport),
+			string(types201.MeasurandPowerActiveImport),
+			string(types201.MeasurandPowerReactiveImport),
+			string(types201.MeasurandPowerReactiveExport),
+			string(types201.MeasurandPowerOffered),
+			string(types201.MeasurandPowerFactor),
+			string(types201.MeasurandVoltage),
+			string(types201.MeasurandTemperature),
+			string(types201.MeasurandEnergyActiveNet),
+			string(types201.MeasurandEnergyApparentNet),
+			string(types201.MeasurandEnergyReactiveNet),
+			string(types201.MeasurandEnergyApparentImport),
+			string(types201.MeasurandEnergyApparentExport),
+			string(types16.MeasurandRPM),
+		},
+		"messageTrigger": {
+			string(remotecontrol.MessageTriggerBootNotification),
+			string(remotecontrol.MessageTriggerLogStatusNotification),
+			string(remotecontrol.MessageTriggerFirmwareStatusNotification),
+			string(remotecontrol.MessageTriggerHeartbeat),
+			string(remotecontrol.MessageTriggerMeterValues),
+			string(remotecontrol.MessageTriggerSignChargingStationCertificate),
+			string(remotecontrol.MessageTriggerSignV2GCertificate),
+			string(remotecontrol.MessageTriggerStatusNotification),
+			string(remotecontrol.MessageTriggerTransactionEvent),
+			string(remotecontrol.MessageTriggerSignCombinedCertificate),
+			string(remotecontrol.MessageTriggerPublishFirmwareStatusNotification),
+			firmware16.DiagnosticsStatusNotificationFeatureName,
+		},
+		"resetStatus": {
+			string(provisioning.ResetStatusAccepted),
+			string(provisioning.ResetStatusRejected),
+			string(provisioning.ResetStatusScheduled),
+		},
+		"resetType": {
+			string(core16.ResetTypeHard),
+			string(core16.ResetTypeSoft),
+			string(provisioning.ResetTypeImmediate),
+			string(provisioning.ResetTypeOnIdle),
+		},
+		"unlockStatus": {
+			string(core16.UnlockStatusUnlocked),
+			string(core16.UnlockStatusUnlockFailed),
+			string(core16.UnlockStatusNotSupported),
+			string(remotecontrol.UnlockStatusOngoingAuthorizedTransaction),
+			string(remotecontrol.UnlockStatusUnknownConnector),
+		},
+	} {
+		values := values
+		_ = ocppj.Validate.RegisterValidation(tag, func(fl validator.FieldLevel) bool {
+			return lo.Contains(values, fl.Field().String())
+		})
+	}
+
+	// OCPP 2.0.1 sampled values require a non-zero value, rejecting readings like zero power.
+	// Validate them by a copy whose value is not required instead.
+	ocppj.Validate.RegisterCustomTypeFunc(func(v reflect.Value) any {
+		return sampledValue(v.Interface().(types201.SampledValue))
+	}, types201.SampledValue{})
+}
+
+// sampledValue equals types201.SampledValue except for the value's validation
+type sampledValue struct {
+	Value            float64                    `json:"value"`
+	Context          types201.ReadingContext    `json:"context,omitempty" validate:"omitempty,readingContext"`
+	Measurand        types201.Measurand         `json:"measurand,omitempty" validate:"omitempty,measurand"`
+	Phase            types201.Phase             `json:"phase,omitempty" validate:"omitempty,phase"`
+	Location         types201.Location          `json:"location,omitempty" validate:"omitempty,location"`
+	SignedMeterValue *types201.SignedMeterValue `json:"signedMeterValue,omitempty" validate:"omitempty"`
+	UnitOfMeasure    *types201.UnitOfMeasure    `json:"unitOfMeasure,omitempty" validate:"omitempty"`
+}
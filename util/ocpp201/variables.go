@@ -0,0 +1,35 @@
+package ocpp201
+
+import (
+	"strings"
+
+	types201 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// Variables maps OCPP 1.6 configuration keys to their OCPP 2.0.1 device model variables
+var Variables = map[string]types201.ComponentVariable{
+	"MeterValuesSampledData":            variable("SampledDataCtrlr", "TxUpdatedMeasurands"),
+	"MeterValueSampleInterval":          variable("SampledDataCtrlr", "TxUpdatedInterval"),
+	"ConnectorSwitch3to1PhaseSupported": variable("SmartChargingCtrlr", "Phases3to1"),
+	"HeartbeatInterval":                 variable("OCPPCommCtrlr", "HeartbeatInterval"),
+	"WebSocketPingInterval":             variable("OCPPCommCtrlr", "WebSocketPingInterval"),
+}
+
+func variable(component, variable string) types201.ComponentVariable {
+	return types201.ComponentVariable{
+		Component: types201.Component{Name: component},
+		Variable:  types201.Variable{Name: variable},
+	}
+}
+
+// Key returns the OCPP 1.6 configuration key of the device model variable.
+// Component and variable names are case insensitive.
+func Key(component types201.Component, variable types201.Variable) (string, bool) {
+	for key, v := range Variables {
+		if strings.EqualFold(v.Component.Name, component.Name) && strings.EqualFold(v.Variable.Name, variable.Name) {
+			return key, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,90 @@
+package ocpprecord
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// OCPP-J message types
+const (
+	Call       = 2
+	CallResult = 3
+	CallError  = 4
+)
+
+// Message is a parsed OCPP-J message
+type Message struct {
+	Type   int
+	ID     string
+	Action string // calls only
+
+	fields []json.RawMessage
+}
+
+// ParseMessage parses an OCPP-J message
+func ParseMessage(data []byte) (Message, error) {
+	var res Message
+
+	if err := json.Unmarshal(data, &res.fields); err != nil {
+		return res, err
+	}
+
+	if len(res.fields) < 3 {
+		return res, errors.New("invalid message")
+	}
+
+	if err := json.Unmarshal(res.fields[0], &res.Type); err != nil {
+		return res, fmt.Errorf("invalid message type: %w", err)
+	}
+
+	if err := json.Unmarshal(res.fields[1], &res.ID); err != nil {
+		return res, fmt.Errorf("invalid message id: %w", err)
+	}
+
+	switch res.Type {
+	case Call:
+		if len(res.fields) != 4 {
+			return res, errors.New("invalid call")
+		}
+
+		if err := json.Unmarshal(res.fields[2], &res.Action); err != nil {
+			return res, fmt.Errorf("invalid action: %w", err)
+		}
+	case CallResult, CallError:
+	default:
+		return res, fmt.Errorf("invalid message type: %d", res.Type)
+	}
+
+	return res, nil
+}
+
+// Payload returns the payload of calls and call results
+func (m Message) Payload() json.RawMessage {
+	switch m.Type {
+	case Call:
+		return m.fields[3]
+	case CallResult:
+		return m.fields[2]
+	}
+
+	return nil
+}
+
+// WithID returns the message with replaced unique id
+func (m Message) WithID(id string) ([]byte, error) {
+	b, err := json.Marshal(id)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := append([]json.RawMessage(nil), m.fields...)
+	fields[1] = b
+
+	return json.Marshal(fields)
+}
+
+// NewCallError creates an OCPP-J call error message
+func NewCallError(id, code, description string) ([]byte, error) {
+	return json.Marshal([]any{CallError, id, code, description, struct{}{}})
+}
@@ -0,0 +1,153 @@
+// Package ocpprecord records OCPP-J frames as JSON lines and replays recordings.
+package ocpprecord
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Direction of a frame as seen by the central system
+type Direction string
+
+const (
+	In  Direction = "in"  // sent by the station
+	Out Direction = "out" // sent by the central system
+)
+
+// Frame is a single recorded OCPP-J message
+type Frame struct {
+	Time      time.Time       `json:"time"`
+	Station   string          `json:"station"`
+	Protocol  string          `json:"protocol,omitempty"`
+	Direction Direction       `json:"direction"`
+	Message   json.RawMessage `json:"message"`
+}
+
+// Recorder appends frames to a JSON lines file
+type Recorder struct {
+	mu  sync.Mutex
+	w   io.Writer
+	enc *json.Encoder
+}
+
+// NewRecorder creates a recorder writing to w
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{
+		w:   w,
+		enc: json.NewEncoder(w),
+	}
+}
+
+// Create creates a recorder appending to the file
+func Create(path string) (*Recorder, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewRecorder(f), nil
+}
+
+// Record writes the frame. Frames which are not valid JSON are recorded as string.
+func (r *Recorder) Record(station, protocol string, dir Direction, data []byte) error {
+	msg := json.RawMessage(data)
+	if !json.Valid(data) {
+		b, err := json.Marshal(string(data))
+		if err != nil {
+			return err
+		}
+		msg = b
+	}
+
+	frame := Frame{
+		Time:      time.Now(),
+		Station:   station,
+		Protocol:  protocol,
+		Direction: dir,
+		Message:   msg,
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.enc.Encode(frame)
+}
+
+// Close closes the underlying writer
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if c, ok := r.w.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
+
+// Read reads all frames of a recording
+func Read(r io.Reader) ([]Frame, error) {
+	var res []Frame
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var frame Frame
+		if err := json.Unmarshal(scanner.Bytes(), &frame); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		res = append(res, frame)
+	}
+
+	return res, scanner.Err()
+}
+
+// Load reads all frames of a recording file
+func Load(path string) ([]Frame, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Read(f)
+}
+
+// Stations returns the recording's station ids in order of appearance
+func Stations(frames []Frame) []string {
+	var res []string
+	seen := make(map[string]bool)
+
+	for _, frame := range frames {
+		if !seen[frame.Station] {
+			seen[frame.Station] = true
+			res = append(res, frame.Station)
+		}
+	}
+
+	return res
+}
+
+// Filter returns the station's frames
+func Filter(frames []Frame, station string) []Frame {
+	var res []Frame
+
+	for _, frame := range frames {
+		if frame.Station == station {
+			res = append(res, frame)
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,47 @@
+package ocpprecord
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecord(t *testing.T) {
+	var buf bytes.Buffer
+	rec := NewRecorder(&buf)
+
+	require.NoError(t, rec.Record("cp1", "ocpp1.6", In, []byte(`[2,"1","Heartbeat",{}]`)))
+	require.NoError(t, rec.Record("cp2", "ocpp1.6", Out, []byte(`[3,"1",{}]`)))
+	require.NoError(t, rec.Record("cp1", "ocpp1.6", In, []byte(`invalid`)))
+
+	frames, err := Read(&buf)
+	require.NoError(t, err)
+	require.Len(t, frames, 3)
+
+	assert.Equal(t, In, frames[0].Direction)
+	assert.JSONEq(t, `[2,"1","Heartbeat",{}]`, string(frames[0].Message))
+	assert.JSONEq(t, `"invalid"`, string(frames[2].Message))
+
+	assert.Equal(t, []string{"cp1", "cp2"}, Stations(frames))
+	assert.Len(t, Filter(frames, "cp1"), 2)
+}
+
+func TestParseMessage(t *testing.T) {
+	msg, err := ParseMessage([]byte(`[2,"42","BootNotification",{"chargePointModel":"m"}]`))
+	require.NoError(t, err)
+	assert.Equal(t, Call, msg.Type)
+	assert.Equal(t, "42", msg.ID)
+	assert.Equal(t, "BootNotification", msg.Action)
+	assert.JSONEq(t, `{"chargePointModel":"m"}`, string(msg.Payload()))
+
+	b, err := msg.WithID("7")
+	require.NoError(t, err)
+	assert.JSONEq(t, `[2,"7","BootNotification",{"chargePointModel":"m"}]`, string(b))
+
+	for _, invalid := range []string{`{}`, `[2,"1"]`, `[2,"1",{}]`, `[5,"1",{}]`, `["2","1",{}]`} {
+		_, err := ParseMessage([]byte(invalid))
+		assert.Error(t, err, invalid)
+	}
+}
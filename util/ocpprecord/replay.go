@@ -0,0 +1,211 @@
+package ocpprecord
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"reflect"
+	"sync"
+	"time"
+)
+
+const responseTimeout = 30 * time.Second
+
+// Replay replays one side of a station's recording against a live peer.
+// Calls of the replayed side are sent in recorded order and pace.
+// Calls of the peer are answered with the recorded responses to the same action in order.
+type Replay struct {
+	log   *log.Logger
+	speed float64
+	write func([]byte) error
+
+	calls    []Frame            // recorded calls of the replayed side
+	expected map[string]Message // recorded peer responses by call id
+
+	mu      sync.Mutex
+	answers map[string][]Message    // recorded responses to peer calls by action
+	pending map[string]chan Message // calls awaiting the peer's response
+}
+
+// NewReplay creates a replay of the station's frames sent in direction own.
+// Speed scales the recorded pace, zero sends calls as fast as the peer responds.
+func NewReplay(log *log.Logger, frames []Frame, own Direction, speed float64, write func([]byte) error) (*Replay, error) {
+	r := &Replay{
+		log:      log,
+		speed:    speed,
+		write:    write,
+		expected: make(map[string]Message),
+		answers:  make(map[string][]Message),
+		pending:  make(map[string]chan Message),
+	}
+
+	// peer call actions by id
+	peerCalls := make(map[string]string)
+
+	for _, frame := range frames {
+		msg, err := ParseMessage(frame.Message)
+		if err != nil {
+			log.Printf("skipping frame at %s: %v", frame.Time.Format(time.RFC3339), err)
+			continue
+		}
+
+		switch {
+		case msg.Type == Call && frame.Direction == own:
+			r.calls = append(r.calls, frame)
+		case msg.Type == Call:
+			peerCalls[msg.ID] = msg.Action
+		case frame.Direction == own:
+			if action, ok := peerCalls[msg.ID]; ok {
+				r.answers[action] = append(r.answers[action], msg)
+			}
+		default:
+			r.expected[msg.ID] = msg
+		}
+	}
+
+	if len(r.calls) == 0 && len(r.answers) == 0 {
+		return nil, errors.New("nothing to replay")
+	}
+
+	return r, nil
+}
+
+// Handle processes a message received from the peer
+func (r *Replay) Handle(data []byte) error {
+	msg, err := ParseMessage(data)
+	if err != nil {
+		return err
+	}
+
+	if msg.Type == Call {
+		return r.answer(msg)
+	}
+
+	r.mu.Lock()
+	ch, ok := r.pending[msg.ID]
+	r.mu.Unlock()
+
+	if !ok {
+		r.log.Printf("unexpected response: %s", data)
+		return nil
+	}
+
+	// ignore duplicate responses
+	select {
+	case ch <- msg:
+	default:
+	}
+
+	return nil
+}
+
+// answer responds to the peer's call with the next recorded response to the action
+func (r *Replay) answer(call Message) error {
+	r.mu.Lock()
+	var (
+		res Message
+		ok  bool
+	)
+	if queue := r.answers[call.Action]; len(queue) > 0 {
+		res, ok = queue[0], true
+		r.answers[call.Action] = queue[1:]
+	}
+	r.mu.Unlock()
+
+	var (
+		b   []byte
+		err error
+	)
+
+	if ok {
+		r.log.Printf("%s: answering with recorded response", call.Action)
+		b, err = res.WithID(call.ID)
+	} else {
+		r.log.Printf("%s: no recorded response", call.Action)
+		b, err = NewCallError(call.ID, "NotSupported", "not recorded")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return r.write(b)
+}
+
+// Run sends the recorded calls and compares the peer's responses to the recorded ones
+func (r *Replay) Run(ctx context.Context) error {
+	if len(r.calls) == 0 {
+		return nil
+	}
+
+	start, first := time.Now(), r.calls[0].Time
+
+	for _, frame := range r.calls {
+		if r.speed > 0 {
+			delay := time.Duration(float64(frame.Time.Sub(first))/r.speed) - time.Since(start)
+
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		if err := r.call(ctx, frame); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// call sends the recorded call and waits for the peer's response
+func (r *Replay) call(ctx context.Context, frame Frame) error {
+	msg, err := ParseMessage(frame.Message)
+	if err != nil {
+		return err
+	}
+
+	ch := make(chan Message, 1)
+
+	r.mu.Lock()
+	r.pending[msg.ID] = ch
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		delete(r.pending, msg.ID)
+		r.mu.Unlock()
+	}()
+
+	r.log.Printf("%s: sending", msg.Action)
+
+	if err := r.write(frame.Message); err != nil {
+		return err
+	}
+
+	select {
+	case res := <-ch:
+		if exp, ok := r.expected[msg.ID]; ok && (exp.Type != res.Type || !jsonEqual(exp.Payload(), res.Payload())) {
+			r.log.Printf("%s: response differs: recorded %s, received %s", msg.Action, exp.Payload(), res.Payload())
+		}
+	case <-time.After(responseTimeout):
+		r.log.Printf("%s: response timeout", msg.Action)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return nil
+}
+
+// jsonEqual compares JSON values ignoring formatting
+func jsonEqual(a, b json.RawMessage) bool {
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return bytes.Equal(a, b)
+	}
+
+	return reflect.DeepEqual(va, vb)
+}
@@ -0,0 +1,58 @@
+package ocpprecord
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func frame(dir Direction, msg string) Frame {
+	return Frame{Time: time.Now(), Station: "cp1", Direction: dir, Message: json.RawMessage(msg)}
+}
+
+func TestReplay(t *testing.T) {
+	frames := []Frame{
+		frame(In, `[2,"a","BootNotification",{}]`),
+		frame(Out, `[3,"a",{"status":"Accepted"}]`),
+		frame(Out, `[2,"b","RemoteStartTransaction",{"idTag":"evcc"}]`),
+		frame(In, `[3,"b",{"status":"Accepted"}]`),
+	}
+
+	var written []string
+
+	var r *Replay
+	r, err := NewReplay(log.New(io.Discard, "", 0), frames, In, 0, func(data []byte) error {
+		written = append(written, string(data))
+
+		// peer responds to calls immediately
+		if msg, err := ParseMessage(data); err == nil && msg.Type == Call {
+			go func() {
+				_ = r.Handle([]byte(`[3,"` + msg.ID + `",{"status":"Accepted"}]`))
+			}()
+		}
+
+		return nil
+	})
+	require.NoError(t, err)
+
+	// peer calls are answered with the recorded response and the peer's message id
+	require.NoError(t, r.Handle([]byte(`[2,"x","RemoteStartTransaction",{"idTag":"evcc"}]`)))
+	require.Len(t, written, 1)
+	assert.JSONEq(t, `[3,"x",{"status":"Accepted"}]`, written[0])
+
+	// unrecorded calls are rejected
+	require.NoError(t, r.Handle([]byte(`[2,"y","RemoteStartTransaction",{"idTag":"evcc"}]`)))
+	require.Len(t, written, 2)
+	assert.JSONEq(t, `[4,"y","NotSupported","not recorded",{}]`, written[1])
+
+	// own calls are sent in order
+	require.NoError(t, r.Run(context.Background()))
+	require.Len(t, written, 3)
+	assert.JSONEq(t, `[2,"a","BootNotification",{}]`, written[2])
+}
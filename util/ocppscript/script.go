@@ -0,0 +1,213 @@
+// Package ocppscript runs timelines of actions and expectations for the OCPP simulators.
+package ocppscript
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ExpectTimeout is the default time an expectation waits for being met
+const ExpectTimeout = 30 * time.Second
+
+// Actions is a list of actions given as single value or sequence
+type Actions []string
+
+func (a *Actions) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*a = Actions{value.Value}
+		return nil
+	}
+
+	var res []string
+	err := value.Decode(&res)
+	*a = res
+
+	return err
+}
+
+// Step is the common part of a timeline entry. It either executes an action or
+// registers an expectation that must be met within the given duration.
+// Simulators embed it inline and add their action parameters.
+type Step struct {
+	At        time.Duration `yaml:"at"`
+	Station   string        `yaml:"station"`
+	Action    string        `yaml:"action"` // wait or any simulator action
+	Connector int           `yaml:"connector"`
+	IdTag     string        `yaml:"idtag"`
+	Expect    Actions       `yaml:"expect"` // actions that must be received in this order
+	Status    string        `yaml:"status"` // connector status that must be reached, e.g. Charging
+	Within    time.Duration `yaml:"within"`
+}
+
+// Entry is implemented by simulator steps embedding Step
+type Entry interface {
+	Base() Step
+}
+
+// Base returns the common part of the step
+func (step Step) Base() Step {
+	return step
+}
+
+// Expectation returns true if the step registers an expectation
+func (step Step) Expectation() bool {
+	return len(step.Expect) > 0 || step.Status != ""
+}
+
+// ConnectorID returns the step's connector or connector 1 if not given
+func (step Step) ConnectorID() int {
+	if step.Connector == 0 {
+		return 1
+	}
+	return step.Connector
+}
+
+// Load reads the steps of a yaml or json script file
+func Load[S Entry](file string) ([]S, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var res struct {
+		Steps []S `yaml:"steps"`
+	}
+
+	if err := yaml.Unmarshal(b, &res); err != nil {
+		return nil, fmt.Errorf("invalid script: %w", err)
+	}
+
+	for i, step := range res.Steps {
+		if step.Base().Action == "" && !step.Base().Expectation() {
+			return nil, fmt.Errorf("step %d: missing action or expectation", i+1)
+		}
+	}
+
+	// sort by time, keeping order of simultaneous steps
+	sort.SliceStable(res.Steps, func(i, j int) bool {
+		return res.Steps[i].Base().At < res.Steps[j].Base().At
+	})
+
+	return res.Steps, nil
+}
+
+// Timeline executes steps at their time offset
+type Timeline[S Entry] struct {
+	Steps []S
+
+	// Execute runs the step's action
+	Execute func(step S) error
+
+	// Check returns nil once the step's expectation is met or the reason why it isn't.
+	// Messages received at or after from count towards the expectation.
+	Check func(step S, from time.Time) error
+
+	// Abort stops the timeline at the first failed action after pending expectations have completed
+	Abort bool
+}
+
+// Run executes the timeline and returns an error if any action or expectation failed
+func (t Timeline[S]) Run() error {
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		total  int
+		failed int
+		err    error
+	)
+
+	fail := func(i int, err error) {
+		log.Printf("step %d: FAIL: %v", i+1, err)
+		mu.Lock()
+		failed++
+		mu.Unlock()
+	}
+
+	start := time.Now()
+
+	for i, step := range t.Steps {
+		base := step.Base()
+
+		// messages caused by simultaneous actions count
+		from := start.Add(base.At)
+		time.Sleep(time.Until(from))
+
+		if base.Expectation() {
+			total++
+
+			wg.Add(1)
+			go func(i int, step S) {
+				defer wg.Done()
+
+				if err := t.expect(step, from); err != nil {
+					fail(i, err)
+				} else {
+					log.Printf("step %d: PASS", i+1)
+				}
+			}(i, step)
+		}
+
+		if base.Action != "" && base.Action != "wait" {
+			total++
+
+			log.Printf("step %d: %s", i+1, base.Action)
+
+			if e := t.Execute(step); e != nil {
+				fail(i, e)
+
+				if t.Abort {
+					err = fmt.Errorf("step %d: %w", i+1, e)
+					break
+				}
+			}
+		}
+	}
+
+	wg.Wait()
+
+	switch {
+	case err != nil:
+		return fmt.Errorf("%w (%d of %d steps failed)", err, failed, total)
+	case failed > 0:
+		return fmt.Errorf("%d of %d steps failed", failed, total)
+	}
+
+	return nil
+}
+
+// expect waits for the step's expectation to be met
+func (t Timeline[S]) expect(step S, from time.Time) error {
+	within := step.Base().Within
+	if within == 0 {
+		within = ExpectTimeout
+	}
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	var err error
+	for deadline := time.Now().Add(within); time.Now().Before(deadline); <-ticker.C {
+		if err = t.Check(step, from); err == nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("not met within %v: %w", within, err)
+}
+
+// Ordered returns true if the expected actions appear in the received actions in given order
+func Ordered(received, expected []string) bool {
+	i := 0
+	for _, action := range received {
+		if i < len(expected) && action == expected[i] {
+			i++
+		}
+	}
+	return i == len(expected)
+}
@@ -0,0 +1,106 @@
+package ocppscript
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testStep struct {
+	Step  `yaml:",inline"`
+	Value string `yaml:"value"`
+}
+
+func TestLoad(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "script.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`
+steps:
+  - at: 2s
+    action: stop
+  - action: start
+    value: foo
+  - expect: [BootNotification, StatusNotification]
+  - expect: Heartbeat
+`), 0o644))
+
+	steps, err := Load[testStep](file)
+	require.NoError(t, err)
+	require.Len(t, steps, 4)
+
+	// sorted by time, simultaneous steps keep their order
+	assert.Equal(t, "start", steps[0].Action)
+	assert.Equal(t, "foo", steps[0].Value)
+	assert.Equal(t, Actions{"BootNotification", "StatusNotification"}, steps[1].Expect)
+	assert.Equal(t, Actions{"Heartbeat"}, steps[2].Expect)
+	assert.Equal(t, 2*time.Second, steps[3].At)
+
+	require.NoError(t, os.WriteFile(file, []byte(`
+steps:
+  - connector: 1
+`), 0o644))
+
+	_, err = Load[testStep](file)
+	assert.Error(t, err)
+}
+
+func TestOrdered(t *testing.T) {
+	received := []string{"BootNotification", "StatusNotification", "StartTransaction", "MeterValues", "StopTransaction"}
+
+	assert.True(t, Ordered(received, nil))
+	assert.True(t, Ordered(received, []string{"BootNotification", "StartTransaction", "StopTransaction"}))
+	assert.False(t, Ordered(received, []string{"StopTransaction", "MeterValues"}))
+	assert.False(t, Ordered(received, []string{"Heartbeat"}))
+}
+
+func TestTimelineRun(t *testing.T) {
+	var met int32
+
+	timeline := func(abort bool, steps ...testStep) Timeline[testStep] {
+		return Timeline[testStep]{
+			Steps: steps,
+			Abort: abort,
+			Execute: func(step testStep) error {
+				switch step.Action {
+				case "meet":
+					atomic.StoreInt32(&met, 1)
+				case "fail":
+					return errors.New("failed")
+				}
+				return nil
+			},
+			Check: func(step testStep, from time.Time) error {
+				if atomic.LoadInt32(&met) == 0 {
+					return errors.New("not met")
+				}
+				return nil
+			},
+		}
+	}
+
+	step := func(at time.Duration, action, status string) testStep {
+		return testStep{Step: Step{At: at, Action: action, Status: status, Within: 500 * time.Millisecond}}
+	}
+
+	// expectation met by later action
+	atomic.StoreInt32(&met, 0)
+	require.NoError(t, timeline(false, step(0, "", "Charging"), step(100*time.Millisecond, "meet", "")).Run())
+
+	// failed actions are counted
+	atomic.StoreInt32(&met, 0)
+	err := timeline(false, step(0, "fail", ""), step(0, "meet", "")).Run()
+	require.Error(t, err)
+	assert.Equal(t, "1 of 2 steps failed", err.Error())
+
+	// failed action aborts after pending expectations
+	atomic.StoreInt32(&met, 0)
+	err = timeline(true, step(0, "", "Charging"), step(0, "fail", ""), step(0, "meet", "")).Run()
+	require.Error(t, err)
+	assert.Equal(t, "step 2: failed (2 of 2 steps failed)", err.Error())
+	assert.Zero(t, atomic.LoadInt32(&met))
+}